@@ -14,20 +14,53 @@ const (
 	INSERT       NodeType = "INSERT"
 	DELETE       NodeType = "DELETE"
 	UPDATE       NodeType = "UPDATE"
+	BEGIN        NodeType = "BEGIN"
+	COMMIT       NodeType = "COMMIT"
+	ROLLBACK     NodeType = "ROLLBACK"
+	CREATE_INDEX NodeType = "CREATE_INDEX"
+	DROP_INDEX   NodeType = "DROP_INDEX"
 
 	INTEGER    NodeType = "INTEGER"
 	FLOAT      NodeType = "FLOAT"
 	STRING     NodeType = "STRING"
 	BOOLEAN    NodeType = "BOOLEAN"
+	NULL       NodeType = "NULL"
 	IDENTIFIER NodeType = "IDENTIFIER"
 
 	INFIX_EXPRESSION NodeType = "INFIX_EXPRESSION"
+	IN_EXPRESSION    NodeType = "IN_EXPRESSION"
+	IS_EXPRESSION    NodeType = "IS_EXPRESSION"
+
+	PREFIX_EXPRESSION  NodeType = "PREFIX_EXPRESSION"
+	PATTERN_EXPRESSION NodeType = "PATTERN_EXPRESSION"
+	BETWEEN_EXPRESSION NodeType = "BETWEEN_EXPRESSION"
+	CASE_EXPRESSION    NodeType = "CASE_EXPRESSION"
+
+	TABLE_NAME NodeType = "TABLE_NAME"
+	JOIN       NodeType = "JOIN"
+
+	CALL_EXPRESSION NodeType = "CALL_EXPRESSION"
+
+	SUBQUERY_EXPRESSION NodeType = "SUBQUERY_EXPRESSION"
+	EXISTS_EXPRESSION   NodeType = "EXISTS_EXPRESSION"
 )
 
 type Program struct {
 	Statements []Statement
 }
 
+// HasTransactionStatements reports whether the program begins or ends
+// transactions itself.
+func (p *Program) HasTransactionStatements() bool {
+	for _, stmt := range p.Statements {
+		switch stmt.Type() {
+		case BEGIN, COMMIT, ROLLBACK:
+			return true
+		}
+	}
+	return false
+}
+
 type Node interface {
 	Type() NodeType
 	String() string
@@ -45,10 +78,33 @@ type Expression interface {
 	expressionNode()
 }
 
+// TableExpression is a source of rows in a FROM clause.
+type TableExpression interface {
+	Type() NodeType
+	String() string
+	tableExpressionNode()
+}
+
 type SelectStatement struct {
-	Table     *token.Token
-	Columns   []*token.Token
+	From      TableExpression
+	Columns   []*SelectColumn
 	Predicate Expression
+	GroupBy   []Expression
+	Having    Expression
+	OrderBy   []*OrderingTerm
+	Limit     Expression
+	Offset    Expression
+
+	// Distinct drops duplicate rows. With DistinctOn, rows are duplicates
+	// when those expressions are equal, and the first of them is kept.
+	Distinct   bool
+	DistinctOn []Expression
+
+	// With names the results of queries the statement can read
+	With *WithClause
+	// Compound are the queries whose rows are combined with those of the
+	// statement. OrderBy, Limit and Offset then apply to the combined rows.
+	Compound []*CompoundSelect
 }
 
 func (ss *SelectStatement) statementNode() {}
@@ -56,22 +112,190 @@ func (ss *SelectStatement) Type() NodeType { return SELECT }
 func (ss *SelectStatement) String() string {
 	columns := []string{}
 	for _, col := range ss.Columns {
-		columns = append(columns, col.Literal)
+		columns = append(columns, col.String())
 	}
 
 	cols := strings.Join(columns, ", ")
+	if len(ss.DistinctOn) != 0 {
+		exprs := []string{}
+		for _, expr := range ss.DistinctOn {
+			exprs = append(exprs, expr.String())
+		}
+		cols = fmt.Sprintf("DISTINCT ON (%s) %s", strings.Join(exprs, ", "), cols)
+	} else if ss.Distinct {
+		cols = "DISTINCT " + cols
+	}
 
 	predicate := ""
 	if ss.Predicate != nil {
 		predicate = fmt.Sprintf(" WHERE %s", ss.Predicate.String())
 	}
 
-	return fmt.Sprintf("SELECT %s FROM %s%s", cols, ss.Table.Literal, predicate)
+	if len(ss.GroupBy) != 0 {
+		groups := []string{}
+		for _, expr := range ss.GroupBy {
+			groups = append(groups, expr.String())
+		}
+		predicate += fmt.Sprintf(" GROUP BY %s", strings.Join(groups, ", "))
+	}
+
+	if ss.Having != nil {
+		predicate += fmt.Sprintf(" HAVING %s", ss.Having.String())
+	}
+
+	for _, term := range ss.Compound {
+		predicate += " " + term.String()
+	}
+
+	if len(ss.OrderBy) != 0 {
+		terms := []string{}
+		for _, term := range ss.OrderBy {
+			terms = append(terms, term.String())
+		}
+		predicate += fmt.Sprintf(" ORDER BY %s", strings.Join(terms, ", "))
+	}
+
+	if ss.Limit != nil {
+		predicate += fmt.Sprintf(" LIMIT %s", ss.Limit.String())
+	}
+	if ss.Offset != nil {
+		predicate += fmt.Sprintf(" OFFSET %s", ss.Offset.String())
+	}
+
+	with := ""
+	if ss.With != nil {
+		with = ss.With.String() + " "
+	}
+
+	return fmt.Sprintf("%sSELECT %s FROM %s%s", with, cols, ss.From.String(), predicate)
+}
+
+type CompoundOperator string
+
+const (
+	UNION     CompoundOperator = "UNION"
+	INTERSECT CompoundOperator = "INTERSECT"
+	EXCEPT    CompoundOperator = "EXCEPT"
+)
+
+// CompoundSelect is a query whose rows are combined with those of the
+// queries before it. INTERSECT is done before UNION and EXCEPT, which are
+// done from left to right. Unless All is set, duplicate rows are dropped.
+type CompoundSelect struct {
+	Operator CompoundOperator
+	All      bool
+	Select   *SelectStatement
+}
+
+func (cs *CompoundSelect) String() string {
+	operator := string(cs.Operator)
+	if cs.All {
+		operator += " ALL"
+	}
+	return fmt.Sprintf("%s %s", operator, cs.Select.String())
+}
+
+// WithClause names the results of queries, which the statement it starts
+// reads like tables. The tables of a recursive clause can read
+// themselves.
+type WithClause struct {
+	Recursive bool
+	Tables    []*CommonTableExpression
+}
+
+func (wc *WithClause) String() string {
+	tables := []string{}
+	for _, table := range wc.Tables {
+		tables = append(tables, table.String())
+	}
+
+	with := "WITH "
+	if wc.Recursive {
+		with += "RECURSIVE "
+	}
+	return with + strings.Join(tables, ", ")
+}
+
+// CommonTableExpression is a table of a WITH clause. In a recursive
+// clause, the query after the last UNION of Select can read the table:
+// the rows of the table are those of the queries before it, followed by
+// the rows it reads from the ones added before, until it adds none.
+type CommonTableExpression struct {
+	Name *token.Token
+	// Columns rename the columns of the result, if given
+	Columns []*token.Token
+	Select  *SelectStatement
+}
+
+func (cte *CommonTableExpression) String() string {
+	name := cte.Name.Literal
+	if len(cte.Columns) != 0 {
+		columns := []string{}
+		for _, col := range cte.Columns {
+			columns = append(columns, col.Literal)
+		}
+		name += fmt.Sprintf(" (%s)", strings.Join(columns, ", "))
+	}
+
+	return fmt.Sprintf("%s AS (%s)", name, cte.Select.String())
+}
+
+// SelectColumn is an entry of a SELECT list: either an expression with an
+// optional alias, or a star for all the columns of Table, or of every
+// table if Table is nil.
+type SelectColumn struct {
+	Expression Expression
+	Alias      *token.Token
+
+	Star  bool
+	Table *token.Token
+}
+
+func (sc *SelectColumn) String() string {
+	if sc.Star {
+		if sc.Table != nil {
+			return sc.Table.Literal + ".*"
+		}
+		return "*"
+	}
+
+	if sc.Alias != nil {
+		return fmt.Sprintf("%s AS %s", sc.Expression.String(), sc.Alias.Literal)
+	}
+	return sc.Expression.String()
+}
+
+type NullsOrder string
+
+const (
+	NULLS_FIRST NullsOrder = "FIRST"
+	NULLS_LAST  NullsOrder = "LAST"
+)
+
+// OrderingTerm is a term of ORDER BY. Unless Nulls says otherwise, NULLs
+// sort before any value.
+type OrderingTerm struct {
+	Expression Expression
+	Descending bool
+	Nulls      NullsOrder
+}
+
+func (ot *OrderingTerm) String() string {
+	term := ot.Expression.String()
+	if ot.Descending {
+		term += " DESC"
+	}
+	if ot.Nulls != "" {
+		term += " NULLS " + string(ot.Nulls)
+	}
+	return term
 }
 
 type CreateTableStatement struct {
 	Table   *token.Token
 	Columns []*ColumnDefinition
+	// Constraints are the constraints declared apart from the columns
+	Constraints []*TableConstraint
 }
 
 func (cs *CreateTableStatement) statementNode() {}
@@ -81,7 +305,10 @@ func (cs *CreateTableStatement) Type() NodeType {
 func (cs *CreateTableStatement) String() string {
 	columns := []string{}
 	for _, colDef := range cs.Columns {
-		columns = append(columns, fmt.Sprintf("%s %s", colDef.Name.Literal, colDef.DataType.Literal))
+		columns = append(columns, colDef.String())
+	}
+	for _, constraint := range cs.Constraints {
+		columns = append(columns, constraint.String())
 	}
 
 	cols := strings.Join(columns, ", ")
@@ -89,8 +316,153 @@ func (cs *CreateTableStatement) String() string {
 }
 
 type ColumnDefinition struct {
-	Name     *token.Token
-	DataType *token.Token
+	Name       *token.Token
+	DataType   *token.Token
+	PrimaryKey bool
+	Unique     bool
+	NotNull    bool
+	References *ForeignKey
+	Checks     []*CheckConstraint
+}
+
+func (cd *ColumnDefinition) String() string {
+	def := fmt.Sprintf("%s %s", cd.Name.Literal, cd.DataType.Literal)
+	if cd.PrimaryKey {
+		def += " PRIMARY KEY"
+	}
+	if cd.Unique {
+		def += " UNIQUE"
+	}
+	if cd.NotNull {
+		def += " NOT NULL"
+	}
+	if cd.References != nil {
+		def += " " + cd.References.String()
+	}
+	for _, check := range cd.Checks {
+		def += " " + check.String()
+	}
+	return def
+}
+
+// TableConstraint is a constraint over one or more columns of a table,
+// such as PRIMARY KEY (a, b). Kind is the keyword it starts with.
+type TableConstraint struct {
+	Kind    token.TokenType
+	Columns []*token.Token
+	// References is set for FOREIGN KEY constraints
+	References *ForeignKey
+	// Check is set for CHECK constraints
+	Check *CheckConstraint
+}
+
+func (tc *TableConstraint) String() string {
+	if tc.Check != nil {
+		return tc.Check.String()
+	}
+
+	columns := []string{}
+	for _, col := range tc.Columns {
+		columns = append(columns, col.Literal)
+	}
+
+	kind := string(tc.Kind)
+	switch tc.Kind {
+	case token.PRIMARY:
+		kind = "PRIMARY KEY"
+	case token.FOREIGN:
+		kind = "FOREIGN KEY"
+	}
+
+	constraint := fmt.Sprintf("%s (%s)", kind, strings.Join(columns, ", "))
+	if tc.References != nil {
+		constraint += " " + tc.References.String()
+	}
+	return constraint
+}
+
+// CheckConstraint is a CHECK (...) constraint. Name is nil unless the
+// constraint was named with CONSTRAINT. Source is the expression as it was
+// written.
+type CheckConstraint struct {
+	Name       *token.Token
+	Expression Expression
+	Source     string
+}
+
+func (cc *CheckConstraint) String() string {
+	check := fmt.Sprintf("CHECK (%s)", cc.Source)
+	if cc.Name != nil {
+		check = fmt.Sprintf("CONSTRAINT %s %s", cc.Name.Literal, check)
+	}
+	return check
+}
+
+// ForeignKey is the REFERENCES clause of a foreign key. Without Columns,
+// it refers to the primary key of Table. OnDelete is CASCADE, SET NULL or
+// RESTRICT, the default.
+type ForeignKey struct {
+	Table    *token.Token
+	Columns  []*token.Token
+	OnDelete string
+}
+
+func (fk *ForeignKey) String() string {
+	ref := fmt.Sprintf("REFERENCES %s", fk.Table.Literal)
+	if len(fk.Columns) != 0 {
+		columns := []string{}
+		for _, col := range fk.Columns {
+			columns = append(columns, col.Literal)
+		}
+		ref += fmt.Sprintf(" (%s)", strings.Join(columns, ", "))
+	}
+	if fk.OnDelete != "" {
+		ref += " ON DELETE " + fk.OnDelete
+	}
+	return ref
+}
+
+type CreateIndexStatement struct {
+	Name    *token.Token
+	Table   *token.Token
+	Columns []*token.Token
+	Unique  bool
+	// Method is the index type named after USING, if any
+	Method *token.Token
+}
+
+func (cs *CreateIndexStatement) statementNode() {}
+func (cs *CreateIndexStatement) Type() NodeType {
+	return CREATE_INDEX
+}
+func (cs *CreateIndexStatement) String() string {
+	columns := []string{}
+	for _, col := range cs.Columns {
+		columns = append(columns, col.Literal)
+	}
+
+	unique := ""
+	if cs.Unique {
+		unique = "UNIQUE "
+	}
+
+	using := ""
+	if cs.Method != nil {
+		using = fmt.Sprintf(" USING %s", strings.ToUpper(cs.Method.Literal))
+	}
+
+	cols := strings.Join(columns, ", ")
+	return fmt.Sprintf("CREATE %sINDEX %s ON %s%s (%s)", unique, cs.Name.Literal, cs.Table.Literal, using, cols)
+}
+
+type DropIndexStatement struct {
+	Name *token.Token
+}
+
+func (ds *DropIndexStatement) statementNode() {}
+func (ds *DropIndexStatement) Type() NodeType { return DROP_INDEX }
+func (ds *DropIndexStatement) String() string {
+	return fmt.Sprintf("DROP INDEX %s", ds.Name.Literal)
 }
 
 type InsertStatement struct {
@@ -135,7 +507,7 @@ func (ds *DeleteStatement) String() string {
 
 type UpdateStatement struct {
 	Table     *token.Token
-	Update    [][]*token.Token
+	Update    []*SetClause
 	Predicate Expression
 }
 
@@ -143,8 +515,8 @@ func (us *UpdateStatement) statementNode() {}
 func (us *UpdateStatement) Type() NodeType { return UPDATE }
 func (us *UpdateStatement) String() string {
 	updates := []string{}
-	for _, col := range us.Update {
-		updates = append(updates, fmt.Sprintf("%s=%s", col[0].Literal, col[1].Literal))
+	for _, set := range us.Update {
+		updates = append(updates, set.String())
 	}
 
 	predicate := ""
@@ -156,6 +528,41 @@ func (us *UpdateStatement) String() string {
 	return fmt.Sprintf("UPDATE %s SET %s%s", us.Table.Literal, ups, predicate)
 }
 
+// SetClause is a column = value assignment of an UPDATE. The value is
+// evaluated for every updated row.
+type SetClause struct {
+	Column *token.Token
+	Value  Expression
+}
+
+func (sc *SetClause) String() string {
+	return fmt.Sprintf("%s=%s", sc.Column.Literal, sc.Value.String())
+}
+
+type BeginStatement struct {
+	Token *token.Token
+}
+
+func (bs *BeginStatement) statementNode() {}
+func (bs *BeginStatement) Type() NodeType { return BEGIN }
+func (bs *BeginStatement) String() string { return "BEGIN" }
+
+type CommitStatement struct {
+	Token *token.Token
+}
+
+func (cs *CommitStatement) statementNode() {}
+func (cs *CommitStatement) Type() NodeType { return COMMIT }
+func (cs *CommitStatement) String() string { return "COMMIT" }
+
+type RollbackStatement struct {
+	Token *token.Token
+}
+
+func (rs *RollbackStatement) statementNode() {}
+func (rs *RollbackStatement) Type() NodeType { return ROLLBACK }
+func (rs *RollbackStatement) String() string { return "ROLLBACK" }
+
 type IntegerLiteral struct {
 	Token *token.Token
 	Value int64
@@ -191,12 +598,17 @@ func (sl *StringLiteral) String() string {
 
 type Identifier struct {
 	Token *token.Token
+	// Table qualifies the name in table.name
+	Table *token.Token
 	Value string
 }
 
 func (i *Identifier) expressionNode() {}
 func (i *Identifier) Type() NodeType  { return IDENTIFIER }
 func (i *Identifier) String() string {
+	if i.Table != nil {
+		return i.Table.Literal + "." + i.Value
+	}
 	return i.Value
 }
 
@@ -211,6 +623,32 @@ func (b *Boolean) String() string {
 	return fmt.Sprintf("%v", b.Value)
 }
 
+// Null is the NULL literal.
+type Null struct {
+	Token *token.Token
+}
+
+func (n *Null) expressionNode() {}
+func (n *Null) Type() NodeType  { return NULL }
+func (n *Null) String() string {
+	return "NULL"
+}
+
+type PrefixExpression struct {
+	Token    *token.Token
+	Operator string
+	Right    Expression
+}
+
+func (pe *PrefixExpression) expressionNode() {}
+func (pe *PrefixExpression) Type() NodeType  { return PREFIX_EXPRESSION }
+func (pe *PrefixExpression) String() string {
+	if pe.Operator == "NOT" {
+		return "NOT " + operandString(pe.Right)
+	}
+	return fmt.Sprintf("%s%s", pe.Operator, operandString(pe.Right))
+}
+
 type InfixExpression struct {
 	Token    *token.Token
 	Left     Expression
@@ -221,5 +659,348 @@ type InfixExpression struct {
 func (ie *InfixExpression) expressionNode() {}
 func (ie *InfixExpression) Type() NodeType  { return INFIX_EXPRESSION }
 func (ie *InfixExpression) String() string {
-	return fmt.Sprintf("%s%s%s", ie.Left.String(), ie.Operator, ie.Right.String())
+	return fmt.Sprintf("%s%s%s", operandString(ie.Left), ie.Operator, operandString(ie.Right))
+}
+
+// operandString returns the string of an operand of a prefix or infix
+// operator. Operands that are operations themselves are put in parentheses,
+// so that differently grouped expressions read differently.
+func operandString(operand Expression) string {
+	switch operand.(type) {
+	case *PrefixExpression, *InfixExpression, *InExpression, *BetweenExpression, *PatternExpression, *IsExpression:
+		return "(" + operand.String() + ")"
+	}
+	return operand.String()
+}
+
+// InExpression is Left IN (Values), or Left IN (Subquery) if Subquery is
+// set, in which case Left is looked up in the rows it returns.
+type InExpression struct {
+	Token    *token.Token
+	Left     Expression
+	Not      bool
+	Values   []Expression
+	Subquery *SubqueryExpression
+}
+
+func (ie *InExpression) expressionNode() {}
+func (ie *InExpression) Type() NodeType  { return IN_EXPRESSION }
+func (ie *InExpression) String() string {
+	if ie.Subquery != nil {
+		op := "IN"
+		if ie.Not {
+			op = "NOT IN"
+		}
+		return fmt.Sprintf("%s %s %s", ie.Left.String(), op, ie.Subquery.String())
+	}
+
+	values := []string{}
+	for _, v := range ie.Values {
+		values = append(values, v.String())
+	}
+	op := "IN"
+	if ie.Not {
+		op = "NOT IN"
+	}
+	return fmt.Sprintf("%s %s (%s)", ie.Left.String(), op, strings.Join(values, ", "))
+}
+
+// IsExpression is Left IS NULL, or Left IS NOT NULL when Not is set.
+type IsExpression struct {
+	Token *token.Token
+	Left  Expression
+	Not   bool
+}
+
+func (ie *IsExpression) expressionNode() {}
+func (ie *IsExpression) Type() NodeType  { return IS_EXPRESSION }
+func (ie *IsExpression) String() string {
+	if ie.Not {
+		return fmt.Sprintf("%s IS NOT NULL", ie.Left.String())
+	}
+	return fmt.Sprintf("%s IS NULL", ie.Left.String())
+}
+
+// PatternExpression matches Left against Pattern with LIKE, GLOB or
+// REGEXP, the Operator. Escape is only set for LIKE.
+type PatternExpression struct {
+	Token    *token.Token
+	Left     Expression
+	Operator string
+	Not      bool
+	Pattern  Expression
+	Escape   Expression
+}
+
+func (pe *PatternExpression) expressionNode() {}
+func (pe *PatternExpression) Type() NodeType  { return PATTERN_EXPRESSION }
+func (pe *PatternExpression) String() string {
+	op := pe.Operator
+	if pe.Not {
+		op = "NOT " + op
+	}
+
+	expr := fmt.Sprintf("%s %s %s", pe.Left.String(), op, pe.Pattern.String())
+	if pe.Escape != nil {
+		expr += " ESCAPE " + pe.Escape.String()
+	}
+	return expr
+}
+
+// BetweenExpression is Left BETWEEN Low AND High, which includes both
+// bounds.
+type BetweenExpression struct {
+	Token *token.Token
+	Left  Expression
+	Not   bool
+	Low   Expression
+	High  Expression
+}
+
+func (be *BetweenExpression) expressionNode() {}
+func (be *BetweenExpression) Type() NodeType  { return BETWEEN_EXPRESSION }
+func (be *BetweenExpression) String() string {
+	op := "BETWEEN"
+	if be.Not {
+		op = "NOT BETWEEN"
+	}
+	return fmt.Sprintf("%s %s %s AND %s", be.Left.String(), op, be.Low.String(), be.High.String())
+}
+
+// CaseExpression is CASE [Operand] WHEN ... THEN ... [ELSE ...] END. With
+// an Operand, the WHEN values are compared with it; without one, they are
+// conditions.
+type CaseExpression struct {
+	Token   *token.Token
+	Operand Expression
+	Whens   []*WhenClause
+	Else    Expression
+}
+
+type WhenClause struct {
+	Condition Expression
+	Result    Expression
+}
+
+func (ce *CaseExpression) expressionNode() {}
+func (ce *CaseExpression) Type() NodeType  { return CASE_EXPRESSION }
+func (ce *CaseExpression) String() string {
+	var expr strings.Builder
+	expr.WriteString("CASE")
+	if ce.Operand != nil {
+		expr.WriteString(" " + ce.Operand.String())
+	}
+	for _, when := range ce.Whens {
+		expr.WriteString(fmt.Sprintf(" WHEN %s THEN %s", when.Condition.String(), when.Result.String()))
+	}
+	if ce.Else != nil {
+		expr.WriteString(" ELSE " + ce.Else.String())
+	}
+	expr.WriteString(" END")
+	return expr.String()
+}
+
+// TableName is a table in a FROM clause. Its columns are qualified by the
+// alias if there's one.
+type TableName struct {
+	Name  *token.Token
+	Alias *token.Token
+}
+
+func (tn *TableName) tableExpressionNode() {}
+func (tn *TableName) Type() NodeType       { return TABLE_NAME }
+func (tn *TableName) String() string {
+	if tn.Alias != nil {
+		return fmt.Sprintf("%s AS %s", tn.Name.Literal, tn.Alias.Literal)
+	}
+	return tn.Name.Literal
+}
+
+type JoinKind string
+
+const (
+	INNER_JOIN JoinKind = "INNER"
+	LEFT_JOIN  JoinKind = "LEFT"
+	RIGHT_JOIN JoinKind = "RIGHT"
+	FULL_JOIN  JoinKind = "FULL"
+	CROSS_JOIN JoinKind = "CROSS"
+)
+
+// JoinExpression combines the rows of Left and Right that match On, or
+// that have equal values in the Using columns. Outer joins also keep the
+// rows of one or both sides that match nothing.
+type JoinExpression struct {
+	Token *token.Token
+	Kind  JoinKind
+	Left  TableExpression
+	Right TableExpression
+	On    Expression
+	Using []*token.Token
+}
+
+func (je *JoinExpression) tableExpressionNode() {}
+func (je *JoinExpression) Type() NodeType       { return JOIN }
+func (je *JoinExpression) String() string {
+	condition := ""
+	if je.On != nil {
+		condition = " ON " + je.On.String()
+	} else if len(je.Using) != 0 {
+		columns := []string{}
+		for _, col := range je.Using {
+			columns = append(columns, col.Literal)
+		}
+		condition = fmt.Sprintf(" USING (%s)", strings.Join(columns, ", "))
+	}
+
+	return fmt.Sprintf("%s %s JOIN %s%s", je.Left.String(), je.Kind, je.Right.String(), condition)
+}
+
+// CallExpression is a call of the function Name. Aggregate functions may
+// be called with * for every row, or with DISTINCT to see each value once.
+// With Over, the call is computed over a window of rows for each row.
+type CallExpression struct {
+	Token     *token.Token
+	Name      string
+	Arguments []Expression
+	Distinct  bool
+	Star      bool
+
+	Over *WindowDefinition
+}
+
+func (ce *CallExpression) expressionNode() {}
+func (ce *CallExpression) Type() NodeType  { return CALL_EXPRESSION }
+func (ce *CallExpression) String() string {
+	over := ""
+	if ce.Over != nil {
+		over = " OVER " + ce.Over.String()
+	}
+
+	if ce.Star {
+		return ce.Name + "(*)" + over
+	}
+
+	args := []string{}
+	for _, arg := range ce.Arguments {
+		args = append(args, arg.String())
+	}
+
+	distinct := ""
+	if ce.Distinct {
+		distinct = "DISTINCT "
+	}
+	return fmt.Sprintf("%s(%s%s)%s", ce.Name, distinct, strings.Join(args, ", "), over)
+}
+
+// WindowDefinition is the OVER clause of a window function call. The rows
+// are split into partitions with the same PartitionBy values, and ordered
+// by OrderBy within them. Functions of the values of many rows, such as
+// aggregates, read the rows of the frame of each row.
+type WindowDefinition struct {
+	PartitionBy []Expression
+	OrderBy     []*OrderingTerm
+	// Frame defaults to the rows up to the last one ordered with the
+	// current row, or to the whole partition without ORDER BY
+	Frame *WindowFrame
+}
+
+func (wd *WindowDefinition) String() string {
+	clauses := []string{}
+	if len(wd.PartitionBy) != 0 {
+		exprs := []string{}
+		for _, expr := range wd.PartitionBy {
+			exprs = append(exprs, expr.String())
+		}
+		clauses = append(clauses, "PARTITION BY "+strings.Join(exprs, ", "))
+	}
+
+	if len(wd.OrderBy) != 0 {
+		terms := []string{}
+		for _, term := range wd.OrderBy {
+			terms = append(terms, term.String())
+		}
+		clauses = append(clauses, "ORDER BY "+strings.Join(terms, ", "))
+	}
+
+	if wd.Frame != nil {
+		clauses = append(clauses, wd.Frame.String())
+	}
+	return fmt.Sprintf("(%s)", strings.Join(clauses, " "))
+}
+
+type FrameUnit string
+
+const (
+	// ROWS_FRAME bounds count rows
+	ROWS_FRAME FrameUnit = "ROWS"
+	// RANGE_FRAME bounds take in the rows ordered with the current row
+	RANGE_FRAME FrameUnit = "RANGE"
+)
+
+type FrameBoundKind string
+
+const (
+	UNBOUNDED_PRECEDING FrameBoundKind = "UNBOUNDED PRECEDING"
+	OFFSET_PRECEDING    FrameBoundKind = "PRECEDING"
+	CURRENT_ROW         FrameBoundKind = "CURRENT ROW"
+	OFFSET_FOLLOWING    FrameBoundKind = "FOLLOWING"
+	UNBOUNDED_FOLLOWING FrameBoundKind = "UNBOUNDED FOLLOWING"
+)
+
+// WindowFrame is the set of rows of a partition between Start and End,
+// relative to the current row.
+type WindowFrame struct {
+	Unit  FrameUnit
+	Start *FrameBound
+	End   *FrameBound
+}
+
+func (wf *WindowFrame) String() string {
+	return fmt.Sprintf("%s BETWEEN %s AND %s", wf.Unit, wf.Start.String(), wf.End.String())
+}
+
+// FrameBound is a bound of a window frame. Offset is the number of rows
+// an OFFSET_PRECEDING or OFFSET_FOLLOWING bound is away from the current
+// row.
+type FrameBound struct {
+	Kind   FrameBoundKind
+	Offset Expression
+}
+
+func (fb *FrameBound) String() string {
+	if fb.Offset != nil {
+		return fmt.Sprintf("%s %s", fb.Offset.String(), fb.Kind)
+	}
+	return string(fb.Kind)
+}
+
+// SubqueryExpression is a SELECT nested in an expression. Its value is the
+// single column of the single row it returns, or NULL if it returns none.
+// The nested statement can refer to the columns of the ones around it.
+type SubqueryExpression struct {
+	Token  *token.Token
+	Select *SelectStatement
+}
+
+func (se *SubqueryExpression) expressionNode() {}
+func (se *SubqueryExpression) Type() NodeType  { return SUBQUERY_EXPRESSION }
+func (se *SubqueryExpression) String() string {
+	return fmt.Sprintf("(%s)", se.Select.String())
+}
+
+// ExistsExpression is EXISTS (Subquery), or NOT EXISTS (Subquery) when Not
+// is set.
+type ExistsExpression struct {
+	Token    *token.Token
+	Not      bool
+	Subquery *SubqueryExpression
+}
+
+func (ee *ExistsExpression) expressionNode() {}
+func (ee *ExistsExpression) Type() NodeType  { return EXISTS_EXPRESSION }
+func (ee *ExistsExpression) String() string {
+	if ee.Not {
+		return "NOT EXISTS " + ee.Subquery.String()
+	}
+	return "EXISTS " + ee.Subquery.String()
 }
@@ -0,0 +1,104 @@
+package ast
+
+import "strings"
+
+// Equal reports whether a and b are the same expression: nodes of the same
+// kind, with the same operators, names and values, made of expressions that
+// are equal too. Subqueries are only equal to themselves.
+func Equal(a Expression, b Expression) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if !sameNode(a, b) {
+		return false
+	}
+
+	aChildren, bChildren := children(a), children(b)
+	if len(aChildren) != len(bChildren) {
+		return false
+	}
+	for i := range aChildren {
+		if !Equal(aChildren[i], bChildren[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameNode reports whether a and b are nodes of the same kind that only
+// differ, if at all, in the expressions they're made of.
+func sameNode(a Expression, b Expression) bool {
+	switch a := a.(type) {
+	case *IntegerLiteral:
+		b, ok := b.(*IntegerLiteral)
+		return ok && a.Value == b.Value
+	case *FloatLiteral:
+		b, ok := b.(*FloatLiteral)
+		return ok && a.Value == b.Value
+	case *StringLiteral:
+		b, ok := b.(*StringLiteral)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+	case *Identifier:
+		b, ok := b.(*Identifier)
+		return ok && a.Value == b.Value && (a.Table == nil) == (b.Table == nil) && (a.Table == nil || a.Table.Literal == b.Table.Literal)
+	case *PrefixExpression:
+		b, ok := b.(*PrefixExpression)
+		return ok && strings.EqualFold(a.Operator, b.Operator)
+	case *InfixExpression:
+		b, ok := b.(*InfixExpression)
+		return ok && strings.EqualFold(a.Operator, b.Operator)
+	case *InExpression:
+		b, ok := b.(*InExpression)
+		return ok && a.Not == b.Not && a.Subquery == b.Subquery
+	case *IsExpression:
+		b, ok := b.(*IsExpression)
+		return ok && a.Not == b.Not
+	case *PatternExpression:
+		b, ok := b.(*PatternExpression)
+		return ok && a.Not == b.Not && strings.EqualFold(a.Operator, b.Operator)
+	case *BetweenExpression:
+		b, ok := b.(*BetweenExpression)
+		return ok && a.Not == b.Not
+	case *CaseExpression:
+		// The WHEN clauses are compared as children
+		b, ok := b.(*CaseExpression)
+		return ok && len(a.Whens) == len(b.Whens)
+	case *CallExpression:
+		b, ok := b.(*CallExpression)
+		return ok && strings.EqualFold(a.Name, b.Name) && a.Distinct == b.Distinct && a.Star == b.Star && sameWindow(a.Over, b.Over)
+	case *SubqueryExpression, *ExistsExpression:
+		return a == b
+	}
+	return false
+}
+
+// sameWindow reports whether two window definitions are the same, but for
+// the PARTITION BY and ORDER BY expressions, which are children of calls.
+func sameWindow(a *WindowDefinition, b *WindowDefinition) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if len(a.PartitionBy) != len(b.PartitionBy) || len(a.OrderBy) != len(b.OrderBy) {
+		return false
+	}
+	for i, term := range a.OrderBy {
+		if term.Descending != b.OrderBy[i].Descending || term.Nulls != b.OrderBy[i].Nulls {
+			return false
+		}
+	}
+
+	if a.Frame == nil || b.Frame == nil {
+		return a.Frame == nil && b.Frame == nil
+	}
+	return a.Frame.Unit == b.Frame.Unit && sameBound(a.Frame.Start, b.Frame.Start) && sameBound(a.Frame.End, b.Frame.End)
+}
+
+func sameBound(a *FrameBound, b *FrameBound) bool {
+	return a.Kind == b.Kind && Equal(a.Offset, b.Offset)
+}
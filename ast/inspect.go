@@ -0,0 +1,49 @@
+package ast
+
+// Inspect calls fn for expr and then, for as long as fn returns true, for
+// the expressions expr is made of, depth first. The expressions of
+// subqueries belong to the statements nested in expr, and aren't visited.
+func Inspect(expr Expression, fn func(Expression) bool) {
+	if expr == nil || !fn(expr) {
+		return
+	}
+
+	for _, child := range children(expr) {
+		Inspect(child, fn)
+	}
+}
+
+// children returns the expressions expr is made of, in the order they're
+// written. Optional parts that are missing are nil.
+func children(expr Expression) []Expression {
+	var children []Expression
+	switch node := expr.(type) {
+	case *PrefixExpression:
+		children = []Expression{node.Right}
+	case *InfixExpression:
+		children = []Expression{node.Left, node.Right}
+	case *InExpression:
+		children = append([]Expression{node.Left}, node.Values...)
+	case *IsExpression:
+		children = []Expression{node.Left}
+	case *PatternExpression:
+		children = []Expression{node.Left, node.Pattern, node.Escape}
+	case *BetweenExpression:
+		children = []Expression{node.Left, node.Low, node.High}
+	case *CaseExpression:
+		children = []Expression{node.Operand}
+		for _, when := range node.Whens {
+			children = append(children, when.Condition, when.Result)
+		}
+		children = append(children, node.Else)
+	case *CallExpression:
+		children = node.Arguments
+		if node.Over != nil {
+			children = append(append([]Expression{}, children...), node.Over.PartitionBy...)
+			for _, term := range node.Over.OrderBy {
+				children = append(children, term.Expression)
+			}
+		}
+	}
+	return children
+}
@@ -1,10 +1,25 @@
 package main
 
 import (
+	"fmt"
+	"jnafolayan/sql-db/engine"
 	"jnafolayan/sql-db/repl"
 	"os"
 )
 
 func main() {
-	repl.Start(os.Stdin, os.Stdout)
+	if len(os.Args) < 2 {
+		repl.Start(os.Stdin, os.Stdout)
+		return
+	}
+
+	// sqlit <database file>
+	backend, err := engine.NewFileBackend(os.Args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening database: %s\n", err)
+		os.Exit(1)
+	}
+	defer backend.Close()
+
+	repl.Run(os.Stdin, os.Stdout, backend)
 }
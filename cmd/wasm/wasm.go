@@ -11,10 +11,11 @@ import (
 	"time"
 )
 
-var tables engine.MemoryTables
+// backend is shared between runs so a transaction can span several of them
+var backend *engine.MemoryBackend
 
 func init() {
-	tables = engine.NewMemoryBackendTables()
+	backend = engine.NewMemoryBackend(nil)
 }
 
 //export logText
@@ -60,52 +61,68 @@ func parse(input string) (*ast.Program, error) {
 func execute(program *ast.Program) string {
 	var result strings.Builder
 
-	backend := engine.NewMemoryBackend(tables)
 	end := len(program.Statements) - 1
 
+	// Run the whole program atomically unless it manages transactions itself
+	implicit := end >= 0 && !backend.InTransaction() && !program.HasTransactionStatements()
+	if implicit {
+		if err := backend.Begin(); err != nil {
+			result.WriteString(fmt.Errorf("program error: %s\n", err).Error())
+			return result.String()
+		}
+	}
+
 	startTime := time.Now()
 loop:
 	for i, stmt := range program.Statements {
+		var err error
 		switch st := stmt.(type) {
 		case *ast.CreateTableStatement:
-			err := backend.CreateTable(st)
-			if err != nil {
-				result.WriteString(fmt.Errorf("program error: %s\n", err).Error())
-				break loop
-			}
+			err = backend.CreateTable(st)
 		case *ast.InsertStatement:
-			err := backend.Insert(st)
-			if err != nil {
-				result.WriteString(fmt.Errorf("program error: %s\n", err).Error())
-				break loop
-			}
+			err = backend.Insert(st)
+		case *ast.CreateIndexStatement:
+			err = backend.CreateIndex(st)
+		case *ast.DropIndexStatement:
+			err = backend.DropIndex(st)
 		case *ast.SelectStatement:
-			res, err := backend.Select(st)
-			if err != nil {
-				result.WriteString(fmt.Errorf("program error: %s\n", err).Error())
-				break loop
-			} else if i == end {
+			var res *engine.FetchResult
+			res, err = backend.Select(st)
+			if err == nil && i == end {
 				result.WriteString(utils.FormatSelectResult(res))
 			}
 		case *ast.DeleteStatement:
-			res, err := backend.Delete(st)
-			if err != nil {
-				result.WriteString(fmt.Errorf("program error: %s\n", err).Error())
-				break loop
-			} else if i == end {
-				// Print only if result is not empty
+			var res *engine.UpdateResult
+			res, err = backend.Delete(st)
+			if err == nil && i == end {
 				result.WriteString(fmt.Sprintf("affected rows: %d\n", res.AffectedRows))
 			}
 		case *ast.UpdateStatement:
-			res, err := backend.Update(st)
-			if err != nil {
-				result.WriteString(fmt.Errorf("program error: %s\n", err).Error())
-				break loop
-			} else if i == end {
-				// Print only if result is not empty
+			var res *engine.UpdateResult
+			res, err = backend.Update(st)
+			if err == nil && i == end {
 				result.WriteString(fmt.Sprintf("affected rows: %d\n", res.AffectedRows))
 			}
+		case *ast.BeginStatement:
+			err = backend.Begin()
+		case *ast.CommitStatement:
+			err = backend.Commit()
+		case *ast.RollbackStatement:
+			err = backend.Rollback()
+		}
+
+		if err == nil && i == end && implicit {
+			err = backend.Commit()
+		}
 
+		if err != nil {
+			result.WriteString(fmt.Errorf("program error: %s\n", err).Error())
+			// A failed statement aborts the transaction it ran in
+			if backend.InTransaction() {
+				backend.Rollback()
+				result.WriteString("transaction rolled back\n")
+			}
+			break loop
 		}
 
 		if i == end {
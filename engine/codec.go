@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var errCorruptData = errors.New("corrupt data")
+
+// encoder appends length-prefixed values to a byte buffer. It is shared by
+// the page and log formats.
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) putUvarint(v uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], v)
+	e.buf.Write(scratch[:n])
+}
+
+func (e *encoder) putUint32(v uint32) {
+	var scratch [4]byte
+	binary.BigEndian.PutUint32(scratch[:], v)
+	e.buf.Write(scratch[:])
+}
+
+func (e *encoder) putByte(b byte) {
+	e.buf.WriteByte(b)
+}
+
+func (e *encoder) putBytes(b []byte) {
+	e.putUvarint(uint64(len(b)))
+	e.buf.Write(b)
+}
+
+func (e *encoder) putString(s string) {
+	e.putBytes([]byte(s))
+}
+
+// putCell writes a cell along with a flag that tells a nil (NULL) cell
+// apart from an empty one.
+func (e *encoder) putCell(c memoryCell) {
+	if c == nil {
+		e.putByte(0)
+		return
+	}
+	e.putByte(1)
+	e.putBytes(c)
+}
+
+func (e *encoder) Bytes() []byte {
+	return e.buf.Bytes()
+}
+
+// decoder reads values written by an encoder. The first error is sticky so
+// callers can check it once after decoding a whole structure.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func newDecoder(data []byte) *decoder {
+	return &decoder{data: data}
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = errCorruptData
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) uint32() uint32 {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.data) < 4 {
+		d.err = errCorruptData
+		return 0
+	}
+	v := binary.BigEndian.Uint32(d.data)
+	d.data = d.data[4:]
+	return v
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	if len(d.data) < 1 {
+		d.err = errCorruptData
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.uvarint()
+	if d.err != nil {
+		return nil
+	}
+	if uint64(len(d.data)) < n {
+		d.err = errCorruptData
+		return nil
+	}
+	b := make([]byte, n)
+	copy(b, d.data[:n])
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) cell() memoryCell {
+	if d.byte() == 0 {
+		return nil
+	}
+	return memoryCell(d.bytes())
+}
@@ -0,0 +1,229 @@
+package engine
+
+import (
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
+	"jnafolayan/sql-db/parser"
+)
+
+// compoundSelects returns the queries of a compound SELECT, the first of
+// them without the clauses that apply to the combined rows.
+func compoundSelects(stmt *ast.SelectStatement) []*ast.SelectStatement {
+	first := *stmt
+	first.With = nil
+	first.Compound = nil
+	first.OrderBy = nil
+	first.Limit = nil
+	first.Offset = nil
+
+	selects := []*ast.SelectStatement{&first}
+	for _, term := range stmt.Compound {
+		selects = append(selects, term.Select)
+	}
+	return selects
+}
+
+// compoundColumns returns the columns of the combined result of queries
+// with the given columns: those of the first query, with types that hold
+// the values of all of them.
+func compoundColumns(results [][]*ResultColumn) ([]*ResultColumn, error) {
+	columns := []*ResultColumn{}
+	for _, col := range results[0] {
+		columns = append(columns, &ResultColumn{Type: col.Type, Name: col.Name})
+	}
+
+	for _, res := range results[1:] {
+		// The parser can't count the columns of a star
+		if len(res) != len(columns) {
+			return nil, parser.ErrCompoundColumns
+		}
+		for i, col := range res {
+			columns[i].Type = widerType(columns[i].Type, col.Type)
+		}
+	}
+	return columns, nil
+}
+
+// compound runs a compound SELECT. The rows of its queries are combined,
+// then ordered and limited as a whole.
+func (c *queryContext) compound(stmt *ast.SelectStatement) (*FetchResult, error) {
+	limit, offset, err := limits(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*FetchResult{}
+	described := [][]*ResultColumn{}
+	for _, sel := range compoundSelects(stmt) {
+		res, err := c.withContext(c.with).query(sel)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, res)
+		described = append(described, res.Columns)
+	}
+
+	columns, err := compoundColumns(described)
+	if err != nil {
+		return nil, err
+	}
+	types := []ColumnType{}
+	for _, col := range columns {
+		types = append(types, col.Type)
+	}
+
+	sets := [][][]memoryCell{}
+	for _, res := range results {
+		rows := [][]memoryCell{}
+		for _, row := range res.Rows {
+			rows = append(rows, memoryRowCells(row))
+		}
+		rows, err = convertRows(rows, res.Columns, types)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, rows)
+	}
+
+	// INTERSECT is done first, then the rest from left to right
+	operands := [][][]memoryCell{sets[0]}
+	operators := []*ast.CompoundSelect{}
+	for i, term := range stmt.Compound {
+		if term.Operator == ast.INTERSECT {
+			last := len(operands) - 1
+			operands[last] = combineRows(term, operands[last], sets[i+1], types)
+			continue
+		}
+		operands = append(operands, sets[i+1])
+		operators = append(operators, term)
+	}
+
+	rows := operands[0]
+	for i, term := range operators {
+		rows = combineRows(term, rows, operands[i+1], types)
+	}
+
+	// ORDER BY refers to the columns of the combined rows
+	relColumns := []*relationColumn{}
+	projections := []*projection{}
+	for i, col := range columns {
+		relColumns = append(relColumns, &relationColumn{name: col.Name, columnType: col.Type})
+		projections = append(projections, &projection{column: col, colIdx: i})
+	}
+	rel := newRelation(relColumns)
+	rel.ctx = c
+
+	keys, err := rel.orderKeys(&ast.SelectStatement{OrderBy: stmt.OrderBy}, projections)
+	if err != nil {
+		return nil, err
+	}
+
+	computed := false
+	for _, key := range keys {
+		computed = computed || key.column < 0
+	}
+
+	sorter := newRowSorter(keys, limit, offset)
+	scope := c.newScope(rel)
+	for _, row := range rows {
+		if sorter.full() {
+			break
+		}
+
+		cells := []Cell{}
+		for _, cell := range row {
+			cells = append(cells, cell)
+		}
+
+		if computed {
+			rel.bind(scope, row)
+		}
+
+		values := []evaluator.Value{}
+		for _, key := range keys {
+			if key.column >= 0 {
+				values = append(values, row[key.column].Value(types[key.column]))
+				continue
+			}
+
+			value, err := evaluator.EvalExpression(key.term.Expression, scope)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+
+		if err := sorter.add(cells, values, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	resultRows, err := sorter.result()
+	if err != nil {
+		return nil, err
+	}
+
+	return &FetchResult{
+		Rows:    resultRows,
+		Columns: columns,
+	}, nil
+}
+
+// combineRows returns the rows of term combined with the rows before it.
+// Rows are duplicates when their cells hold the same bytes. With ALL, a
+// row of right takes the place of one duplicate of it in left.
+func combineRows(term *ast.CompoundSelect, left [][]memoryCell, right [][]memoryCell, types []ColumnType) [][]memoryCell {
+	if term.Operator == ast.UNION {
+		rows := append(append([][]memoryCell{}, left...), right...)
+		if term.All {
+			return rows
+		}
+
+		unique := [][]memoryCell{}
+		seen := map[string]bool{}
+		for _, row := range rows {
+			key := rowKey(row, types)
+			if !seen[key] {
+				seen[key] = true
+				unique = append(unique, row)
+			}
+		}
+		return unique
+	}
+
+	counts := map[string]int{}
+	for _, row := range right {
+		counts[rowKey(row, types)]++
+	}
+
+	rows := [][]memoryCell{}
+	seen := map[string]bool{}
+	for _, row := range left {
+		key := rowKey(row, types)
+		if !term.All {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+
+		found := counts[key] > 0
+		if found && term.All {
+			counts[key]--
+		}
+		if found == (term.Operator == ast.INTERSECT) {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// rowKey returns the bytes of the cells of a row, with columns of the
+// given types, which equal rows share.
+func rowKey(row []memoryCell, types []ColumnType) string {
+	key := []byte{}
+	for i, cell := range row {
+		key = appendKeyCell(key, types[i], cell)
+	}
+	return string(key)
+}
@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"errors"
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/parser"
+	"strings"
+	"testing"
+)
+
+func TestCompoundSelects(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (id INT, name TEXT, score FLOAT)")
+	execStatement(t, mb, "CREATE TABLE staff (id INT, name TEXT)")
+	execStatement(t, mb, "INSERT INTO people (id, name, score) VALUES (1, 'John', 1.5)")
+	execStatement(t, mb, "INSERT INTO people (id, name, score) VALUES (2, 'Julia', 2)")
+	execStatement(t, mb, "INSERT INTO people (id, name) VALUES (3, 'Jake')")
+	execStatement(t, mb, "INSERT INTO people (id, name) VALUES (3, 'Jake')")
+	execStatement(t, mb, "INSERT INTO people (id) VALUES (4)")
+	execStatement(t, mb, "INSERT INTO staff (id, name) VALUES (3, 'Jake')")
+	execStatement(t, mb, "INSERT INTO staff (id, name) VALUES (5, 'Jane')")
+	execStatement(t, mb, "INSERT INTO staff (id) VALUES (4)")
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"SELECT id, name FROM people UNION SELECT id, name FROM staff", []string{"1,John", "2,Julia", "3,Jake", "4,NULL", "5,Jane"}},
+		{"SELECT id FROM people WHERE id > 2 UNION ALL SELECT id FROM staff", []string{"3", "3", "4", "3", "5", "4"}},
+		// NULLs are duplicates of each other
+		{"SELECT id, name FROM people INTERSECT SELECT * FROM staff", []string{"3,Jake", "4,NULL"}},
+		{"SELECT id, name FROM people EXCEPT SELECT * FROM staff", []string{"1,John", "2,Julia"}},
+		{"SELECT id FROM people INTERSECT ALL SELECT id FROM staff", []string{"3", "4"}},
+		{"SELECT id FROM people EXCEPT ALL SELECT id FROM staff", []string{"1", "2", "3"}},
+		{"SELECT id FROM people INTERSECT ALL SELECT id FROM people WHERE id = 3", []string{"3", "3"}},
+		// INTERSECT is done before UNION and EXCEPT
+		{"SELECT id FROM staff UNION SELECT id FROM people INTERSECT SELECT id FROM people WHERE id < 2", []string{"3", "5", "4", "1"}},
+		{"SELECT id FROM people EXCEPT SELECT id FROM staff EXCEPT SELECT id FROM people WHERE id = 1", []string{"2"}},
+
+		// ORDER BY and LIMIT apply to the combined rows
+		{"SELECT id, name FROM people UNION SELECT id, name FROM staff ORDER BY name DESC NULLS LAST LIMIT 3", []string{"2,Julia", "1,John", "5,Jane"}},
+		{"SELECT name FROM people WHERE id < 3 UNION SELECT name FROM staff ORDER BY 1 LIMIT 2 OFFSET 1", []string{"Jake", "Jane"}},
+		{"SELECT id FROM people UNION SELECT id FROM staff ORDER BY id % 2, id", []string{"2", "4", "1", "3", "5"}},
+		{"SELECT id AS n FROM people UNION SELECT id FROM staff ORDER BY n DESC LIMIT 2", []string{"5", "4"}},
+		{"SELECT DISTINCT id FROM people WHERE id < 3 UNION ALL SELECT COUNT(*) FROM staff GROUP BY name", []string{"1", "2", "1", "1", "1"}},
+
+		// Columns hold the values of every query
+		{"SELECT id FROM people WHERE id = 1 UNION SELECT score FROM people WHERE id < 3", []string{"1.000000", "1.500000", "2.000000"}},
+		{"SELECT id FROM staff WHERE id = 5 UNION ALL SELECT name FROM staff WHERE id = 5", []string{"5", "Jane"}},
+
+		{"SELECT name FROM people WHERE id IN (SELECT id FROM people EXCEPT SELECT id FROM staff)", []string{"John", "Julia"}},
+		{
+			"WITH both AS (SELECT id FROM people INTERSECT SELECT id FROM staff) SELECT name FROM staff WHERE id IN (SELECT id FROM both) ORDER BY name",
+			[]string{"NULL", "Jake"},
+		},
+		// The recursive query of a table comes after its last UNION
+		{
+			"WITH RECURSIVE n (x) AS (SELECT id FROM staff WHERE id = 5 UNION SELECT id FROM people WHERE id = 1 UNION ALL SELECT x + 10 FROM n WHERE x < 20) SELECT x FROM n",
+			[]string{"5", "1", "15", "11", "25", "21"},
+		},
+	}
+
+	for _, tt := range tests {
+		rows := orderedRows(t, mb, tt.input)
+		if strings.Join(rows, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%s: expected %v, got %v", tt.input, tt.expected, rows)
+		}
+	}
+
+	// Columns are named after those of the first query
+	res := execStatement(t, mb, "SELECT id AS n, name FROM people UNION SELECT score, id FROM people").(*FetchResult)
+	if res.Columns[0].Name != "n" || res.Columns[0].Type != FLOAT_COLUMN || res.Columns[1].Name != "name" || res.Columns[1].Type != TEXT_COLUMN {
+		t.Errorf("expected columns n FLOAT and name TEXT, got %s %s and %s %s", res.Columns[0].Name, res.Columns[0].Type, res.Columns[1].Name, res.Columns[1].Type)
+	}
+
+	for _, tt := range []struct {
+		input    string
+		expected error
+	}{
+		{"SELECT * FROM people UNION SELECT * FROM staff", parser.ErrCompoundColumns},
+		{"SELECT id FROM people WHERE id IN (SELECT * FROM people INTERSECT SELECT * FROM staff)", parser.ErrCompoundColumns},
+		{"SELECT id FROM people UNION SELECT id FROM staff ORDER BY 2", ErrColumnNotFound},
+		{"SELECT id FROM people UNION SELECT id FROM staff ORDER BY name", ErrColumnNotFound},
+		{"SELECT id FROM people UNION SELECT id FROM staff LIMIT -1", ErrInvalidLimit},
+		{"SELECT id FROM people UNION SELECT id FROM animals", ErrTableNotFound},
+	} {
+		_, err := mb.Select(parseStatement(t, tt.input).(*ast.SelectStatement))
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %q error, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
+	"strings"
+)
+
+// checkConstraints returns an error if giving row the cells would break
+// one of the constraints of t. row is nil for rows that are being
+// inserted.
+func (t *memoryTable) checkConstraints(tx *transaction, row *memoryRow, cells []memoryCell) error {
+	for i, col := range t.columns {
+		if col.notNull && cells[i] == nil {
+			return ErrNotNullViolation
+		}
+	}
+
+	if len(t.checks) != 0 {
+		scope := rowScope(cells, t.columns, generateColNameToIndexMap(t.columns))
+		for _, check := range t.checks {
+			result, err := evaluator.EvalExpression(check.expr, scope)
+			if err != nil {
+				return err
+			}
+
+			// Checks whose result is unknown because of missing values pass
+			if result.Type() != evaluator.NULL_VALUE && !evaluator.Truthy(result) {
+				return fmt.Errorf("%w: %s", ErrCheckViolation, check.name)
+			}
+		}
+	}
+
+	return t.checkUnique(tx, row, cells)
+}
+
+// checkConstraint is a CHECK constraint. Its source is what gets persisted
+// and parsed again when the database is read.
+type checkConstraint struct {
+	name   string
+	source string
+	expr   ast.Expression
+}
+
+// addCheck adds a CHECK constraint to t. Unless it was named, its name is
+// made of parts joined by underscores, with a number appended if another
+// check of t already has it.
+func (t *memoryTable) addCheck(check *ast.CheckConstraint, parts ...string) error {
+	if err := t.checkReferences(check.Expression); err != nil {
+		return err
+	}
+
+	// Evaluating the expression on a row of missing values finds operations
+	// that can't be done whatever the row
+	nulls := make([]memoryCell, len(t.columns))
+	scope := rowScope(nulls, t.columns, generateColNameToIndexMap(t.columns))
+	if _, err := evaluator.EvalExpression(check.Expression, scope); err != nil {
+		return err
+	}
+
+	name := strings.Join(parts, "_")
+	if check.Name != nil {
+		name = check.Name.Literal
+	} else {
+		base := name
+		for i := 1; t.findCheck(name) != nil; i++ {
+			name = fmt.Sprintf("%s%d", base, i)
+		}
+	}
+
+	t.checks = append(t.checks, &checkConstraint{
+		name:   name,
+		source: check.Source,
+		expr:   check.Expression,
+	})
+	return nil
+}
+
+// checkReferences returns an error if expr, the expression of a CHECK
+// constraint of t, reads anything but the columns of the row it checks.
+// Every branch is searched, whether or not it would be taken.
+func (t *memoryTable) checkReferences(expr ast.Expression) error {
+	colNameToIdx := generateColNameToIndexMap(t.columns)
+
+	var err error
+	ast.Inspect(expr, func(e ast.Expression) bool {
+		switch node := e.(type) {
+		case *ast.Identifier:
+			// Rows are bound by the plain names of their columns
+			if _, ok := colNameToIdx[node.Value]; !ok || node.Table != nil {
+				err = ErrColumnNotFound
+			}
+		case *ast.SubqueryExpression, *ast.ExistsExpression:
+			err = evaluator.ErrNoQuerier
+		case *ast.InExpression:
+			if node.Subquery != nil {
+				err = evaluator.ErrNoQuerier
+			}
+		}
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := noAggregates(expr); err != nil {
+		return err
+	}
+	return noWindows(expr)
+}
+
+func (t *memoryTable) findCheck(name string) *checkConstraint {
+	for _, check := range t.checks {
+		if check.name == name {
+			return check
+		}
+	}
+	return nil
+}
+
+// indexName returns an unused name for an index created by a constraint,
+// made of parts joined by underscores.
+func (mb *MemoryBackend) indexName(parts ...string) string {
+	base := strings.Join(parts, "_")
+	name := base
+	for i := 1; ; i++ {
+		if _, _, idx := mb.findIndex(name); idx == nil {
+			return name
+		}
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+}
+
+type referentialAction byte
+
+const (
+	restrictAction referentialAction = iota
+	cascadeAction
+	setNullAction
+)
+
+// foreignKey requires the values of columns, unless one of them is NULL,
+// to be found in parentColumns of a row of the parent table. onDelete says
+// what happens to the row when that parent row is deleted.
+type foreignKey struct {
+	columns       []int
+	parent        string
+	parentColumns []int
+	onDelete      referentialAction
+}
+
+// foreignKey resolves a REFERENCES clause for columns of t. The referenced
+// columns must be covered by a unique index of the parent table.
+func (mb *MemoryBackend) foreignKey(tx *transaction, t *memoryTable, columns []int, ref *ast.ForeignKey) (*foreignKey, error) {
+	parent := mb.table(tx, ref.Table.Literal)
+	if parent == nil {
+		return nil, ErrTableNotFound
+	}
+
+	fk := &foreignKey{columns: columns, parent: ref.Table.Literal}
+	switch ref.OnDelete {
+	case "CASCADE":
+		fk.onDelete = cascadeAction
+	case "SET NULL":
+		fk.onDelete = setNullAction
+	}
+
+	if len(ref.Columns) == 0 {
+		// The primary key is referenced by default
+		for _, idx := range parent.indexes {
+			if idx.primary {
+				fk.parentColumns = idx.columns
+			}
+		}
+	}
+
+	colNameToIdx := generateColNameToIndexMap(parent.columns)
+	for _, col := range ref.Columns {
+		colIdx, ok := colNameToIdx[col.Literal]
+		if !ok {
+			return nil, ErrColumnNotFound
+		}
+		fk.parentColumns = append(fk.parentColumns, colIdx)
+	}
+
+	if len(fk.parentColumns) == 0 || parent.uniqueIndex(fk.parentColumns) == nil {
+		return nil, ErrReferencedKeyNotUnique
+	}
+
+	if len(fk.parentColumns) != len(columns) {
+		return nil, ErrForeignKeyMismatch
+	}
+	for i, col := range columns {
+		if t.columns[col].columnType != parent.columns[fk.parentColumns[i]].columnType {
+			return nil, ErrForeignKeyMismatch
+		}
+	}
+
+	return fk, nil
+}
+
+// values returns the cells of the foreign key's columns, or nil if one of
+// them is NULL.
+func (fk *foreignKey) values(cells []memoryCell) []memoryCell {
+	return pickCells(cells, fk.columns)
+}
+
+// pickCells returns the cells at positions, or nil if one of them is NULL.
+func pickCells(cells []memoryCell, positions []int) []memoryCell {
+	values := []memoryCell{}
+	for _, pos := range positions {
+		if cells[pos] == nil {
+			return nil
+		}
+		values = append(values, cells[pos])
+	}
+	return values
+}
+
+func cellsEqual(a, b []memoryCell) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if (a[i] == nil) != (b[i] == nil) || !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// uniqueIndex returns a unique index of t over the given set of columns.
+func (t *memoryTable) uniqueIndex(columns []int) *tableIndex {
+	for _, idx := range t.indexes {
+		if !idx.unique || len(idx.columns) != len(columns) {
+			continue
+		}
+
+		covered := true
+		for _, col := range columns {
+			found := false
+			for _, c := range idx.columns {
+				found = found || c == col
+			}
+			covered = covered && found
+		}
+
+		if covered {
+			return idx
+		}
+	}
+	return nil
+}
+
+// findRow returns the row of t whose columns hold values in the version
+// visible to tx, along with that version.
+func (t *memoryTable) findRow(tx *transaction, columns []int, values []memoryCell) (*memoryRow, *rowVersion) {
+	var found *memoryRow
+	var version *rowVersion
+	match := func(row *memoryRow) bool {
+		if v := tx.version(row); v != nil && cellsEqual(pickCells(v.cells, columns), values) {
+			found, version = row, v
+			return false
+		}
+		return true
+	}
+
+	if idx := t.uniqueIndex(columns); idx != nil {
+		cells := make([]memoryCell, len(t.columns))
+		for i, col := range columns {
+			cells[col] = values[i]
+		}
+		idx.lookup(idx.key(t, cells), match)
+		return found, version
+	}
+
+	for _, row := range t.rows {
+		if !match(row) {
+			break
+		}
+	}
+	return found, version
+}
+
+// referencingRows returns the rows of t whose foreign key fk refers to the
+// parent row holding parentCells.
+func (t *memoryTable) referencingRows(tx *transaction, fk *foreignKey, parentCells []memoryCell) ([]*memoryRow, error) {
+	values := pickCells(parentCells, fk.parentColumns)
+	if values == nil {
+		return nil, nil
+	}
+
+	rows := []*memoryRow{}
+	for _, row := range t.rows {
+		v := tx.version(row)
+		if v == nil {
+			// A reference the snapshot can't see, written by a transaction
+			// that's running or has committed since
+			if v := row.version; v != nil && v.xmax == 0 && cellsEqual(fk.values(v.cells), values) {
+				return nil, ErrSerializationFailure
+			}
+			continue
+		}
+
+		if cellsEqual(fk.values(v.cells), values) {
+			if v.xmax != 0 {
+				return nil, ErrSerializationFailure
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+type reference struct {
+	table string
+	child *memoryTable
+	fk    *foreignKey
+}
+
+// references returns the foreign keys visible to tx that refer to the
+// table called parent.
+func (mb *MemoryBackend) references(tx *transaction, parent string) []*reference {
+	refs := []*reference{}
+	for name, t := range mb.tables {
+		if !tx.visible(t.createdBy) {
+			continue
+		}
+
+		for _, fk := range t.foreignKeys {
+			if fk.parent == parent {
+				refs = append(refs, &reference{table: name, child: t, fk: fk})
+			}
+		}
+	}
+	return refs
+}
+
+// checkReferences returns an error if the foreign keys of a row of t
+// holding cells refer to rows that don't exist. old holds the cells the
+// row had before, if any; references that haven't changed aren't checked
+// again.
+func (mb *MemoryBackend) checkReferences(tx *transaction, t *memoryTable, old, cells []memoryCell) error {
+	for _, fk := range t.foreignKeys {
+		values := fk.values(cells)
+		if values == nil || (old != nil && cellsEqual(values, fk.values(old))) {
+			continue
+		}
+
+		parent := mb.table(tx, fk.parent)
+
+		// Rows may refer to themselves
+		if parent == t && cellsEqual(values, pickCells(cells, fk.parentColumns)) {
+			continue
+		}
+
+		row, v := parent.findRow(tx, fk.parentColumns, values)
+		if row == nil {
+			return ErrForeignKeyViolation
+		}
+		if v.xmax != 0 {
+			return ErrSerializationFailure
+		}
+	}
+	return nil
+}
+
+// insertRow adds a row to t after checking its constraints.
+func (mb *MemoryBackend) insertRow(tx *transaction, name string, t *memoryTable, cells []memoryCell) error {
+	if err := t.checkConstraints(tx, nil, cells); err != nil {
+		return err
+	}
+	if err := mb.checkReferences(tx, t, nil, cells); err != nil {
+		return err
+	}
+
+	tx.insert(name, t, cells)
+	return nil
+}
+
+// updateRow replaces the cells of row after checking the constraints of t.
+// Values that rows of other tables refer to can't be changed.
+func (mb *MemoryBackend) updateRow(tx *transaction, name string, t *memoryTable, row *memoryRow, cells []memoryCell) error {
+	v := tx.version(row)
+	if v.xmax != 0 {
+		return ErrSerializationFailure
+	}
+
+	if err := t.checkConstraints(tx, row, cells); err != nil {
+		return err
+	}
+	if err := mb.checkReferences(tx, t, v.cells, cells); err != nil {
+		return err
+	}
+
+	for _, ref := range mb.references(tx, name) {
+		if cellsEqual(pickCells(v.cells, ref.fk.parentColumns), pickCells(cells, ref.fk.parentColumns)) {
+			continue
+		}
+
+		rows, err := ref.child.referencingRows(tx, ref.fk, v.cells)
+		if err != nil {
+			return err
+		}
+		if len(rows) != 0 {
+			return ErrForeignKeyViolation
+		}
+	}
+
+	tx.update(name, t, row, cells)
+	return nil
+}
+
+// deleteRow deletes row and applies the ON DELETE action of every foreign
+// key referring to it. Rows that were already deleted are skipped.
+func (mb *MemoryBackend) deleteRow(tx *transaction, name string, t *memoryTable, row *memoryRow) error {
+	v := tx.version(row)
+	if v == nil {
+		return nil
+	}
+	if v.xmax != 0 {
+		return ErrSerializationFailure
+	}
+
+	tx.delete(name, t, row)
+
+	for _, ref := range mb.references(tx, name) {
+		rows, err := ref.child.referencingRows(tx, ref.fk, v.cells)
+		if err != nil {
+			return err
+		}
+
+		for _, child := range rows {
+			switch ref.fk.onDelete {
+			case cascadeAction:
+				err = mb.deleteRow(tx, ref.table, ref.child, child)
+			case setNullAction:
+				cv := tx.version(child)
+				if cv == nil {
+					continue
+				}
+
+				cells := make([]memoryCell, len(cv.cells))
+				copy(cells, cv.cells)
+				for _, col := range ref.fk.columns {
+					cells[col] = nil
+				}
+				err = mb.updateRow(tx, ref.table, ref.child, child, cells)
+			default:
+				err = ErrForeignKeyViolation
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
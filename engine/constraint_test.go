@@ -0,0 +1,327 @@
+package engine
+
+import (
+	"errors"
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConstraints(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (id INT PRIMARY KEY, email TEXT UNIQUE, name TEXT NOT NULL)")
+	execStatement(t, mb, "CREATE TABLE visits (person INT, day INT, note TEXT, PRIMARY KEY (person, day))")
+
+	tests := []struct {
+		input         string
+		expectedError error
+	}{
+		{"INSERT INTO people (id, email, name) VALUES (1, 'john@example.com', 'John')", nil},
+		{"INSERT INTO people (id, email, name) VALUES (1, 'julia@example.com', 'Julia')", ErrUniqueViolation},
+		{"INSERT INTO people (email, name) VALUES ('julia@example.com', 'Julia')", ErrNotNullViolation},
+		{"INSERT INTO people (id, email) VALUES (2, 'julia@example.com')", ErrNotNullViolation},
+		{"INSERT INTO people (id, email, name) VALUES (2, 'john@example.com', 'Julia')", ErrUniqueViolation},
+		{"INSERT INTO people (id, name) VALUES (2, 'Julia')", nil},
+		{"INSERT INTO people (id, name) VALUES (3, 'Jake')", nil},
+		{"UPDATE people SET id=1 WHERE id=2", ErrUniqueViolation},
+		{"UPDATE people SET email='john@example.com' WHERE id=3", ErrUniqueViolation},
+		{"UPDATE people SET email='jake@example.com' WHERE id=3", nil},
+
+		{"INSERT INTO visits (person, day) VALUES (1, 1)", nil},
+		{"INSERT INTO visits (person, day) VALUES (1, 2)", nil},
+		{"INSERT INTO visits (person, day) VALUES (2, 1)", nil},
+		{"INSERT INTO visits (person, day) VALUES (1, 1)", ErrUniqueViolation},
+		{"INSERT INTO visits (person, note) VALUES (1, 'no day')", ErrNotNullViolation},
+
+		{"DROP INDEX people_pkey", ErrIndexInUse},
+		{"DROP INDEX people_email_key", ErrIndexInUse},
+		{"CREATE TABLE pets (id INT PRIMARY KEY, name TEXT, PRIMARY KEY (name))", ErrMultiplePrimaryKeys},
+		{"CREATE TABLE pets (id INT, UNIQUE (owner))", ErrColumnNotFound},
+	}
+
+	for _, tt := range tests {
+		var err error
+		switch st := parseStatement(t, tt.input).(type) {
+		case *ast.CreateTableStatement:
+			err = mb.CreateTable(st)
+		case *ast.InsertStatement:
+			err = mb.Insert(st)
+		case *ast.UpdateStatement:
+			_, err = mb.Update(st)
+		case *ast.DropIndexStatement:
+			err = mb.DropIndex(st)
+		}
+
+		if err != tt.expectedError {
+			t.Errorf("%s: expected %v error, got %v", tt.input, tt.expectedError, err)
+		}
+	}
+
+	expectRowCount(t, mb, "SELECT id FROM people", 3)
+	expectRowCount(t, mb, "SELECT id FROM people WHERE email='jake@example.com'", 1)
+	expectRowCount(t, mb, "SELECT day FROM visits WHERE person=1", 2)
+}
+
+func TestConstraintPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	fb, err := NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("error opening database: %s", err)
+	}
+	execStatement(t, fb, "CREATE TABLE people (id INT PRIMARY KEY, name TEXT NOT NULL)")
+	execStatement(t, fb, "INSERT INTO people (id, name) VALUES (1, 'John')")
+	fb.Checkpoint()
+	execStatement(t, fb, "CREATE TABLE pets (name TEXT UNIQUE)")
+	fb.wal.close()
+
+	fb, err = NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("error reopening database: %s", err)
+	}
+	defer fb.Close()
+
+	tests := []struct {
+		input         string
+		expectedError error
+	}{
+		{"INSERT INTO people (id, name) VALUES (1, 'Julia')", ErrUniqueViolation},
+		{"INSERT INTO people (id) VALUES (2)", ErrNotNullViolation},
+		{"INSERT INTO pets (name) VALUES ('Rex')", nil},
+		{"INSERT INTO pets (name) VALUES ('Rex')", ErrUniqueViolation},
+	}
+
+	for _, tt := range tests {
+		if err := fb.Insert(parseStatement(t, tt.input).(*ast.InsertStatement)); err != tt.expectedError {
+			t.Errorf("%s: expected %v error, got %v", tt.input, tt.expectedError, err)
+		}
+	}
+
+	drop := parseStatement(t, "DROP INDEX people_pkey").(*ast.DropIndexStatement)
+	if err := fb.DropIndex(drop); err != ErrIndexInUse {
+		t.Errorf("expected %q error, got %v", ErrIndexInUse, err)
+	}
+}
+
+func TestForeignKeys(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (id INT PRIMARY KEY, name TEXT)")
+	execStatement(t, mb, "CREATE TABLE addresses (id INT PRIMARY KEY, person INT REFERENCES people ON DELETE CASCADE, city TEXT)")
+	execStatement(t, mb, "CREATE TABLE orders (id INT PRIMARY KEY, person INT, address INT REFERENCES addresses (id) ON DELETE SET NULL, FOREIGN KEY (person) REFERENCES people (id))")
+
+	tests := []struct {
+		input         string
+		expectedError error
+	}{
+		{"INSERT INTO people (id, name) VALUES (1, 'John')", nil},
+		{"INSERT INTO people (id, name) VALUES (2, 'Julia')", nil},
+		{"INSERT INTO addresses (id, person, city) VALUES (10, 1, 'Lagos')", nil},
+		{"INSERT INTO addresses (id, person, city) VALUES (20, 2, 'Abuja')", nil},
+		{"INSERT INTO addresses (id, person, city) VALUES (30, 3, 'Ibadan')", ErrForeignKeyViolation},
+		{"INSERT INTO addresses (id, city) VALUES (30, 'Ibadan')", nil},
+		{"INSERT INTO orders (id, person, address) VALUES (100, 1, 20)", nil},
+		{"INSERT INTO orders (id, person, address) VALUES (200, 1, 40)", ErrForeignKeyViolation},
+		{"UPDATE addresses SET person=3 WHERE id=30", ErrForeignKeyViolation},
+		{"UPDATE addresses SET person=2 WHERE id=30", nil},
+		{"UPDATE addresses SET city='Kano' WHERE id=30", nil},
+
+		// Referenced values can't change
+		{"UPDATE people SET id=3 WHERE id=1", ErrForeignKeyViolation},
+		{"UPDATE people SET name='Johnny' WHERE id=1", nil},
+
+		// Deleting Julia deletes her addresses, which unsets the address
+		// of the order
+		{"DELETE FROM people WHERE id=2", nil},
+		// John still has an order
+		{"DELETE FROM people WHERE id=1", ErrForeignKeyViolation},
+
+		{"CREATE TABLE pets (owner TEXT REFERENCES people (name))", ErrReferencedKeyNotUnique},
+		{"CREATE TABLE pets (owner TEXT REFERENCES people)", ErrForeignKeyMismatch},
+		{"CREATE TABLE pets (owner INT REFERENCES owners)", ErrTableNotFound},
+		{"CREATE TABLE pets (owner INT REFERENCES people (name, id))", ErrReferencedKeyNotUnique},
+	}
+
+	for _, tt := range tests {
+		var err error
+		switch st := parseStatement(t, tt.input).(type) {
+		case *ast.CreateTableStatement:
+			err = mb.CreateTable(st)
+		case *ast.InsertStatement:
+			err = mb.Insert(st)
+		case *ast.UpdateStatement:
+			_, err = mb.Update(st)
+		case *ast.DeleteStatement:
+			_, err = mb.Delete(st)
+		}
+
+		if err != tt.expectedError {
+			t.Errorf("%s: expected %v error, got %v", tt.input, tt.expectedError, err)
+		}
+	}
+
+	expectRowCount(t, mb, "SELECT id FROM people", 1)
+	expectRowCount(t, mb, "SELECT id FROM addresses", 1)
+	expectRowCount(t, mb, "SELECT id FROM addresses WHERE person=1", 1)
+	expectRowCount(t, mb, "SELECT id FROM orders", 1)
+	expectRowCount(t, mb, "SELECT id FROM orders WHERE address=20", 0)
+	if _, ok := mb.tables["pets"]; ok {
+		t.Errorf("expected pets not to be created")
+	}
+}
+
+func TestSelfReferencingForeignKey(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE employees (id INT PRIMARY KEY, manager INT REFERENCES employees ON DELETE CASCADE)")
+	execStatement(t, mb, "INSERT INTO employees (id) VALUES (1)")
+	execStatement(t, mb, "INSERT INTO employees (id, manager) VALUES (2, 1)")
+	execStatement(t, mb, "INSERT INTO employees (id, manager) VALUES (3, 2)")
+	execStatement(t, mb, "INSERT INTO employees (id, manager) VALUES (4, 4)")
+
+	insert := parseStatement(t, "INSERT INTO employees (id, manager) VALUES (5, 6)").(*ast.InsertStatement)
+	if err := mb.Insert(insert); err != ErrForeignKeyViolation {
+		t.Fatalf("expected %q error, got %v", ErrForeignKeyViolation, err)
+	}
+
+	execStatement(t, mb, "DELETE FROM employees WHERE id=1")
+	expectRowCount(t, mb, "SELECT id FROM employees", 1)
+	execStatement(t, mb, "DELETE FROM employees WHERE id=4")
+	expectRowCount(t, mb, "SELECT id FROM employees", 0)
+}
+
+func TestForeignKeyPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	fb, err := NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("error opening database: %s", err)
+	}
+	execStatement(t, fb, "CREATE TABLE people (id INT PRIMARY KEY)")
+	execStatement(t, fb, "CREATE TABLE addresses (person INT REFERENCES people ON DELETE CASCADE)")
+	fb.Checkpoint()
+	execStatement(t, fb, "CREATE TABLE orders (person INT, FOREIGN KEY (person) REFERENCES people (id))")
+	execStatement(t, fb, "INSERT INTO people (id) VALUES (1)")
+	execStatement(t, fb, "INSERT INTO people (id) VALUES (2)")
+	execStatement(t, fb, "INSERT INTO addresses (person) VALUES (1)")
+	execStatement(t, fb, "INSERT INTO orders (person) VALUES (2)")
+	fb.wal.close()
+
+	fb, err = NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("error reopening database: %s", err)
+	}
+	defer fb.Close()
+
+	insert := parseStatement(t, "INSERT INTO addresses (person) VALUES (3)").(*ast.InsertStatement)
+	if err := fb.Insert(insert); err != ErrForeignKeyViolation {
+		t.Errorf("expected %q error, got %v", ErrForeignKeyViolation, err)
+	}
+
+	remove := parseStatement(t, "DELETE FROM people WHERE id=2").(*ast.DeleteStatement)
+	if _, err := fb.Delete(remove); err != ErrForeignKeyViolation {
+		t.Errorf("expected %q error, got %v", ErrForeignKeyViolation, err)
+	}
+
+	execStatement(t, fb, "DELETE FROM people WHERE id=1")
+	expectRowCount(t, fb, "SELECT person FROM addresses", 0)
+}
+
+func TestCheckConstraints(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT CHECK (age > 0 AND age < 150), CONSTRAINT named CHECK (name IN ('John', 'Julia')), CHECK (age > 17 OR name = 'Julia'))")
+
+	tests := []struct {
+		input      string
+		constraint string
+	}{
+		{"INSERT INTO people (name, age) VALUES ('John', 40)", ""},
+		{"INSERT INTO people (name, age) VALUES ('John', 200)", "people_age_check"},
+		{"INSERT INTO people (name, age) VALUES ('Jake', 40)", "named"},
+		{"INSERT INTO people (name, age) VALUES ('John', 10)", "people_check"},
+		{"INSERT INTO people (name, age) VALUES ('Julia', 10)", ""},
+		// Checks on missing values pass
+		{"INSERT INTO people (name) VALUES ('John')", ""},
+		{"UPDATE people SET age=150 WHERE name='Julia'", "people_age_check"},
+		{"UPDATE people SET age=20 WHERE name='Julia'", ""},
+	}
+
+	for _, tt := range tests {
+		var err error
+		switch st := parseStatement(t, tt.input).(type) {
+		case *ast.InsertStatement:
+			err = mb.Insert(st)
+		case *ast.UpdateStatement:
+			_, err = mb.Update(st)
+		}
+
+		if tt.constraint == "" {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %v", tt.input, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrCheckViolation) || !strings.HasSuffix(err.Error(), ": "+tt.constraint) {
+			t.Errorf("%s: expected %q to be violated, got %v", tt.input, tt.constraint, err)
+		}
+	}
+
+	expectRowCount(t, mb, "SELECT name FROM people", 3)
+	expectRowCount(t, mb, "SELECT name FROM people WHERE age=20", 1)
+
+	for _, tt := range []struct {
+		input    string
+		expected error
+	}{
+		{"CREATE TABLE pets (name TEXT CHECK (owner = 'John'))", ErrColumnNotFound},
+		// Branches that aren't taken are searched too
+		{"CREATE TABLE pets (name TEXT CHECK (CASE WHEN name IS NULL THEN 1 ELSE owner END > 0))", ErrColumnNotFound},
+		{"CREATE TABLE pets (name TEXT CHECK (pets.name = 'Rex'))", ErrColumnNotFound},
+		{"CREATE TABLE pets (name TEXT CHECK ('a' + 1 > 0))", evaluator.ErrInvalidOperation},
+		{"CREATE TABLE pets (name TEXT CHECK (name IN (SELECT name FROM people)))", evaluator.ErrNoQuerier},
+		{"CREATE TABLE pets (name TEXT CHECK (EXISTS (SELECT name FROM people)))", evaluator.ErrNoQuerier},
+		{"CREATE TABLE pets (name TEXT CHECK (COUNT(*) > 0))", evaluator.ErrMisusedAggregate},
+		{"CREATE TABLE pets (name TEXT CHECK (ROW_NUMBER() OVER () > 0))", evaluator.ErrMisusedWindow},
+	} {
+		err := mb.CreateTable(parseStatement(t, tt.input).(*ast.CreateTableStatement))
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %q error, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestCheckConstraintPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	fb, err := NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("error opening database: %s", err)
+	}
+	execStatement(t, fb, "CREATE TABLE people (age INT CHECK (age > 0))")
+	fb.Checkpoint()
+	execStatement(t, fb, "CREATE TABLE pets (age INT, CONSTRAINT young CHECK (age < 20))")
+	fb.wal.close()
+
+	fb, err = NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("error reopening database: %s", err)
+	}
+	defer fb.Close()
+
+	tests := []struct {
+		input      string
+		constraint string
+	}{
+		{"INSERT INTO people (age) VALUES (0)", "people_age_check"},
+		{"INSERT INTO pets (age) VALUES (20)", "young"},
+	}
+
+	for _, tt := range tests {
+		err := fb.Insert(parseStatement(t, tt.input).(*ast.InsertStatement))
+		if !errors.Is(err, ErrCheckViolation) || !strings.HasSuffix(err.Error(), ": "+tt.constraint) {
+			t.Errorf("%s: expected %q to be violated, got %v", tt.input, tt.constraint, err)
+		}
+	}
+
+	execStatement(t, fb, "INSERT INTO pets (age) VALUES (2)")
+}
@@ -0,0 +1,64 @@
+package engine
+
+import (
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
+)
+
+// distinction tells the rows of a SELECT DISTINCT apart. Rows are
+// duplicates when the cells they'd store the values of its expressions in
+// hold the same bytes.
+type distinction struct {
+	// exprs are the expressions of DISTINCT ON. Without them, rows are
+	// told apart by all of their result columns.
+	exprs []ast.Expression
+	types []ColumnType
+}
+
+func (r *relation) distinction(stmt *ast.SelectStatement, columns []*ResultColumn) (*distinction, error) {
+	d := &distinction{exprs: stmt.DistinctOn}
+	if len(d.exprs) == 0 {
+		for _, col := range columns {
+			d.types = append(d.types, col.Type)
+		}
+		return d, nil
+	}
+
+	for _, expr := range d.exprs {
+		colType, err := r.exprType(expr)
+		if err != nil {
+			return nil, err
+		}
+		if colType == "" {
+			colType = TEXT_COLUMN
+		}
+		d.types = append(d.types, colType)
+	}
+	return d, nil
+}
+
+// key returns the key of a row with the given result cells. scope must be
+// bound to the row.
+func (d *distinction) key(scope *evaluator.Scope, cells []memoryCell) (string, error) {
+	if len(d.exprs) != 0 {
+		cells = []memoryCell{}
+		for i, expr := range d.exprs {
+			value, err := evaluator.EvalExpression(expr, scope)
+			if err != nil {
+				return "", err
+			}
+
+			cell, err := resultCell(d.types[i], value)
+			if err != nil {
+				return "", err
+			}
+			cells = append(cells, cell)
+		}
+	}
+
+	key := []byte{}
+	for i, cell := range cells {
+		key = appendKeyCell(key, d.types[i], cell)
+	}
+	return string(key), nil
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"errors"
+	"jnafolayan/sql-db/ast"
+	"strings"
+	"testing"
+)
+
+func TestDistinct(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT, balance FLOAT, status TEXT)")
+	execStatement(t, mb, "INSERT INTO people (name, age, balance, status) VALUES ('John', 40, 10.5, 'a')")
+	execStatement(t, mb, "INSERT INTO people (name, age, balance, status) VALUES ('Julia', 30, 20, 'b')")
+	execStatement(t, mb, "INSERT INTO people (name, age, balance, status) VALUES ('Jake', 20, 1.5, 'a')")
+	execStatement(t, mb, "INSERT INTO people (name, age, status) VALUES ('Jane', 30, 'a')")
+	execStatement(t, mb, "INSERT INTO people (name) VALUES ('Jim')")
+	execStatement(t, mb, "INSERT INTO people (name, age, balance, status) VALUES ('John', 40, 3, 'b')")
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// Without ORDER BY, the first row of each set of duplicates is kept
+		{"SELECT DISTINCT status FROM people", []string{"a", "b", "NULL"}},
+		{"SELECT DISTINCT name, age FROM people", []string{"John,40", "Julia,30", "Jake,20", "Jane,30", "Jim,NULL"}},
+		{"SELECT DISTINCT age / 10 AS decade FROM people ORDER BY decade DESC", []string{"4", "3", "2", "NULL"}},
+		{"SELECT DISTINCT age FROM people ORDER BY age LIMIT 2 OFFSET 1", []string{"20", "30"}},
+		{"SELECT DISTINCT status FROM people WHERE age > 25 ORDER BY people.status LIMIT 5", []string{"a", "b"}},
+		{"SELECT DISTINCT status, COUNT(*) FROM people GROUP BY age, status ORDER BY 1, 2", []string{"NULL,1", "a,1", "b,1"}},
+		{"SELECT DISTINCT status FROM people LIMIT 2", []string{"a", "b"}},
+		// Equal numbers are duplicates once stored in the same column
+		{"SELECT DISTINCT CASE WHEN age > 30 THEN 30 ELSE 30.0 END FROM people WHERE age IS NOT NULL", []string{"30.000000"}},
+
+		// DISTINCT ON keeps the first row in ORDER BY order
+		{"SELECT DISTINCT ON (status) status, name FROM people ORDER BY status, name", []string{"NULL,Jim", "a,Jake", "b,John"}},
+		{"SELECT DISTINCT ON (status) status, name FROM people ORDER BY status, balance DESC NULLS LAST", []string{"NULL,Jim", "a,John", "b,Julia"}},
+		{"SELECT DISTINCT ON (name) name, balance FROM people ORDER BY balance NULLS LAST LIMIT 2", []string{"Jake,1.500000", "John,3.000000"}},
+		{"SELECT DISTINCT ON (age / 10, status) name FROM people", []string{"John", "Julia", "Jake", "Jane", "Jim", "John"}},
+		{"SELECT DISTINCT ON (status) name FROM people LIMIT 2", []string{"John", "Julia"}},
+		{"SELECT DISTINCT ON (COUNT(*) > 1) status FROM people GROUP BY status ORDER BY COUNT(*) DESC, status", []string{"a", "NULL"}},
+	}
+
+	for _, tt := range tests {
+		rows := orderedRows(t, mb, tt.input)
+		if strings.Join(rows, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%s: expected %v, got %v", tt.input, tt.expected, rows)
+		}
+	}
+
+	// Rows read in the order of an index are made distinct as well
+	execStatement(t, mb, "CREATE INDEX people_age ON people (age)")
+	for _, tt := range []struct {
+		input    string
+		expected []string
+	}{
+		{"SELECT DISTINCT age FROM people ORDER BY age DESC LIMIT 3", []string{"40", "30", "20"}},
+		{"SELECT DISTINCT ON (age) name FROM people ORDER BY age", []string{"Jim", "Jake", "Julia", "John"}},
+	} {
+		rows := orderedRows(t, mb, tt.input)
+		if strings.Join(rows, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%s: expected %v, got %v", tt.input, tt.expected, rows)
+		}
+	}
+
+	for _, tt := range []struct {
+		input    string
+		expected error
+	}{
+		{"SELECT DISTINCT name FROM people ORDER BY age", ErrDistinctOrder},
+		{"SELECT DISTINCT age + balance * 2 FROM people ORDER BY (age + balance) * 2", ErrDistinctOrder},
+		{"SELECT DISTINCT ON (height) name FROM people", ErrColumnNotFound},
+	} {
+		_, err := mb.Select(parseStatement(t, tt.input).(*ast.SelectStatement))
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %q error, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package engine
 import (
 	"errors"
 	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
 	"jnafolayan/sql-db/lib"
 )
 
@@ -15,6 +16,8 @@ const (
 )
 
 type Cell interface {
+	Value(ColumnType) evaluator.Value
+	IsNull() bool
 	AsText() string
 	AsInt() int64
 	AsFloat() float64
@@ -58,11 +61,48 @@ var (
 	ErrTableNotFound   = errors.New("Table not found")
 	ErrTableExists     = errors.New("Table already exists")
 	ErrColumnNotFound  = errors.New("Column not found")
+
+	ErrIndexExists        = errors.New("Index already exists")
+	ErrIndexNotFound      = errors.New("Index not found")
+	ErrUnknownIndexMethod = errors.New("Unknown index method")
+	ErrIndexInUse         = errors.New("Index is required by a constraint")
+
+	ErrMultiplePrimaryKeys = errors.New("Multiple primary keys are not allowed")
+	ErrUniqueViolation     = errors.New("Duplicate key violates unique constraint")
+	ErrNotNullViolation    = errors.New("Null value violates not-null constraint")
+
+	ErrForeignKeyViolation    = errors.New("Foreign key constraint violated")
+	ErrForeignKeyMismatch     = errors.New("Foreign key columns don't match the referenced columns")
+	ErrReferencedKeyNotUnique = errors.New("Referenced columns are not a primary key or unique")
+
+	ErrCheckViolation = errors.New("Check constraint violated")
+
+	ErrAmbiguousColumn = errors.New("Ambiguous column name")
+	ErrDuplicateTable  = errors.New("Table name specified more than once")
+
+	ErrInvalidLimit  = errors.New("LIMIT and OFFSET must be non-negative integers")
+	ErrDistinctOrder = errors.New("ORDER BY expressions must appear in the select list of SELECT DISTINCT")
+	ErrWindowOffset  = errors.New("Window frame and LAG or LEAD offsets must be non-negative integers")
+
+	ErrWithColumns    = errors.New("WITH query returns a different number of columns than it names")
+	ErrRecursionLimit = errors.New("Recursive WITH query exceeded the recursion limit")
+
+	ErrTransactionInProgress = errors.New("Transaction already in progress")
+	ErrNoTransaction         = errors.New("No transaction in progress")
+	ErrSerializationFailure  = errors.New("Could not serialize access due to concurrent update")
 )
 
 type Engine interface {
 	Select(*ast.SelectStatement) (*FetchResult, error)
 	CreateTable(*ast.CreateTableStatement) error
 	Insert(*ast.InsertStatement) error
-	Delete(*ast.DeleteStatement) error
+	Delete(*ast.DeleteStatement) (*UpdateResult, error)
+	Update(*ast.UpdateStatement) (*UpdateResult, error)
+	CreateIndex(*ast.CreateIndexStatement) error
+	DropIndex(*ast.DropIndexStatement) error
+
+	Begin() error
+	Commit() error
+	Rollback() error
+	InTransaction() bool
 }
@@ -1,7 +1,9 @@
 package engine
 
 import (
+	"errors"
 	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
 	"jnafolayan/sql-db/lexer"
 	"jnafolayan/sql-db/parser"
 	"testing"
@@ -162,3 +164,458 @@ func testStatement(t *testing.T, stmt string, callback func(*testing.T, interfac
 		}
 	})
 }
+
+func TestTransactions(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT)")
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('John', 40)")
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('Julia', 30)")
+
+	versions := []*rowVersion{}
+	for _, row := range mb.tables["people"].rows {
+		versions = append(versions, row.version)
+	}
+
+	if err := mb.Begin(); err != nil {
+		t.Fatalf("error beginning transaction: %s", err)
+	}
+	if err := mb.Begin(); err != ErrTransactionInProgress {
+		t.Fatalf("expected %q error, got %v", ErrTransactionInProgress, err)
+	}
+
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('Jake', 20)")
+	execStatement(t, mb, "UPDATE people SET age=41 WHERE age=40")
+	execStatement(t, mb, "DELETE FROM people WHERE age=30")
+	execStatement(t, mb, "CREATE TABLE pets (name TEXT)")
+
+	// The transaction sees its own changes
+	res := execStatement(t, mb, "SELECT name FROM people WHERE age=41").(*FetchResult)
+	if len(res.Rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(res.Rows))
+	}
+
+	if err := mb.Rollback(); err != nil {
+		t.Fatalf("error rolling back transaction: %s", err)
+	}
+
+	if len(mb.tables["people"].rows) != len(versions) {
+		t.Fatalf("expected %d rows, got %d", len(versions), len(mb.tables["people"].rows))
+	}
+	for i, row := range mb.tables["people"].rows {
+		if row.version != versions[i] || row.version.xmax != 0 {
+			t.Fatalf("expected row %d to be left untouched", row.id)
+		}
+	}
+	if _, ok := mb.tables["pets"]; ok {
+		t.Fatalf("expected table pets not to exist")
+	}
+
+	mb.Begin()
+	execStatement(t, mb, "UPDATE people SET age=41 WHERE age=40")
+	execStatement(t, mb, "CREATE TABLE pets (name TEXT)")
+	if err := mb.Commit(); err != nil {
+		t.Fatalf("error committing transaction: %s", err)
+	}
+
+	res = execStatement(t, mb, "SELECT name FROM people WHERE age=41").(*FetchResult)
+	if len(res.Rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(res.Rows))
+	}
+	if _, ok := mb.tables["pets"]; !ok {
+		t.Fatalf("expected table pets to exist")
+	}
+
+	if err := mb.Commit(); err != ErrNoTransaction {
+		t.Fatalf("expected %q error, got %v", ErrNoTransaction, err)
+	}
+}
+
+func TestNullValues(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT)")
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('John', 40)")
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('Julia', NULL)")
+	execStatement(t, mb, "INSERT INTO people (name) VALUES ('Jake')")
+
+	res := execStatement(t, mb, "SELECT name, age FROM people WHERE name='Julia'").(*FetchResult)
+	if len(res.Rows) != 1 || !res.FetchAssoc()["age"].IsNull() {
+		t.Fatalf("expected a NULL age")
+	}
+
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"SELECT name FROM people WHERE age IS NULL", 2},
+		{"SELECT name FROM people WHERE age is not null", 1},
+		{"SELECT name FROM people WHERE age=NULL", 0},
+		{"SELECT name FROM people WHERE age>30", 1},
+		// Comparisons with NULL are unknown, and so are the rows
+		{"SELECT name FROM people WHERE age=40 OR age=NULL", 1},
+		{"SELECT name FROM people WHERE age=NULL OR name='Jake'", 1},
+		{"SELECT name FROM people WHERE age>30 AND name='John'", 1},
+		{"SELECT name FROM people WHERE age IN (40, NULL)", 1},
+		{"SELECT name FROM people WHERE age IS NULL AND name='Jake'", 1},
+	}
+
+	for _, tt := range tests {
+		expectRowCount(t, mb, tt.input, tt.expected)
+	}
+
+	execStatement(t, mb, "UPDATE people SET age=NULL WHERE name='John'")
+	expectRowCount(t, mb, "SELECT name FROM people WHERE age IS NULL", 3)
+}
+
+func TestValueConversion(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT, balance FLOAT)")
+	execStatement(t, mb, "INSERT INTO people (name, age, balance) VALUES ('John', 40+1, 2)")
+
+	res := execStatement(t, mb, "SELECT name, age, balance FROM people").(*FetchResult)
+	row := res.FetchAssoc()
+	if row["age"].AsInt() != 41 {
+		t.Errorf("expected 41, got %d", row["age"].AsInt())
+	}
+	if row["balance"].AsFloat() != 2 {
+		t.Errorf("expected 2, got %f", row["balance"].AsFloat())
+	}
+	if v := row["name"].Value(TEXT_COLUMN); v.String() != "John" {
+		t.Errorf("expected 'John', got %q", v.String())
+	}
+	if v := row["balance"].Value(FLOAT_COLUMN); v.String() != "2.000000" {
+		t.Errorf("expected '2.000000', got %q", v.String())
+	}
+
+	tests := []string{
+		"INSERT INTO people (name, age) VALUES ('Julia', '30')",
+		"INSERT INTO people (name, age) VALUES ('Julia', 30.5)",
+		"INSERT INTO people (name) VALUES (30)",
+		"UPDATE people SET age='41'",
+	}
+
+	for _, input := range tests {
+		var err error
+		switch st := parseStatement(t, input).(type) {
+		case *ast.InsertStatement:
+			err = mb.Insert(st)
+		case *ast.UpdateStatement:
+			_, err = mb.Update(st)
+		}
+
+		if err != ErrInvalidDataType {
+			t.Errorf("%s: expected %q error, got %v", input, ErrInvalidDataType, err)
+		}
+	}
+}
+
+func TestTypeCoercion(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT, balance FLOAT)")
+	execStatement(t, mb, "INSERT INTO people (name, age, balance) VALUES ('John', 40, 1+0.5)")
+	execStatement(t, mb, "INSERT INTO people (name, age, balance) VALUES ('Julia', 30, 0.5)")
+	execStatement(t, mb, "INSERT INTO people (name, age, balance) VALUES ('10', 10, 10)")
+
+	tests := []struct {
+		predicate string
+		expected  int
+	}{
+		{"balance>1", 2},
+		{"balance=1.5", 1},
+		{"age+0.5=40.5", 1},
+		{"balance-age<0", 2},
+		{"age!=40", 2},
+		// Text that reads as a number is compared as one
+		{"age='40'", 1},
+		{"name=10", 1},
+		{"name=10.0", 1},
+		// Other text sorts after numbers
+		{"name>100", 2},
+		{"name=40", 0},
+		{"name!=40", 3},
+	}
+
+	for _, tt := range tests {
+		expectRowCount(t, mb, "SELECT name FROM people WHERE "+tt.predicate, tt.expected)
+	}
+
+	errorTests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"SELECT name FROM people WHERE age+name=1", "invalid operation: INT + TEXT"},
+		{"SELECT name FROM people WHERE name-1.5>0", "invalid operation: TEXT - FLOAT"},
+		{"INSERT INTO people (name) VALUES ('a'+1)", "invalid operation: TEXT + INT"},
+	}
+
+	for _, tt := range errorTests {
+		var err error
+		switch st := parseStatement(t, tt.input).(type) {
+		case *ast.InsertStatement:
+			err = mb.Insert(st)
+		case *ast.SelectStatement:
+			_, err = mb.Select(st)
+		}
+
+		if err == nil || err.Error() != tt.expectedError {
+			t.Errorf("%s: expected %q error, got %v", tt.input, tt.expectedError, err)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE items (name TEXT, price FLOAT, qty INT)")
+	execStatement(t, mb, "CREATE INDEX items_qty ON items (qty)")
+	execStatement(t, mb, "INSERT INTO items (name, price, qty) VALUES ('pen', 1.5, -(2 * 5))")
+	execStatement(t, mb, "INSERT INTO items (name, price, qty) VALUES ('book', 12, 20 % 7)")
+	execStatement(t, mb, "INSERT INTO items (name, price, qty) VALUES ('lamp', 30 / 4, 0)")
+
+	tests := []struct {
+		predicate string
+		expected  int
+	}{
+		{"price * qty > 20", 1},
+		{"qty = -10", 1},
+		{"-qty > 5", 1},
+		{"(price + 0.5) * 2 = 4", 1},
+		{"price = 7", 1},
+		{"qty % 2 = 0", 3},
+		{"qty / 3 = 2", 1},
+		// Results up to the bounds of INT are exact
+		{"9223372036854775806 + 1 = 9223372036854775807", 3},
+		{"-9223372036854775807 - 1 < 0", 3},
+		{"(-9223372036854775807 - 1) / 1 = -9223372036854775807 - 1", 3},
+		{"4611686018427387904 * -2 < 0", 3},
+	}
+
+	for _, tt := range tests {
+		expectRowCount(t, mb, "SELECT name FROM items WHERE "+tt.predicate, tt.expected)
+	}
+
+	// Constant expressions can be looked up in indexes
+	stmt := parseStatement(t, "SELECT name FROM items WHERE qty = 2 * 3").(*ast.SelectStatement)
+	table := mb.tables["items"]
+	if rows := table.candidateRows(stmt.Predicate, generateColNameToIndexMap(table.columns)); len(rows) != 1 {
+		t.Errorf("expected 1 candidate row, got %d", len(rows))
+	}
+
+	for _, tt := range []struct {
+		input    string
+		expected error
+	}{
+		{"SELECT name FROM items WHERE price / qty > 1", evaluator.ErrDivisionByZero},
+		{"SELECT name FROM items WHERE qty % 0 = 1", evaluator.ErrDivisionByZero},
+		{"SELECT name FROM items WHERE 9223372036854775807 + 1 > 0", evaluator.ErrIntegerOverflow},
+		{"SELECT name FROM items WHERE -9223372036854775807 - 2 < 0", evaluator.ErrIntegerOverflow},
+		{"SELECT name FROM items WHERE (-9223372036854775807 - 1) / -1 > 0", evaluator.ErrIntegerOverflow},
+		{"SELECT name FROM items WHERE -(-9223372036854775807 - 1) > 0", evaluator.ErrIntegerOverflow},
+		{"SELECT name FROM items WHERE 4611686018427387904 * 2 > 0", evaluator.ErrIntegerOverflow},
+		{"SELECT name FROM items WHERE -1 * (-9223372036854775807 - 1) > 0", evaluator.ErrIntegerOverflow},
+		{"SELECT qty * 9223372036854775807 FROM items", evaluator.ErrIntegerOverflow},
+	} {
+		_, err := mb.Select(parseStatement(t, tt.input).(*ast.SelectStatement))
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %q error, got %v", tt.input, tt.expected, err)
+		}
+	}
+
+	// Columns are named after how their operations are grouped
+	res := execStatement(t, mb, "SELECT (price + 1) * qty, price + 1 * qty, price - -qty, -(price - qty) FROM items").(*FetchResult)
+	for i, name := range []string{"(price+1)*qty", "price+(1*qty)", "price-(-qty)", "-(price-qty)"} {
+		if res.Columns[i].Name != name {
+			t.Errorf("expected column %q, got %q", name, res.Columns[i].Name)
+		}
+	}
+
+	insert := parseStatement(t, "INSERT INTO items (name) VALUES (-'pen')").(*ast.InsertStatement)
+	if err := mb.Insert(insert); err == nil || err.Error() != "invalid operation: -TEXT" {
+		t.Errorf("expected %q error, got %v", "invalid operation: -TEXT", err)
+	}
+}
+
+func TestPatternMatching(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT)")
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('John', 40)")
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('Julia', 30)")
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('jo_ann', 20)")
+	execStatement(t, mb, "INSERT INTO people (age) VALUES (10)")
+
+	tests := []struct {
+		predicate string
+		expected  int
+	}{
+		{"name LIKE 'Jo%'", 2},
+		{"name NOT LIKE 'Jo%'", 1},
+		{"name LIKE 'J___'", 1},
+		{"name LIKE 'jo!_%' ESCAPE '!'", 1},
+		{"name GLOB 'J*'", 2},
+		{"name GLOB '[jJ]o*'", 2},
+		{"name NOT GLOB 'J*'", 1},
+		{"name REGEXP 'li'", 1},
+		{"name REGEXP '^[A-Z][a-z]+$'", 2},
+		{"name LIKE 'J%' AND age > 35", 1},
+	}
+
+	for _, tt := range tests {
+		expectRowCount(t, mb, "SELECT age FROM people WHERE "+tt.predicate, tt.expected)
+	}
+
+	stmt := parseStatement(t, "SELECT age FROM people WHERE age LIKE '4%'").(*ast.SelectStatement)
+	if _, err := mb.Select(stmt); !errors.Is(err, evaluator.ErrInvalidOperation) {
+		t.Errorf("expected %q error, got %v", evaluator.ErrInvalidOperation, err)
+	}
+}
+
+func TestInAndBetween(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT, status TEXT)")
+	execStatement(t, mb, "INSERT INTO people (name, age, status) VALUES ('John', 40, 'a')")
+	execStatement(t, mb, "INSERT INTO people (name, age, status) VALUES ('Julia', 30, 'b')")
+	execStatement(t, mb, "INSERT INTO people (name, age, status) VALUES ('Jake', 20, 'd')")
+	execStatement(t, mb, "INSERT INTO people (name, status) VALUES ('Jane', 'c')")
+
+	tests := []struct {
+		predicate string
+		expected  int
+	}{
+		{"status IN ('a', 'b', 'c')", 3},
+		{"status NOT IN ('a', 'b', 'c')", 1},
+		{"age IN (10 + 10, 30)", 2},
+		{"age NOT IN (20, 30)", 1},
+		// Without a match, a NULL in the list makes the result unknown
+		{"age NOT IN (20, NULL)", 0},
+		{"age IN (20, NULL)", 1},
+		{"age BETWEEN 20 AND 30", 2},
+		{"age BETWEEN 30 AND 30", 1},
+		{"age NOT BETWEEN 20 AND 30", 1},
+		{"age BETWEEN 25 AND 45 AND status = 'a'", 1},
+		{"age BETWEEN 20 AND 30 OR status = 'c'", 3},
+		// AND binds tighter than OR
+		{"age = 20 OR age = 30 AND status = 'x'", 1},
+		{"status = 'c' OR age BETWEEN 20 AND 30 AND status = 'd'", 2},
+		{"age BETWEEN 30 AND 20", 0},
+		{"age BETWEEN 25.5 AND 40.0", 2},
+		{"name BETWEEN 'Jake' AND 'Jane'", 2},
+		// The bound that is known can still rule rows out
+		{"age BETWEEN NULL AND 25", 0},
+		{"age NOT BETWEEN NULL AND 25", 2},
+		{"age NOT BETWEEN 15 AND NULL", 0},
+	}
+
+	for _, tt := range tests {
+		expectRowCount(t, mb, "SELECT name FROM people WHERE "+tt.predicate, tt.expected)
+	}
+}
+
+func TestCaseExpressions(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT, status TEXT)")
+	execStatement(t, mb, "INSERT INTO people (name, age, status) VALUES ('John', 40, 'a')")
+	execStatement(t, mb, "INSERT INTO people (name, age, status) VALUES ('Julia', 30, 'b')")
+	execStatement(t, mb, "INSERT INTO people (name, age, status) VALUES ('Jake', 20, CASE WHEN 1 > 2 THEN 'x' ELSE 'c' END)")
+	execStatement(t, mb, "INSERT INTO people (name) VALUES ('Jane')")
+
+	tests := []struct {
+		predicate string
+		expected  int
+	}{
+		{"CASE WHEN age > 25 THEN 'old' ELSE 'young' END = 'old'", 2},
+		// Unknown conditions fall through to ELSE
+		{"CASE WHEN age > 25 THEN 'old' ELSE 'young' END = 'young'", 2},
+		{"CASE status WHEN 'a' THEN 1 WHEN 'b' THEN 2 ELSE 3 END = 3", 2},
+		{"CASE status WHEN 'c' THEN age END = 20", 1},
+		// Without an ELSE, rows that match no WHEN get NULL
+		{"CASE status WHEN 'a' THEN 1 END IS NULL", 3},
+		// NULL doesn't match NULL
+		{"CASE age WHEN NULL THEN 1 ELSE 0 END = 1", 0},
+		{"CASE WHEN age > 30 THEN 1 WHEN age > 10 THEN 2 END = 2", 2},
+	}
+
+	for _, tt := range tests {
+		expectRowCount(t, mb, "SELECT name FROM people WHERE "+tt.predicate, tt.expected)
+	}
+
+	res := execStatement(t, mb, "UPDATE people SET age = age + 1, status = CASE WHEN age > 25 THEN 'senior' ELSE status END").(*UpdateResult)
+	if res.AffectedRows != 4 {
+		t.Fatalf("expected 4 affected rows, got %d", res.AffectedRows)
+	}
+
+	// SET values are computed from the row before the update
+	expectRowCount(t, mb, "SELECT name FROM people WHERE status = 'senior'", 2)
+	expectRowCount(t, mb, "SELECT name FROM people WHERE age = 21 AND status = 'c'", 1)
+	expectRowCount(t, mb, "SELECT name FROM people WHERE age IS NULL AND status IS NULL", 1)
+
+	update := parseStatement(t, "UPDATE people SET age = CASE WHEN name = 'John' THEN 'forty' ELSE age END").(*ast.UpdateStatement)
+	if _, err := mb.Update(update); !errors.Is(err, ErrInvalidDataType) {
+		t.Errorf("expected %q error, got %v", ErrInvalidDataType, err)
+	}
+	expectRowCount(t, mb, "SELECT name FROM people WHERE age = 41", 1)
+}
+
+func TestSelectExpressions(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT, balance FLOAT)")
+	execStatement(t, mb, "INSERT INTO people (name, age, balance) VALUES ('John', 40, 10.5)")
+	execStatement(t, mb, "INSERT INTO people (name) VALUES ('Jane')")
+
+	res := execStatement(t, mb, "SELECT name, age + 1 AS next_age, balance * 2 doubled, age > 30 AS old, CASE WHEN age > 30 THEN 'old' ELSE age END label, NULL AS nothing FROM people WHERE name = 'John'").(*FetchResult)
+
+	expected := []ResultColumn{
+		{TEXT_COLUMN, "name"},
+		{INT_COLUMN, "next_age"},
+		{FLOAT_COLUMN, "doubled"},
+		{INT_COLUMN, "old"},
+		{TEXT_COLUMN, "label"},
+		{TEXT_COLUMN, "nothing"},
+	}
+	if len(res.Columns) != len(expected) {
+		t.Fatalf("expected %d columns, got %d", len(expected), len(res.Columns))
+	}
+	for i, col := range res.Columns {
+		if *col != expected[i] {
+			t.Errorf("expected column %v, got %v", expected[i], *col)
+		}
+	}
+
+	row := res.FetchAssoc()
+	if row["name"].AsText() != "John" || row["next_age"].AsInt() != 41 || row["doubled"].AsFloat() != 21 ||
+		row["old"].AsInt() != 1 || row["label"].AsText() != "old" || !row["nothing"].IsNull() {
+		t.Errorf("unexpected row %v", row)
+	}
+
+	// Computed columns without an alias are named after the expression
+	res = execStatement(t, mb, "SELECT age - 1, -balance FROM people WHERE name = 'Jane'").(*FetchResult)
+	if res.Columns[0].Name != "age-1" || res.Columns[1].Name != "-balance" {
+		t.Errorf("unexpected columns %v, %v", res.Columns[0], res.Columns[1])
+	}
+	row = res.FetchAssoc()
+	if !row["age-1"].IsNull() || !row["-balance"].IsNull() {
+		t.Errorf("expected NULL values")
+	}
+
+	// Stars select the columns of the table in order
+	for _, input := range []string{"SELECT * FROM people", "SELECT people.*, name AS alias FROM people"} {
+		res = execStatement(t, mb, input).(*FetchResult)
+		for i, name := range []string{"name", "age", "balance"} {
+			if res.Columns[i].Name != name {
+				t.Errorf("%s: expected column %q, got %q", input, name, res.Columns[i].Name)
+			}
+		}
+	}
+
+	for _, tt := range []struct {
+		input    string
+		expected error
+	}{
+		{"SELECT others.* FROM people", ErrTableNotFound},
+		{"SELECT age + height FROM people", ErrColumnNotFound},
+		{"SELECT CASE WHEN height > 1 THEN 1 END FROM people", ErrColumnNotFound},
+		{"SELECT name IN (nickname) FROM people", ErrColumnNotFound},
+	} {
+		_, err := mb.Select(parseStatement(t, tt.input).(*ast.SelectStatement))
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %q error, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// defaultCheckpointSize is the size the write-ahead log may grow to before
+// the tables are written back to the database file.
+const defaultCheckpointSize = 4 << 20
+
+// FileBackend keeps its tables in memory like MemoryBackend and persists
+// them in a single database file, so they outlive the process.
+//
+// Changes are first appended to a write-ahead log next to the database file
+// and fsynced. The database file itself is only rewritten, atomically, when
+// the log is checkpointed. Opening the database replays whatever the log
+// holds on top of the last checkpoint, so a crash never leaves a statement
+// half applied.
+type FileBackend struct {
+	*MemoryBackend
+	path           string
+	wal            *writeAheadLog
+	checkpointSize int64
+}
+
+// NewFileBackend opens the database file at path, creating it if it does
+// not exist yet.
+func NewFileBackend(path string) (*FileBackend, error) {
+	tables, seq, err := readDatabase(path)
+	created := errors.Is(err, fs.ErrNotExist)
+	if created {
+		tables = NewMemoryBackendTables()
+	} else if err != nil {
+		return nil, err
+	}
+
+	wal, err := openWriteAheadLog(path + "-wal")
+	if err != nil {
+		return nil, err
+	}
+
+	err = wal.replay(seq, func(mutations []*mutation) error {
+		return applyMutations(tables, mutations)
+	})
+	if err != nil {
+		wal.close()
+		return nil, err
+	}
+
+	fb := &FileBackend{
+		MemoryBackend:  NewMemoryBackend(tables),
+		path:           path,
+		wal:            wal,
+		checkpointSize: defaultCheckpointSize,
+	}
+	fb.journal = fb
+
+	if created {
+		if err := fb.Checkpoint(); err != nil {
+			wal.close()
+			return nil, err
+		}
+	}
+
+	return fb, nil
+}
+
+func (fb *FileBackend) append(mutations []*mutation) error {
+	return fb.wal.append(mutations)
+}
+
+func (fb *FileBackend) committed() {
+	if fb.wal.size < fb.checkpointSize {
+		return
+	}
+
+	// The transaction is already durable in the log, so a failed checkpoint
+	// is simply retried after the next commit
+	fb.checkpoint()
+}
+
+// Checkpoint writes the committed tables to the database file and empties
+// the log.
+func (fb *FileBackend) Checkpoint() error {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	return fb.checkpoint()
+}
+
+// Close checkpoints the log and closes the database.
+func (fb *FileBackend) Close() error {
+	if err := fb.Checkpoint(); err != nil {
+		fb.wal.close()
+		return err
+	}
+	return fb.wal.close()
+}
+
+// checkpoint must be called with fb.mu held.
+func (fb *FileBackend) checkpoint() error {
+	if err := writeDatabase(fb.path, fb.tables, fb.latest(), fb.wal.seq); err != nil {
+		return err
+	}
+	return fb.wal.reset()
+}
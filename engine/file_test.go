@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"fmt"
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/lexer"
+	"jnafolayan/sql-db/parser"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileBackend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	fb, err := NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("error opening database: %s", err)
+	}
+
+	execStatement(t, fb, "CREATE TABLE people (name TEXT, age INT, balance FLOAT)")
+	execStatement(t, fb, "CREATE TABLE empty (id INT)")
+	// Enough rows to span several pages
+	for i := 0; i < 500; i++ {
+		execStatement(t, fb, fmt.Sprintf("INSERT INTO people (name, age, balance) VALUES ('person %d', %d, %d.5)", i, i, i))
+	}
+	execStatement(t, fb, "INSERT INTO people (name, age) VALUES ('', 1000)")
+	execStatement(t, fb, "UPDATE people SET balance=2.25 WHERE age=3")
+	execStatement(t, fb, "DELETE FROM people WHERE age=4")
+
+	if err := fb.Close(); err != nil {
+		t.Fatalf("error closing database: %s", err)
+	}
+
+	fb, err = NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("error reopening database: %s", err)
+	}
+
+	res := execStatement(t, fb, "SELECT name, age, balance FROM people").(*FetchResult)
+	if len(res.Rows) != 500 {
+		t.Fatalf("expected 500 rows, got %d", len(res.Rows))
+	}
+
+	for i := 0; i < 499; i++ {
+		row := res.FetchAssoc()
+		age := i
+		if i >= 4 {
+			age = i + 1
+		}
+
+		if row["name"].AsText() != fmt.Sprintf("person %d", age) {
+			t.Errorf("expected %q, got %q", fmt.Sprintf("person %d", age), row["name"].AsText())
+		}
+		if row["age"].AsInt() != int64(age) {
+			t.Errorf("expected %d, got %d", age, row["age"].AsInt())
+		}
+
+		balance := float64(age) + 0.5
+		if age == 3 {
+			balance = 2.25
+		}
+		if row["balance"].AsFloat() != balance {
+			t.Errorf("expected %f, got %f", balance, row["balance"].AsFloat())
+		}
+	}
+
+	last := res.FetchAssoc()
+	if last["name"] == nil || last["name"].AsText() != "" {
+		t.Errorf("expected an empty name, got %v", last["name"])
+	}
+	if last["balance"].(memoryCell) != nil {
+		t.Errorf("expected a nil balance, got %v", last["balance"])
+	}
+
+	res = execStatement(t, fb, "SELECT id FROM empty").(*FetchResult)
+	if len(res.Rows) != 0 {
+		t.Errorf("expected 0 rows, got %d", len(res.Rows))
+	}
+}
+
+func TestFileBackendCorruption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	fb, err := NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("error opening database: %s", err)
+	}
+	execStatement(t, fb, "CREATE TABLE people (name TEXT)")
+	execStatement(t, fb, "INSERT INTO people (name) VALUES ('John')")
+	if err := fb.Close(); err != nil {
+		t.Fatalf("error closing database: %s", err)
+	}
+
+	tables, _, err := readDatabase(path)
+	if err != nil {
+		t.Fatalf("error reading database: %s", err)
+	}
+	if len(tables["people"].rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(tables["people"].rows))
+	}
+
+	// Flip a byte inside the data page
+	corruptFile(t, path, pageSize+pageHeaderSize+1)
+
+	if _, err := NewFileBackend(path); err != ErrCorruptDatabase {
+		t.Fatalf("expected %q error, got %v", ErrCorruptDatabase, err)
+	}
+}
+
+// execStatement runs a single statement against e and returns its result.
+func execStatement(t *testing.T, e Engine, input string) interface{} {
+	t.Helper()
+
+	var res interface{}
+	var err error
+	switch st := parseStatement(t, input).(type) {
+	case *ast.CreateTableStatement:
+		err = e.CreateTable(st)
+	case *ast.InsertStatement:
+		err = e.Insert(st)
+	case *ast.SelectStatement:
+		res, err = e.Select(st)
+	case *ast.DeleteStatement:
+		res, err = e.Delete(st)
+	case *ast.UpdateStatement:
+		res, err = e.Update(st)
+	case *ast.CreateIndexStatement:
+		err = e.CreateIndex(st)
+	case *ast.DropIndexStatement:
+		err = e.DropIndex(st)
+	}
+
+	if err != nil {
+		t.Fatalf("error executing %q: %s", input, err)
+	}
+	return res
+}
+
+func parseStatement(t *testing.T, input string) ast.Statement {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program, err := p.Parse()
+	if err != nil {
+		t.Fatalf("error parsing %q: %s", input, err)
+	}
+	return program.Statements[0]
+}
+
+func corruptFile(t *testing.T, path string, offset int64) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	b := make([]byte, 1)
+	if _, err := f.ReadAt(b, offset); err != nil {
+		t.Fatal(err)
+	}
+	b[0] ^= 0xff
+	if _, err := f.WriteAt(b, offset); err != nil {
+		t.Fatal(err)
+	}
+}
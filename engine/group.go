@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"fmt"
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
+	"strings"
+)
+
+// aggregateCalls returns the aggregate calls exprs are made of. The
+// arguments of aggregate calls aren't searched.
+func aggregateCalls(exprs ...ast.Expression) []*ast.CallExpression {
+	calls := []*ast.CallExpression{}
+	for _, expr := range exprs {
+		ast.Inspect(expr, func(e ast.Expression) bool {
+			call, ok := e.(*ast.CallExpression)
+			if ok && evaluator.IsAggregate(call) {
+				calls = append(calls, call)
+				return false
+			}
+			return true
+		})
+	}
+	return calls
+}
+
+// noAggregates returns an error if exprs call aggregate functions, which
+// can't be used before rows are grouped.
+func noAggregates(exprs ...ast.Expression) error {
+	if calls := aggregateCalls(exprs...); len(calls) != 0 {
+		return fmt.Errorf("%w %s()", evaluator.ErrMisusedAggregate, calls[0].Name)
+	}
+	return nil
+}
+
+// group is a set of rows with the same GROUP BY values.
+type group struct {
+	// row is the first row of the group. Columns that aren't aggregated
+	// take their values from it.
+	row          []memoryCell
+	accumulators []*evaluator.Accumulator
+}
+
+// groupRows splits rows into groups, in the order the groups are first
+// seen, and computes calls over each of them. Without GROUP BY, the rows
+// form a single group, even if there are none.
+func (r *relation) groupRows(scope *evaluator.Scope, rows [][]memoryCell, groupBy []ast.Expression, calls []*ast.CallExpression) ([]*group, error) {
+	newGroup := func(row []memoryCell) (*group, error) {
+		g := &group{row: row}
+		for _, call := range calls {
+			acc, err := evaluator.NewAccumulator(call)
+			if err != nil {
+				return nil, err
+			}
+			g.accumulators = append(g.accumulators, acc)
+		}
+		return g, nil
+	}
+
+	groups := []*group{}
+	if len(groupBy) == 0 {
+		g, err := newGroup(make([]memoryCell, len(r.columns)))
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) != 0 {
+			g.row = rows[0]
+		}
+		groups = append(groups, g)
+	}
+
+	byKey := map[string]*group{}
+	for _, row := range rows {
+		r.bind(scope, row)
+
+		var current *group
+		if len(groupBy) == 0 {
+			current = groups[0]
+		} else {
+			keys := []string{}
+			for _, expr := range groupBy {
+				v, err := evaluator.EvalExpression(expr, scope)
+				if err != nil {
+					return nil, err
+				}
+				keys = append(keys, evaluator.Key(v))
+			}
+
+			key := strings.Join(keys, "\x00")
+			current = byKey[key]
+			if current == nil {
+				created, err := newGroup(row)
+				if err != nil {
+					return nil, err
+				}
+				current = created
+				byKey[key] = current
+				groups = append(groups, current)
+			}
+		}
+
+		for _, acc := range current.accumulators {
+			if err := acc.Step(scope); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return groups, nil
+}
+
+// bindGroup binds scope to the first row of g and to the results of the
+// aggregate calls computed over it.
+func (r *relation) bindGroup(scope *evaluator.Scope, g *group, calls []*ast.CallExpression) {
+	r.bind(scope, g.row)
+	for i, call := range calls {
+		scope.SetAggregate(call, g.accumulators[i].Result())
+	}
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"errors"
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
+	"strings"
+	"testing"
+)
+
+func TestAggregates(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT, balance FLOAT, status TEXT)")
+	execStatement(t, mb, "INSERT INTO people (name, age, balance, status) VALUES ('John', 40, 10.5, 'a')")
+	execStatement(t, mb, "INSERT INTO people (name, age, balance, status) VALUES ('Julia', 30, 20, 'b')")
+	execStatement(t, mb, "INSERT INTO people (name, age, balance, status) VALUES ('Jake', 20, 1.5, 'a')")
+	execStatement(t, mb, "INSERT INTO people (name, age, status) VALUES ('Jane', 30, 'a')")
+	execStatement(t, mb, "INSERT INTO people (name) VALUES ('Jim')")
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"SELECT COUNT(*), COUNT(age), COUNT(DISTINCT age) FROM people", []string{"5,4,3"}},
+		{"SELECT SUM(age), AVG(age), MIN(age), MAX(age) FROM people", []string{"120,30.000000,20,40"}},
+		{"SELECT SUM(balance), MIN(name), MAX(name) FROM people", []string{"32.000000,Jake,Julia"}},
+		{"SELECT COUNT(*), SUM(age), AVG(age), MAX(name) FROM people WHERE age > 50", []string{"0,NULL,NULL,NULL"}},
+		{"SELECT status, COUNT(*) FROM people GROUP BY status", []string{"NULL,1", "a,3", "b,1"}},
+		{"SELECT status, COUNT(*) AS n FROM people GROUP BY status HAVING COUNT(*) > 1", []string{"a,3"}},
+		{"SELECT status, SUM(age) FROM people GROUP BY status HAVING MAX(balance) > 15", []string{"b,30"}},
+		{"SELECT age / 10, COUNT(*) FROM people WHERE age IS NOT NULL GROUP BY age / 10", []string{"2,1", "3,2", "4,1"}},
+		{"SELECT status, age, COUNT(*) FROM people GROUP BY status, age HAVING status = 'a'", []string{"a,20,1", "a,30,1", "a,40,1"}},
+		{"SELECT COUNT(*) * 2 + 1, MAX(age) - MIN(age) FROM people", []string{"11,20"}},
+		{"SELECT CASE WHEN COUNT(*) > 4 THEN 'many' ELSE 'few' END FROM people", []string{"many"}},
+		// NULLs form a single group, and are skipped by aggregates
+		{"SELECT COUNT(age), COUNT(DISTINCT status) FROM people GROUP BY age", []string{"0,0", "1,1", "1,1", "2,2"}},
+		// Numbers of different types that are equal fall in the same group
+		{"SELECT COUNT(*) FROM people WHERE age IS NOT NULL GROUP BY CASE WHEN age > 35 THEN 30 ELSE 30.0 END", []string{"4"}},
+		{"SELECT COUNT(*) FROM people HAVING COUNT(*) > 10", []string{}},
+	}
+
+	for _, tt := range tests {
+		rows := joinedRows(t, mb, tt.input)
+		if strings.Join(rows, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%s: expected %v, got %v", tt.input, tt.expected, rows)
+		}
+	}
+
+	res := execStatement(t, mb, "SELECT COUNT(*) AS n, SUM(age), SUM(balance), AVG(age), MIN(name), MAX(balance), SUM(NULL) FROM people").(*FetchResult)
+	types := []ColumnType{INT_COLUMN, INT_COLUMN, FLOAT_COLUMN, FLOAT_COLUMN, TEXT_COLUMN, FLOAT_COLUMN, TEXT_COLUMN}
+	for i, col := range res.Columns {
+		if col.Type != types[i] {
+			t.Errorf("expected %s column %q, got %s", types[i], col.Name, col.Type)
+		}
+	}
+	if res.Columns[0].Name != "n" || res.Columns[1].Name != "SUM(age)" {
+		t.Errorf("unexpected columns %q, %q", res.Columns[0].Name, res.Columns[1].Name)
+	}
+
+	for _, tt := range []struct {
+		input    string
+		expected error
+	}{
+		{"SELECT name FROM people WHERE COUNT(*) > 1", evaluator.ErrMisusedAggregate},
+		{"SELECT COUNT(*) FROM people GROUP BY COUNT(*)", evaluator.ErrMisusedAggregate},
+		{"SELECT SUM(COUNT(*)) FROM people", evaluator.ErrMisusedAggregate},
+		{"SELECT SUM(*) FROM people", evaluator.ErrMisusedAggregate},
+		{"SELECT MAX(age, balance) FROM people", evaluator.ErrMisusedAggregate},
+		{"SELECT LENGTH(name) FROM people", evaluator.ErrUnknownFunction},
+		{"SELECT SUM(name) FROM people", evaluator.ErrInvalidOperation},
+		{"SELECT COUNT(height) FROM people", ErrColumnNotFound},
+		{"SELECT COUNT(*) FROM people GROUP BY height", ErrColumnNotFound},
+	} {
+		_, err := mb.Select(parseStatement(t, tt.input).(*ast.SelectStatement))
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %q error, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
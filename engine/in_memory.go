@@ -3,15 +3,33 @@ package engine
 import (
 	"bytes"
 	"encoding/binary"
-	"fmt"
 	"jnafolayan/sql-db/ast"
 	"jnafolayan/sql-db/evaluator"
 	"jnafolayan/sql-db/token"
-	"strconv"
+	"math"
+	"strings"
+	"sync"
 )
 
 type memoryCell []byte
 
+// Value converts the cell to a value of a column of colType.
+func (mc memoryCell) Value(colType ColumnType) evaluator.Value {
+	switch {
+	case mc == nil:
+		return &evaluator.Null{}
+	case colType == INT_COLUMN:
+		return &evaluator.Int{Value: mc.AsInt()}
+	case colType == FLOAT_COLUMN:
+		return &evaluator.Float{Value: mc.AsFloat()}
+	}
+	return &evaluator.Text{Value: mc.AsText()}
+}
+
+func (mc memoryCell) IsNull() bool {
+	return mc == nil
+}
+
 func (mc memoryCell) AsText() string {
 	return string(mc)
 }
@@ -37,11 +55,36 @@ func (mc memoryCell) AsFloat() float64 {
 type tableColumn struct {
 	columnType ColumnType
 	name       string
+	notNull    bool
+}
+
+// rowVersion is one version of a row. Statements never modify a version;
+// updating a row adds a newer version and marks the old one as replaced.
+type rowVersion struct {
+	cells []memoryCell
+	// xmin is the transaction that created the version and xmax the one
+	// that deleted or replaced it. Zero means the version is visible to
+	// every transaction (xmin) or hasn't been deleted (xmax).
+	xmin uint64
+	xmax uint64
+	prev *rowVersion
+}
+
+type memoryRow struct {
+	id int64
+	// version is the newest version of the row
+	version *rowVersion
 }
 
 type memoryTable struct {
-	columns []*tableColumn
-	rows    [][]memoryCell
+	columns   []*tableColumn
+	rows      []*memoryRow
+	lastRowID int64
+	createdBy uint64
+
+	indexes     []*tableIndex
+	foreignKeys []*foreignKey
+	checks      []*checkConstraint
 }
 
 type MemoryTables map[string]*memoryTable
@@ -50,8 +93,26 @@ func NewMemoryBackendTables() MemoryTables {
 	return MemoryTables{}
 }
 
+// MemoryBackend is safe for concurrent use. Every statement runs in a
+// transaction that sees a consistent snapshot of the tables: either the
+// one opened with Begin or one that is committed as soon as the statement
+// completes.
+//
+// Begin, Commit and Rollback act on a session shared by every caller of
+// the backend's methods. Callers that run transactions concurrently should
+// each use their own Session.
 type MemoryBackend struct {
-	tables MemoryTables
+	mu       sync.RWMutex
+	tables   MemoryTables
+	journal  journal
+	nextTxID uint64
+	active   map[uint64]*transaction
+	// rows and tables written by committed transactions which may still
+	// hold versions that aren't visible to every transaction
+	pendingRows   []*touchedRow
+	pendingTables []*memoryTable
+
+	session *Session
 }
 
 func NewMemoryBackend(existing MemoryTables) *MemoryBackend {
@@ -60,19 +121,75 @@ func NewMemoryBackend(existing MemoryTables) *MemoryBackend {
 		tables = NewMemoryBackendTables()
 	}
 
-	return &MemoryBackend{
-		tables: tables,
+	mb := &MemoryBackend{
+		tables:   tables,
+		nextTxID: 1,
+		active:   map[uint64]*transaction{},
 	}
+	mb.session = mb.NewSession()
+
+	return mb
 }
 
 func (mb *MemoryBackend) CreateTable(stmt *ast.CreateTableStatement) error {
+	return mb.session.CreateTable(stmt)
+}
+
+func (mb *MemoryBackend) Insert(stmt *ast.InsertStatement) error {
+	return mb.session.Insert(stmt)
+}
+
+func (mb *MemoryBackend) Select(stmt *ast.SelectStatement) (*FetchResult, error) {
+	return mb.session.Select(stmt)
+}
+
+func (mb *MemoryBackend) Delete(stmt *ast.DeleteStatement) (*UpdateResult, error) {
+	return mb.session.Delete(stmt)
+}
+
+func (mb *MemoryBackend) Update(stmt *ast.UpdateStatement) (*UpdateResult, error) {
+	return mb.session.Update(stmt)
+}
+
+func (mb *MemoryBackend) CreateIndex(stmt *ast.CreateIndexStatement) error {
+	return mb.session.CreateIndex(stmt)
+}
+
+func (mb *MemoryBackend) DropIndex(stmt *ast.DropIndexStatement) error {
+	return mb.session.DropIndex(stmt)
+}
+
+func (mb *MemoryBackend) Begin() error {
+	return mb.session.Begin()
+}
+
+func (mb *MemoryBackend) Commit() error {
+	return mb.session.Commit()
+}
+
+func (mb *MemoryBackend) Rollback() error {
+	return mb.session.Rollback()
+}
+
+func (mb *MemoryBackend) InTransaction() bool {
+	return mb.session.InTransaction()
+}
+
+func (s *Session) CreateTable(stmt *ast.CreateTableStatement) error {
 	t := &memoryTable{}
 
-	if _, ok := mb.tables[stmt.Table.Literal]; ok {
-		return ErrTableExists
+	// Unique constraints, as lists of column positions
+	primaryKey := []int{}
+	unique := [][]int{}
+
+	// Foreign keys, resolved once the referenced tables can be looked up
+	type reference struct {
+		columns []int
+		ref     *ast.ForeignKey
 	}
+	references := []reference{}
 
-	for _, col := range stmt.Columns {
+	for i, col := range stmt.Columns {
 		var colType ColumnType
 		switch col.DataType.Type {
 		case token.TEXT:
@@ -88,174 +205,362 @@ func (mb *MemoryBackend) CreateTable(stmt *ast.CreateTableStatement) error {
 		t.columns = append(t.columns, &tableColumn{
 			name:       col.Name.Literal,
 			columnType: colType,
+			notNull:    col.NotNull,
 		})
+
+		if col.PrimaryKey {
+			if len(primaryKey) != 0 {
+				return ErrMultiplePrimaryKeys
+			}
+			primaryKey = []int{i}
+		}
+		if col.Unique {
+			unique = append(unique, []int{i})
+		}
+		if col.References != nil {
+			references = append(references, reference{[]int{i}, col.References})
+		}
 	}
 
-	mb.tables[stmt.Table.Literal] = t
-	return nil
-}
+	colNameToIdx := generateColNameToIndexMap(t.columns)
+	for _, constraint := range stmt.Constraints {
+		columns := []int{}
+		for _, col := range constraint.Columns {
+			colIdx, ok := colNameToIdx[col.Literal]
+			if !ok {
+				return ErrColumnNotFound
+			}
+			columns = append(columns, colIdx)
+		}
 
-func (mb *MemoryBackend) Insert(stmt *ast.InsertStatement) error {
-	t, ok := mb.tables[stmt.Table.Literal]
-	if !ok {
-		return ErrTableNotFound
+		switch {
+		case constraint.Kind == token.CHECK:
+			// Checks are added once every column is known
+		case constraint.Kind == token.FOREIGN:
+			references = append(references, reference{columns, constraint.References})
+		case constraint.Kind != token.PRIMARY:
+			unique = append(unique, columns)
+		case len(primaryKey) != 0:
+			return ErrMultiplePrimaryKeys
+		default:
+			primaryKey = columns
+		}
 	}
 
-	// Generate colName -> colIndex map
-	colNameToIdx := generateColNameToIndexMap(t.columns)
+	// Primary keys can't be NULL
+	for _, col := range primaryKey {
+		t.columns[col].notNull = true
+	}
 
-	// Allocate a row
-	row := make([]memoryCell, len(t.columns))
+	for _, col := range stmt.Columns {
+		for _, check := range col.Checks {
+			if err := t.addCheck(check, stmt.Table.Literal, col.Name.Literal, "check"); err != nil {
+				return err
+			}
+		}
+	}
+	for _, constraint := range stmt.Constraints {
+		if constraint.Check != nil {
+			if err := t.addCheck(constraint.Check, stmt.Table.Literal, "check"); err != nil {
+				return err
+			}
+		}
+	}
 
-	for i := range stmt.Columns {
-		colName := stmt.Columns[i].Literal
-		value := stmt.Values[i].String()
+	return s.write(func(tx *transaction) error {
+		name := stmt.Table.Literal
 
-		colIdx, ok := colNameToIdx[colName]
-		if !ok {
-			return ErrColumnNotFound
+		// Table names are reserved even before the transaction creating
+		// them commits
+		if _, ok := s.backend.tables[name]; ok {
+			return ErrTableExists
 		}
 
-		cellValue, err := getByteValue(t.columns[colIdx].columnType, value)
-		if err != nil {
-			return err
+		tx.createTable(s.backend, name, t)
+
+		if len(primaryKey) != 0 {
+			idx := newTableIndex(s.backend.indexName(name, "pkey"), btreeIndex, true, primaryKey)
+			idx.primary = true
+			idx.constraint = true
+			tx.createIndex(name, t, idx)
 		}
 
-		row[colIdx] = cellValue
-	}
+		for _, columns := range unique {
+			colNames := []string{}
+			for _, col := range columns {
+				colNames = append(colNames, t.columns[col].name)
+			}
 
-	t.rows = append(t.rows, row)
-	return nil
-}
+			idx := newTableIndex(s.backend.indexName(name, strings.Join(colNames, "_"), "key"), btreeIndex, true, columns)
+			idx.constraint = true
+			tx.createIndex(name, t, idx)
+		}
 
-func (mb *MemoryBackend) Select(stmt *ast.SelectStatement) (*FetchResult, error) {
-	t, ok := mb.tables[stmt.Table.Literal]
-	if !ok {
-		return nil, ErrTableNotFound
-	}
+		for _, r := range references {
+			fk, err := s.backend.foreignKey(tx, t, r.columns, r.ref)
+			if err != nil {
+				return err
+			}
+			t.foreignKeys = append(t.foreignKeys, fk)
+		}
 
-	colNameToIdx := generateColNameToIndexMap(t.columns)
+		return nil
+	})
+}
 
-	resultRows := [][]Cell{}
-	// Set of columns requested
-	columnsMap := map[string]*ResultColumn{}
+func (s *Session) CreateIndex(stmt *ast.CreateIndexStatement) error {
+	return s.write(func(tx *transaction) error {
+		t := s.backend.table(tx, stmt.Table.Literal)
+		if t == nil {
+			return ErrTableNotFound
+		}
 
-	for _, col := range stmt.Columns {
-		var colType ColumnType
-		switch col.Type {
-		case token.ASTERISK:
-			for _, c := range t.columns {
-				columnsMap[c.name] = &ResultColumn{
-					Type: c.columnType,
-					Name: c.name,
-				}
-			}
-			continue
-		default:
-			idx, ok := colNameToIdx[col.Literal]
+		// Like table names, index names are unique across the database
+		if _, _, idx := s.backend.findIndex(stmt.Name.Literal); idx != nil {
+			return ErrIndexExists
+		}
+
+		colNameToIdx := generateColNameToIndexMap(t.columns)
+		columns := []int{}
+		for _, col := range stmt.Columns {
+			colIdx, ok := colNameToIdx[col.Literal]
 			if !ok {
-				return nil, ErrColumnNotFound
+				return ErrColumnNotFound
 			}
+			columns = append(columns, colIdx)
+		}
 
-			colType = t.columns[idx].columnType
+		method := btreeIndex
+		if stmt.Method != nil {
+			switch strings.ToUpper(stmt.Method.Literal) {
+			case "BTREE":
+			case "HASH":
+				method = hashIndex
+			default:
+				return ErrUnknownIndexMethod
+			}
 		}
 
-		columnsMap[col.Literal] = &ResultColumn{
-			Type: colType,
-			Name: col.Literal,
+		idx := newTableIndex(stmt.Name.Literal, method, stmt.Unique, columns)
+		if err := idx.build(t, tx); err != nil {
+			return err
 		}
-	}
 
-	columns := []*ResultColumn{}
-	for _, v := range columnsMap {
-		columns = append(columns, v)
-	}
+		tx.createIndex(stmt.Table.Literal, t, idx)
+		return nil
+	})
+}
 
-	for _, row := range t.rows {
-		res := []Cell{}
-		if stmt.Predicate != nil {
-			if !filterRow(row, t.columns, colNameToIdx, stmt.Predicate) {
-				continue
-			}
+func (s *Session) DropIndex(stmt *ast.DropIndexStatement) error {
+	return s.write(func(tx *transaction) error {
+		name, t, idx := s.backend.findIndex(stmt.Name.Literal)
+		if idx == nil || !tx.visible(t.createdBy) || !tx.visible(idx.createdBy) {
+			return ErrIndexNotFound
 		}
 
-		for _, col := range columns {
-			colIdx, ok := colNameToIdx[col.Name]
+		if idx.constraint {
+			return ErrIndexInUse
+		}
+
+		tx.dropIndex(name, t, idx)
+		return nil
+	})
+}
+
+func (s *Session) Insert(stmt *ast.InsertStatement) error {
+	return s.write(func(tx *transaction) error {
+		t := s.backend.table(tx, stmt.Table.Literal)
+		if t == nil {
+			return ErrTableNotFound
+		}
+
+		// Generate colName -> colIndex map
+		colNameToIdx := generateColNameToIndexMap(t.columns)
+
+		// Allocate a row
+		row := make([]memoryCell, len(t.columns))
+
+		for i := range stmt.Columns {
+			colName := stmt.Columns[i].Literal
+
+			colIdx, ok := colNameToIdx[colName]
 			if !ok {
-				return nil, ErrColumnNotFound
+				return ErrColumnNotFound
+			}
+
+			value, err := evaluator.EvalExpression(stmt.Values[i], nil)
+			if err != nil {
+				return err
 			}
 
-			res = append(res, row[colIdx])
+			cellValue, err := valueCell(t.columns[colIdx].columnType, value)
+			if err != nil {
+				return err
+			}
+
+			row[colIdx] = cellValue
 		}
 
-		resultRows = append(resultRows, res)
-	}
+		return s.backend.insertRow(tx, stmt.Table.Literal, t, row)
+	})
+}
 
-	return &FetchResult{
-		Rows:    resultRows,
-		Columns: columns,
-	}, nil
+func (s *Session) Select(stmt *ast.SelectStatement) (*FetchResult, error) {
+	var result *FetchResult
+	err := s.read(func(tx *transaction) error {
+		ctx := &queryContext{session: s, tx: tx}
+		res, err := ctx.query(stmt)
+		result = res
+		return err
+	})
+
+	return result, err
 }
 
-func (mb *MemoryBackend) Delete(stmt *ast.DeleteStatement) (*UpdateResult, error) {
-	t, ok := mb.tables[stmt.Table.Literal]
-	if !ok {
-		return nil, ErrTableNotFound
-	}
+// tableScan returns a relation with the columns of t, the table called
+// name, which statements that change its rows are evaluated over.
+// Subqueries can refer to them by name.
+func (s *Session) tableScan(tx *transaction, name *token.Token, t *memoryTable) *relation {
+	rel := tableRelation(t, &ast.TableName{Name: name})
+	rel.ctx = &queryContext{session: s, tx: tx}
+	return rel
+}
 
-	colNameToIdx := generateColNameToIndexMap(t.columns)
-	startingRows := len(t.rows)
+func (s *Session) Delete(stmt *ast.DeleteStatement) (*UpdateResult, error) {
+	affectedRows := 0
+	err := s.write(func(tx *transaction) error {
+		t := s.backend.table(tx, stmt.Table.Literal)
+		if t == nil {
+			return ErrTableNotFound
+		}
+
+		colNameToIdx := generateColNameToIndexMap(t.columns)
+		rel := s.tableScan(tx, stmt.Table, t)
+		rows := []*memoryRow{}
 
-	for i := 0; i < len(t.rows); i++ {
-		if stmt.Predicate != nil {
-			if !filterRow(t.rows[i], t.columns, colNameToIdx, stmt.Predicate) {
+		// The scope is shared by the rows of the statement
+		scope := rel.ctx.newScope(rel)
+		for _, row := range t.candidateRows(stmt.Predicate, colNameToIdx) {
+			version := tx.version(row)
+			if version == nil {
 				continue
 			}
+
+			if stmt.Predicate != nil {
+				ok, err := filterRow(scope, rel, version.cells, stmt.Predicate)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					continue
+				}
+			}
+
+			if version.xmax != 0 {
+				return ErrSerializationFailure
+			}
+
+			rows = append(rows, row)
 		}
 
-		t.rows = append(t.rows[:i], t.rows[i+1:]...)
-		i--
+		for _, row := range rows {
+			if err := s.backend.deleteRow(tx, stmt.Table.Literal, t, row); err != nil {
+				return err
+			}
+		}
+
+		affectedRows = len(rows)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &UpdateResult{
-		AffectedRows: startingRows - len(t.rows),
+		AffectedRows: affectedRows,
 	}, nil
 }
 
-func (mb *MemoryBackend) Update(stmt *ast.UpdateStatement) (*UpdateResult, error) {
-	t, ok := mb.tables[stmt.Table.Literal]
-	if !ok {
-		return nil, ErrTableNotFound
-	}
-
-	colNameToIdx := generateColNameToIndexMap(t.columns)
+func (s *Session) Update(stmt *ast.UpdateStatement) (*UpdateResult, error) {
 	affectedRows := 0
+	err := s.write(func(tx *transaction) error {
+		t := s.backend.table(tx, stmt.Table.Literal)
+		if t == nil {
+			return ErrTableNotFound
+		}
+
+		colNameToIdx := generateColNameToIndexMap(t.columns)
+		rel := s.tableScan(tx, stmt.Table, t)
+
+		// Nothing is changed until every row has been updated successfully
+		rows := []*memoryRow{}
+		updates := [][]memoryCell{}
 
-	for _, row := range t.rows {
-		if stmt.Predicate != nil {
-			if !filterRow(row, t.columns, colNameToIdx, stmt.Predicate) {
+		// The scope is shared by the rows of the statement
+		scope := rel.ctx.newScope(rel)
+		for _, row := range t.candidateRows(stmt.Predicate, colNameToIdx) {
+			version := tx.version(row)
+			if version == nil {
 				continue
 			}
-		}
 
-		for _, col := range stmt.Update {
-			colName := col[0].Literal
-			value := col[1].Literal
-			colIdx, ok := colNameToIdx[colName]
-			if !ok {
-				return nil, ErrColumnNotFound
+			if stmt.Predicate != nil {
+				ok, err := filterRow(scope, rel, version.cells, stmt.Predicate)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					continue
+				}
 			}
 
-			fmt.Println(colName, value)
+			// Another transaction has changed the row since our snapshot
+			// was taken
+			if version.xmax != 0 {
+				return ErrSerializationFailure
+			}
 
-			cellValue, err := getByteValue(t.columns[colIdx].columnType, value)
-			if err != nil {
-				return nil, err
+			cells := make([]memoryCell, len(version.cells))
+			copy(cells, version.cells)
+
+			// New values are computed from the row as it was before the
+			// update
+			rel.bind(scope, version.cells)
+			for _, set := range stmt.Update {
+				colName := set.Column.Literal
+				colIdx, ok := colNameToIdx[colName]
+				if !ok {
+					return ErrColumnNotFound
+				}
+
+				value, err := evaluator.EvalExpression(set.Value, scope)
+				if err != nil {
+					return err
+				}
+
+				cellValue, err := valueCell(t.columns[colIdx].columnType, value)
+				if err != nil {
+					return err
+				}
+
+				cells[colIdx] = cellValue
 			}
 
-			row[colIdx] = cellValue
-			affectedRows++
+			rows = append(rows, row)
+			updates = append(updates, cells)
 		}
+
+		for i, row := range rows {
+			if err := s.backend.updateRow(tx, stmt.Table.Literal, t, row, updates[i]); err != nil {
+				return err
+			}
+		}
+
+		affectedRows = len(rows)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &UpdateResult{
@@ -271,85 +576,58 @@ func generateColNameToIndexMap(columns []*tableColumn) map[string]int {
 	return colNameToIdx
 }
 
-func filterRow(row []memoryCell, columns []*tableColumn, colNameToIdx map[string]int, predicate ast.Expression) bool {
+// rowScope makes the cells of row available to expressions under the
+// names of their columns.
+func rowScope(row []memoryCell, columns []*tableColumn, colNameToIdx map[string]int) *evaluator.Scope {
 	scope := evaluator.NewScope()
+	bindRow(scope, row, columns, colNameToIdx)
+	return scope
+}
+
+// bindRow sets the variables of scope to the cells of row, replacing the
+// ones of the row it was bound to before.
+func bindRow(scope *evaluator.Scope, row []memoryCell, columns []*tableColumn, colNameToIdx map[string]int) {
 	for _, col := range columns {
 		colIdx, ok := colNameToIdx[col.name]
 		if !ok {
 			continue
 		}
 
-		// skip null values
-		if row[colIdx] == nil {
-			continue
-		}
-
-		switch col.columnType {
-		case INT_COLUMN:
-			scope.SetVar(col.name, &ast.IntegerLiteral{Value: row[colIdx].AsInt()})
-		case FLOAT_COLUMN:
-			scope.SetVar(col.name, &ast.FloatLiteral{Value: row[colIdx].AsFloat()})
-		case TEXT_COLUMN:
-			scope.SetVar(col.name, &ast.StringLiteral{Value: row[colIdx].AsText()})
-		}
+		scope.SetVar(col.name, row[colIdx].Value(col.columnType))
 	}
+}
 
-	expr, err := evaluator.EvalExpression(predicate, scope)
+// filterRow reports whether the predicate holds for row, a row of rel.
+func filterRow(scope *evaluator.Scope, rel *relation, row []memoryCell, predicate ast.Expression) (bool, error) {
+	rel.bind(scope, row)
+	value, err := evaluator.EvalExpression(predicate, scope)
 	if err != nil {
-		return false
+		return false, err
 	}
 
-	if expr.Type() == ast.BOOLEAN {
-		return expr.(*ast.Boolean).Value
-	}
-
-	// Check if the result is truthy
-	switch e := expr.(type) {
-	case *ast.IntegerLiteral:
-		return e.Value != 0
-	case *ast.FloatLiteral:
-		return e.Value != 0
-	case *ast.StringLiteral:
-		return e.Value != ""
-	}
-
-	// Finally return false
-	return false
+	return evaluator.Truthy(value), nil
 }
 
-func getByteValue(colType ColumnType, value string) (memoryCell, error) {
-	var cellValue memoryCell
-
-	switch colType {
-	case TEXT_COLUMN:
-		cellValue = []byte(value)
-	case INT_COLUMN:
-		i, err := strconv.ParseInt(value, 10, 64)
-		if err != nil {
-			return nil, ErrInvalidDataType
-		}
-
-		buf := new(bytes.Buffer)
-		err = binary.Write(buf, binary.BigEndian, i)
-		if err != nil {
-			panic(err)
+// valueCell converts v to a cell of a column of colType. NULL is stored
+// as a missing cell.
+func valueCell(colType ColumnType, v evaluator.Value) (memoryCell, error) {
+	switch v := v.(type) {
+	case *evaluator.Null:
+		return nil, nil
+	case *evaluator.Int:
+		if colType == INT_COLUMN {
+			return binary.BigEndian.AppendUint64(nil, uint64(v.Value)), nil
 		}
-
-		cellValue = buf.Bytes()
-	case FLOAT_COLUMN:
-		f, err := strconv.ParseFloat(value, 64)
-		if err != nil {
-			return nil, ErrInvalidDataType
-		}
-
-		buf := new(bytes.Buffer)
-		err = binary.Write(buf, binary.BigEndian, f)
-		if err != nil {
-			panic(err)
+	case *evaluator.Text:
+		if colType == TEXT_COLUMN {
+			return memoryCell(v.Value), nil
 		}
+	}
 
-		cellValue = buf.Bytes()
+	// Integers are widened to fit FLOAT columns
+	if f, ok := evaluator.ToFloat(v); ok && colType == FLOAT_COLUMN {
+		return binary.BigEndian.AppendUint64(nil, math.Float64bits(f.Value)), nil
 	}
 
-	return cellValue, nil
+	return nil, ErrInvalidDataType
 }
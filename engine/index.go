@@ -0,0 +1,603 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
+	"jnafolayan/sql-db/lib"
+	"sort"
+	"strings"
+)
+
+const btreeDegree = 32
+
+type indexMethod byte
+
+const (
+	// btreeIndex keeps keys sorted so it can answer range predicates
+	btreeIndex indexMethod = iota
+	// hashIndex only answers equality predicates, in constant time
+	hashIndex
+)
+
+// tableIndex is a secondary index over one or more columns of a table. It
+// maps the encoded values of those columns to the rows holding them.
+// Every version of a row that is still around has an entry, so callers
+// must check the version they see against their predicate.
+type tableIndex struct {
+	name    string
+	method  indexMethod
+	unique  bool
+	columns []int
+	// primary is set for the index enforcing the table's primary key, and
+	// constraint for every index created by a table constraint
+	primary    bool
+	constraint bool
+	// tree is keyed by the encoded values followed by the row ID
+	tree *lib.BTree[*memoryRow]
+	// buckets is keyed by the encoded values alone
+	buckets map[string][]*memoryRow
+	// createdBy is the transaction that created the index until it
+	// commits, droppedBy the one dropping it until then
+	createdBy uint64
+	droppedBy uint64
+}
+
+func newTableIndex(name string, method indexMethod, unique bool, columns []int) *tableIndex {
+	idx := &tableIndex{
+		name:    name,
+		method:  method,
+		unique:  unique,
+		columns: columns,
+	}
+
+	switch method {
+	case hashIndex:
+		idx.buckets = map[string][]*memoryRow{}
+	default:
+		idx.tree = lib.NewBTree[*memoryRow](btreeDegree)
+	}
+
+	return idx
+}
+
+// add records that row has a version with the given key.
+func (idx *tableIndex) add(key []byte, row *memoryRow) {
+	if idx.method == btreeIndex {
+		idx.tree.Set(entryKey(key, row.id), row)
+		return
+	}
+
+	bucket := idx.buckets[string(key)]
+	for _, r := range bucket {
+		if r == row {
+			return
+		}
+	}
+	idx.buckets[string(key)] = append(bucket, row)
+}
+
+func (idx *tableIndex) remove(key []byte, row *memoryRow) {
+	if idx.method == btreeIndex {
+		idx.tree.Delete(entryKey(key, row.id))
+		return
+	}
+
+	bucket := idx.buckets[string(key)]
+	for i, r := range bucket {
+		if r == row {
+			bucket = append(bucket[:i:i], bucket[i+1:]...)
+			break
+		}
+	}
+
+	if len(bucket) == 0 {
+		delete(idx.buckets, string(key))
+	} else {
+		idx.buckets[string(key)] = bucket
+	}
+}
+
+// lookup calls fn for every row with a version whose key is key, until fn
+// returns false.
+func (idx *tableIndex) lookup(key []byte, fn func(*memoryRow) bool) {
+	if idx.method == hashIndex {
+		for _, row := range idx.buckets[string(key)] {
+			if !fn(row) {
+				return
+			}
+		}
+		return
+	}
+
+	idx.tree.Ascend(key, func(entry []byte, row *memoryRow) bool {
+		if !bytes.Equal(entry[:len(entry)-8], key) {
+			return false
+		}
+		return fn(row)
+	})
+}
+
+// key encodes the indexed cells so that comparing keys bytewise orders
+// them like the values themselves.
+func (idx *tableIndex) key(t *memoryTable, cells []memoryCell) []byte {
+	key := []byte{}
+	for _, col := range idx.columns {
+		key = appendKeyCell(key, t.columns[col].columnType, cells[col])
+	}
+	return key
+}
+
+// hasNull reports whether any of the indexed cells is NULL. NULLs are
+// never equal to each other, so they don't count as duplicates.
+func (idx *tableIndex) hasNull(cells []memoryCell) bool {
+	for _, col := range idx.columns {
+		if cells[col] == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// build indexes every version of every row of t. If tx is set, it also
+// checks that the rows visible to tx don't break the index's uniqueness.
+func (idx *tableIndex) build(t *memoryTable, tx *transaction) error {
+	seen := map[string]bool{}
+	for _, row := range t.rows {
+		for v := row.version; v != nil; v = v.prev {
+			idx.add(idx.key(t, v.cells), row)
+		}
+
+		if tx == nil || !idx.unique {
+			continue
+		}
+
+		v := tx.version(row)
+		if v == nil || idx.hasNull(v.cells) {
+			continue
+		}
+
+		key := string(idx.key(t, v.cells))
+		if seen[key] {
+			return ErrUniqueViolation
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+func entryKey(key []byte, rowID int64) []byte {
+	entry := make([]byte, len(key)+8)
+	copy(entry, key)
+	binary.BigEndian.PutUint64(entry[len(key):], uint64(rowID))
+	return entry
+}
+
+// appendKeyCell appends an order preserving encoding of cell to key. NULL
+// sorts before any value, and every encoding is self-delimiting so keys
+// made of several columns compare column by column.
+func appendKeyCell(key []byte, colType ColumnType, cell memoryCell) []byte {
+	if cell == nil {
+		return append(key, 0)
+	}
+	key = append(key, 1)
+
+	switch colType {
+	case INT_COLUMN:
+		// Flip the sign bit so negative numbers sort first
+		return binary.BigEndian.AppendUint64(key, binary.BigEndian.Uint64(cell)^(1<<63))
+	case FLOAT_COLUMN:
+		bits := binary.BigEndian.Uint64(cell)
+		if bits&(1<<63) != 0 {
+			bits = ^bits
+		} else {
+			bits |= 1 << 63
+		}
+		return binary.BigEndian.AppendUint64(key, bits)
+	default:
+		// Escape zero bytes so the terminator sorts before any content
+		for _, b := range cell {
+			if b == 0 {
+				key = append(key, 0, 0xff)
+			} else {
+				key = append(key, b)
+			}
+		}
+		return append(key, 0, 0)
+	}
+}
+
+// index adds entries for a version of row to every index of t.
+func (t *memoryTable) index(row *memoryRow, cells []memoryCell) {
+	for _, idx := range t.indexes {
+		idx.add(idx.key(t, cells), row)
+	}
+}
+
+// unindex removes the entries for a version of row that is no longer part
+// of it, unless a remaining version has the same key.
+func (t *memoryTable) unindex(row *memoryRow, cells []memoryCell) {
+	for _, idx := range t.indexes {
+		key := idx.key(t, cells)
+
+		shared := false
+		for v := row.version; v != nil && !shared; v = v.prev {
+			shared = bytes.Equal(idx.key(t, v.cells), key)
+		}
+
+		if !shared {
+			idx.remove(key, row)
+		}
+	}
+}
+
+func (t *memoryTable) removeIndex(index *tableIndex) {
+	indexes := []*tableIndex{}
+	for _, idx := range t.indexes {
+		if idx != index {
+			indexes = append(indexes, idx)
+		}
+	}
+	t.indexes = indexes
+}
+
+// checkUnique returns an error if giving row the cells would duplicate the
+// key of another row in one of the unique indexes of t. row is nil for
+// rows that are being inserted.
+func (t *memoryTable) checkUnique(tx *transaction, row *memoryRow, cells []memoryCell) error {
+	for _, idx := range t.indexes {
+		if !idx.unique || idx.hasNull(cells) {
+			continue
+		}
+
+		var err error
+		key := idx.key(t, cells)
+		idx.lookup(key, func(other *memoryRow) bool {
+			if other == row {
+				return true
+			}
+
+			if v := tx.version(other); v != nil {
+				if bytes.Equal(idx.key(t, v.cells), key) {
+					err = ErrUniqueViolation
+				}
+				return err == nil
+			}
+
+			// The key may belong to a version the snapshot can't see,
+			// written by a transaction that's running or has committed
+			// since
+			v := other.version
+			if v != nil && v.xmax == 0 && bytes.Equal(idx.key(t, v.cells), key) {
+				err = ErrSerializationFailure
+			}
+			return err == nil
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexCondition is a comparison between a column and literals that
+// must hold for a predicate to be true. IN conditions hold several values.
+type indexCondition struct {
+	column int
+	op     string
+	values []memoryCell
+}
+
+func (t *memoryTable) indexConditions(predicate ast.Expression, colNameToIdx map[string]int) []*indexCondition {
+	if in, ok := predicate.(*ast.InExpression); ok {
+		return t.inCondition(in, colNameToIdx)
+	}
+
+	infix, ok := predicate.(*ast.InfixExpression)
+	if !ok {
+		return nil
+	}
+
+	op := strings.ToUpper(infix.Operator)
+	switch op {
+	case "AND":
+		left := t.indexConditions(infix.Left, colNameToIdx)
+		return append(left, t.indexConditions(infix.Right, colNameToIdx)...)
+	case "=", "<", ">":
+	default:
+		return nil
+	}
+
+	ident, ok := infix.Left.(*ast.Identifier)
+	literal := infix.Right
+	if !ok {
+		// literal op column
+		ident, ok = infix.Right.(*ast.Identifier)
+		literal = infix.Left
+		if !ok {
+			return nil
+		}
+
+		switch op {
+		case "<":
+			op = ">"
+		case ">":
+			op = "<"
+		}
+	}
+
+	col, ok := indexColumn(ident, colNameToIdx)
+	if !ok {
+		return nil
+	}
+
+	value, ok := literalCell(t.columns[col].columnType, literal)
+	if !ok {
+		return nil
+	}
+
+	return []*indexCondition{{column: col, op: op, values: []memoryCell{value}}}
+}
+
+func (t *memoryTable) inCondition(in *ast.InExpression, colNameToIdx map[string]int) []*indexCondition {
+	ident, ok := in.Left.(*ast.Identifier)
+	if !ok || in.Not || in.Subquery != nil {
+		return nil
+	}
+
+	col, ok := indexColumn(ident, colNameToIdx)
+	if !ok {
+		return nil
+	}
+
+	cond := &indexCondition{column: col, op: "IN"}
+	for _, literal := range in.Values {
+		value, ok := literalCell(t.columns[col].columnType, literal)
+		if !ok {
+			return nil
+		}
+		cond.values = append(cond.values, value)
+	}
+
+	return []*indexCondition{cond}
+}
+
+// indexColumn returns the position of the column ident refers to. Names
+// qualified by a table are only found if colNameToIdx has them, as they
+// may refer to another table.
+func indexColumn(ident *ast.Identifier, colNameToIdx map[string]int) (int, bool) {
+	name := ident.Value
+	if ident.Table != nil {
+		name = ident.Table.Literal + "." + name
+	}
+
+	col, ok := colNameToIdx[name]
+	return col, ok
+}
+
+// literalCell encodes a constant expression, such as a literal, for
+// comparison with a column of colType. Only values that can be stored in
+// the column can be compared with it.
+func literalCell(colType ColumnType, expr ast.Expression) (memoryCell, bool) {
+	// Expressions that refer to columns can't be evaluated without a scope
+	value, err := evaluator.EvalExpression(expr, nil)
+	if err != nil {
+		return nil, false
+	}
+
+	cell, err := valueCell(colType, value)
+	if err != nil || cell == nil {
+		return nil, false
+	}
+	return cell, true
+}
+
+// maxIndexProbes limits how many keys a scan built from IN lists may look
+// up before reading the whole table is considered cheaper.
+const maxIndexProbes = 1024
+
+// indexScan reads the rows whose leading indexed columns are equal to one
+// of prefixes and whose next column lies between two exclusive bounds.
+type indexScan struct {
+	index *tableIndex
+	// equal is the number of columns in each prefix
+	equal    int
+	prefixes [][]byte
+	lower    []byte
+	upper    []byte
+}
+
+func (idx *tableIndex) plan(t *memoryTable, conditions []*indexCondition) *indexScan {
+	scan := &indexScan{index: idx, prefixes: [][]byte{{}}}
+
+	for _, col := range idx.columns {
+		var eq *indexCondition
+		for _, cond := range conditions {
+			if cond.column == col && (cond.op == "=" || cond.op == "IN") {
+				eq = cond
+				break
+			}
+		}
+		if eq == nil || len(scan.prefixes)*len(eq.values) > maxIndexProbes {
+			break
+		}
+
+		prefixes := [][]byte{}
+		for _, prefix := range scan.prefixes {
+			for _, value := range eq.values {
+				key := append([]byte{}, prefix...)
+				prefixes = append(prefixes, appendKeyCell(key, t.columns[col].columnType, value))
+			}
+		}
+		scan.prefixes = prefixes
+		scan.equal++
+	}
+
+	// Hash indexes can only look up whole keys
+	if idx.method == hashIndex {
+		if scan.equal < len(idx.columns) {
+			return nil
+		}
+		return scan
+	}
+
+	if scan.equal < len(idx.columns) {
+		col := idx.columns[scan.equal]
+		for _, cond := range conditions {
+			if cond.column != col {
+				continue
+			}
+
+			bound := appendKeyCell(nil, t.columns[col].columnType, cond.values[0])
+			switch cond.op {
+			case ">":
+				if scan.lower == nil || bytes.Compare(bound, scan.lower) > 0 {
+					scan.lower = bound
+				}
+			case "<":
+				if scan.upper == nil || bytes.Compare(bound, scan.upper) < 0 {
+					scan.upper = bound
+				}
+			}
+		}
+	}
+
+	if scan.equal == 0 && scan.lower == nil && scan.upper == nil {
+		return nil
+	}
+	return scan
+}
+
+// better reports whether s narrows the rows down more than other.
+func (s *indexScan) better(other *indexScan) bool {
+	if s.equal != other.equal {
+		return s.equal > other.equal
+	}
+
+	bounded := s.lower != nil && s.upper != nil
+	otherBounded := other.lower != nil && other.upper != nil
+	if bounded != otherBounded {
+		return bounded
+	}
+
+	return s.index.method == hashIndex && other.index.method != hashIndex
+}
+
+func (s *indexScan) rows() []*memoryRow {
+	seen := map[*memoryRow]bool{}
+	rows := []*memoryRow{}
+	collect := func(row *memoryRow) bool {
+		if !seen[row] {
+			seen[row] = true
+			rows = append(rows, row)
+		}
+		return true
+	}
+
+	for _, prefix := range s.prefixes {
+		if s.index.method == hashIndex {
+			s.index.lookup(prefix, collect)
+		} else {
+			s.scanRange(prefix, collect)
+		}
+	}
+
+	// Keep the order rows would be scanned in without the index
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].id < rows[j].id
+	})
+	return rows
+}
+
+func (s *indexScan) scanRange(prefix []byte, fn func(*memoryRow) bool) {
+	start := prefix
+	if s.lower != nil {
+		start = append(append([]byte{}, prefix...), s.lower...)
+	}
+
+	s.index.tree.Ascend(start, func(entry []byte, row *memoryRow) bool {
+		if !bytes.HasPrefix(entry, prefix) {
+			return false
+		}
+
+		rest := entry[len(prefix):]
+		if s.lower != nil && bytes.HasPrefix(rest, s.lower) {
+			// equal to the lower bound
+			return true
+		}
+		if s.upper != nil && bytes.Compare(rest, s.upper) >= 0 {
+			return false
+		}
+
+		return fn(row)
+	})
+}
+
+// candidateRows returns the rows of t that may match predicate, in
+// insertion order. When an index covers part of the predicate, only the
+// rows it points to are returned.
+func (t *memoryTable) candidateRows(predicate ast.Expression, colNameToIdx map[string]int) []*memoryRow {
+	if best := t.bestScan(predicate, colNameToIdx); best != nil {
+		return best.rows()
+	}
+	return t.rows
+}
+
+// bestScan returns the index scan that narrows down the rows matching
+// predicate the most, or nil if no index covers it.
+func (t *memoryTable) bestScan(predicate ast.Expression, colNameToIdx map[string]int) *indexScan {
+	if predicate == nil || len(t.indexes) == 0 {
+		return nil
+	}
+
+	conditions := t.indexConditions(predicate, colNameToIdx)
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	var best *indexScan
+	for _, idx := range t.indexes {
+		scan := idx.plan(t, conditions)
+		if scan != nil && (best == nil || scan.better(best)) {
+			best = scan
+		}
+	}
+	return best
+}
+
+// orderedRows returns the versions of the rows of t visible to tx, in the
+// order of the index's keys, or in reverse if descending is set.
+func (idx *tableIndex) orderedRows(t *memoryTable, tx *transaction, descending bool) [][]memoryCell {
+	rows := [][]memoryCell{}
+	visit := func(entry []byte, row *memoryRow) bool {
+		// Rows have an entry for each of their versions, and only the
+		// one for the visible version is kept
+		v := tx.version(row)
+		if v != nil && bytes.Equal(idx.key(t, v.cells), entry[:len(entry)-8]) {
+			rows = append(rows, v.cells)
+		}
+		return true
+	}
+
+	if descending {
+		idx.tree.Descend(visit)
+	} else {
+		idx.tree.Ascend(nil, visit)
+	}
+	return rows
+}
+
+// findIndex returns the index called name and the table it belongs to.
+// Indexes that are being dropped can't be found.
+func (mb *MemoryBackend) findIndex(name string) (string, *memoryTable, *tableIndex) {
+	for tableName, t := range mb.tables {
+		for _, idx := range t.indexes {
+			if idx.name == name && idx.droppedBy == 0 {
+				return tableName, t, idx
+			}
+		}
+	}
+	return "", nil, nil
+}
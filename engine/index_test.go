@@ -0,0 +1,260 @@
+package engine
+
+import (
+	"fmt"
+	"jnafolayan/sql-db/ast"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndexScan(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT, balance FLOAT)")
+	for i := 0; i < 100; i++ {
+		execStatement(t, mb, fmt.Sprintf("INSERT INTO people (name, age, balance) VALUES ('person %d', %d, %d.5)", i%10, i, i))
+	}
+	execStatement(t, mb, "CREATE INDEX people_age ON people (age)")
+	execStatement(t, mb, "CREATE INDEX people_name_balance ON people (name, balance)")
+
+	tests := []struct {
+		predicate  string
+		candidates int
+		matches    int
+	}{
+		{"age=42", 1, 1},
+		{"42=age", 1, 1},
+		{"age>89", 10, 10},
+		{"age<10", 10, 10},
+		{"age>10 AND age<20", 9, 9},
+		{"5<age AND age<10 AND name='person 7'", 10, 1},
+		{"name='person 3'", 10, 10},
+		{"name='person 3' AND balance>50.0", 5, 5},
+		{"name='person 3' AND balance<40.0", 4, 4},
+		{"balance<50.0", 100, 50},
+		{"age=42.0", 100, 1},
+		{"age='42'", 100, 1},
+		{"name='person 3' AND balance>50", 5, 5},
+	}
+
+	for _, tt := range tests {
+		input := "SELECT name FROM people WHERE " + tt.predicate
+		stmt := parseStatement(t, input).(*ast.SelectStatement)
+
+		table := mb.tables["people"]
+		rows := table.candidateRows(stmt.Predicate, generateColNameToIndexMap(table.columns))
+		if len(rows) != tt.candidates {
+			t.Errorf("%s: expected %d candidate rows, got %d", tt.predicate, tt.candidates, len(rows))
+		}
+		for i := 1; i < len(rows); i++ {
+			if rows[i-1].id >= rows[i].id {
+				t.Errorf("%s: expected candidate rows in insertion order", tt.predicate)
+				break
+			}
+		}
+
+		expectRowCount(t, mb, input, tt.matches)
+	}
+
+	// Entries follow the rows through updates and deletes
+	execStatement(t, mb, "UPDATE people SET age=1000 WHERE age=42")
+	execStatement(t, mb, "DELETE FROM people WHERE age<10")
+	expectRowCount(t, mb, "SELECT name FROM people WHERE age=42", 0)
+	expectRowCount(t, mb, "SELECT name FROM people WHERE age>999", 1)
+	expectRowCount(t, mb, "SELECT name FROM people WHERE age<20", 10)
+	if n := mb.tables["people"].indexes[0].tree.Len(); n != 90 {
+		t.Errorf("expected 90 index entries, got %d", n)
+	}
+
+	execStatement(t, mb, "DROP INDEX people_age")
+	if len(mb.tables["people"].indexes) != 1 {
+		t.Fatalf("expected 1 index, got %d", len(mb.tables["people"].indexes))
+	}
+	expectRowCount(t, mb, "SELECT name FROM people WHERE age<20", 10)
+}
+
+func TestHashIndex(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (email TEXT, age INT)")
+	for i := 0; i < 100; i++ {
+		execStatement(t, mb, fmt.Sprintf("INSERT INTO people (email, age) VALUES ('person%d@example.com', %d)", i, i%10))
+	}
+	execStatement(t, mb, "CREATE UNIQUE INDEX people_email ON people USING HASH (email)")
+	execStatement(t, mb, "CREATE INDEX people_age ON people USING BTREE (age)")
+
+	tests := []struct {
+		predicate  string
+		candidates int
+		matches    int
+	}{
+		{"email='person42@example.com'", 1, 1},
+		{"email='nobody@example.com'", 0, 0},
+		{"email IN ('person1@example.com', 'person2@example.com', 'nobody@example.com')", 2, 2},
+		{"email IN ('person1@example.com', 'person1@example.com')", 1, 1},
+		{"age IN (1, 2) AND email IN ('person1@example.com', 'person3@example.com')", 2, 1},
+		{"age IN (1, 2)", 20, 20},
+		{"email='person42@example.com' OR age=1", 100, 11},
+	}
+
+	for _, tt := range tests {
+		input := "SELECT email FROM people WHERE " + tt.predicate
+		stmt := parseStatement(t, input).(*ast.SelectStatement)
+
+		table := mb.tables["people"]
+		rows := table.candidateRows(stmt.Predicate, generateColNameToIndexMap(table.columns))
+		if len(rows) != tt.candidates {
+			t.Errorf("%s: expected %d candidate rows, got %d", tt.predicate, tt.candidates, len(rows))
+		}
+
+		expectRowCount(t, mb, input, tt.matches)
+	}
+
+	execStatement(t, mb, "UPDATE people SET email='someone@example.com' WHERE email='person42@example.com'")
+	expectRowCount(t, mb, "SELECT email FROM people WHERE email='person42@example.com'", 0)
+	expectRowCount(t, mb, "SELECT email FROM people WHERE email='someone@example.com'", 1)
+
+	insert := parseStatement(t, "INSERT INTO people (email, age) VALUES ('someone@example.com', 1)").(*ast.InsertStatement)
+	if err := mb.Insert(insert); err != ErrUniqueViolation {
+		t.Fatalf("expected %q error, got %v", ErrUniqueViolation, err)
+	}
+
+	execStatement(t, mb, "DELETE FROM people WHERE email IN ('someone@example.com', 'person1@example.com')")
+	if n := len(mb.tables["people"].indexes[0].buckets); n != 98 {
+		t.Errorf("expected 98 hash buckets, got %d", n)
+	}
+
+	create := parseStatement(t, "CREATE INDEX people_age_2 ON people USING GIST (age)").(*ast.CreateIndexStatement)
+	if err := mb.CreateIndex(create); err != ErrUnknownIndexMethod {
+		t.Fatalf("expected %q error, got %v", ErrUnknownIndexMethod, err)
+	}
+}
+
+func TestUniqueIndex(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT)")
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('John', 40)")
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('Julia', 40)")
+
+	// Existing rows must already be unique
+	create := parseStatement(t, "CREATE UNIQUE INDEX people_age ON people (age)").(*ast.CreateIndexStatement)
+	if err := mb.CreateIndex(create); err != ErrUniqueViolation {
+		t.Fatalf("expected %q error, got %v", ErrUniqueViolation, err)
+	}
+	if len(mb.tables["people"].indexes) != 0 {
+		t.Fatalf("expected the index not to be created")
+	}
+
+	execStatement(t, mb, "CREATE UNIQUE INDEX people_name ON people (name)")
+	if err := mb.CreateIndex(parseStatement(t, "CREATE INDEX people_name ON people (age)").(*ast.CreateIndexStatement)); err != ErrIndexExists {
+		t.Fatalf("expected %q error, got %v", ErrIndexExists, err)
+	}
+
+	insert := parseStatement(t, "INSERT INTO people (name, age) VALUES ('John', 20)").(*ast.InsertStatement)
+	if err := mb.Insert(insert); err != ErrUniqueViolation {
+		t.Fatalf("expected %q error, got %v", ErrUniqueViolation, err)
+	}
+
+	// NULLs are never duplicates
+	execStatement(t, mb, "INSERT INTO people (age) VALUES (20)")
+	execStatement(t, mb, "INSERT INTO people (age) VALUES (30)")
+
+	update := parseStatement(t, "UPDATE people SET name='John' WHERE age=40").(*ast.UpdateStatement)
+	if _, err := mb.Update(update); err != ErrUniqueViolation {
+		t.Fatalf("expected %q error, got %v", ErrUniqueViolation, err)
+	}
+	expectRowCount(t, mb, "SELECT name FROM people WHERE name='Julia'", 1)
+
+	// A failed statement leaves the rest of the transaction in place
+	mb.Begin()
+	execStatement(t, mb, "DELETE FROM people WHERE name='John'")
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('John', 41)")
+	if _, err := mb.Update(update); err != ErrUniqueViolation {
+		t.Fatalf("expected %q error, got %v", ErrUniqueViolation, err)
+	}
+	mb.Commit()
+	expectRowCount(t, mb, "SELECT name FROM people WHERE name='John'", 1)
+	expectRowCount(t, mb, "SELECT name FROM people WHERE age=41", 1)
+
+	// Keys written by running transactions are taken
+	other := mb.NewSession()
+	other.Begin()
+	execStatement(t, other, "INSERT INTO people (name, age) VALUES ('Jake', 50)")
+	insert = parseStatement(t, "INSERT INTO people (name, age) VALUES ('Jake', 51)").(*ast.InsertStatement)
+	if err := mb.Insert(insert); err != ErrSerializationFailure {
+		t.Fatalf("expected %q error, got %v", ErrSerializationFailure, err)
+	}
+	other.Rollback()
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('Jake', 51)")
+}
+
+func TestIndexRollback(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT)")
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('John', 40)")
+	execStatement(t, mb, "CREATE INDEX people_age ON people (age)")
+
+	mb.Begin()
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('Julia', 30)")
+	execStatement(t, mb, "UPDATE people SET age=41 WHERE age=40")
+	execStatement(t, mb, "CREATE INDEX people_name ON people (name)")
+	execStatement(t, mb, "DROP INDEX people_age")
+	mb.Rollback()
+
+	table := mb.tables["people"]
+	if len(table.indexes) != 1 || table.indexes[0].name != "people_age" {
+		t.Fatalf("expected only people_age to remain")
+	}
+	if n := table.indexes[0].tree.Len(); n != 1 {
+		t.Errorf("expected 1 index entry, got %d", n)
+	}
+	expectRowCount(t, mb, "SELECT name FROM people WHERE age=40", 1)
+
+	// Dropped indexes stay usable until the drop commits
+	reader := mb.NewSession()
+	reader.Begin()
+	execStatement(t, mb, "DROP INDEX people_age")
+	drop := parseStatement(t, "DROP INDEX people_age").(*ast.DropIndexStatement)
+	if err := mb.DropIndex(drop); err != ErrIndexNotFound {
+		t.Fatalf("expected %q error, got %v", ErrIndexNotFound, err)
+	}
+	expectRowCount(t, reader, "SELECT name FROM people WHERE age=40", 1)
+	reader.Commit()
+}
+
+func TestIndexPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	fb, err := NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("error opening database: %s", err)
+	}
+	execStatement(t, fb, "CREATE TABLE people (name TEXT, age INT)")
+	execStatement(t, fb, "INSERT INTO people (name, age) VALUES ('John', 40)")
+	execStatement(t, fb, "CREATE UNIQUE INDEX people_name ON people USING HASH (name)")
+	execStatement(t, fb, "CREATE INDEX people_age ON people (age)")
+	fb.Checkpoint()
+
+	// These only make it to the log
+	execStatement(t, fb, "INSERT INTO people (name, age) VALUES ('Julia', 30)")
+	execStatement(t, fb, "DROP INDEX people_age")
+	fb.wal.close()
+
+	fb, err = NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("error reopening database: %s", err)
+	}
+	defer fb.Close()
+
+	table := fb.tables["people"]
+	idx := table.indexes[0]
+	if len(table.indexes) != 1 || idx.name != "people_name" || !idx.unique || idx.method != hashIndex {
+		t.Fatalf("expected the unique people_name hash index to be restored")
+	}
+	if n := len(idx.buckets); n != 2 {
+		t.Errorf("expected 2 hash buckets, got %d", n)
+	}
+
+	insert := parseStatement(t, "INSERT INTO people (name, age) VALUES ('Julia', 20)").(*ast.InsertStatement)
+	if err := fb.Insert(insert); err != ErrUniqueViolation {
+		t.Fatalf("expected %q error, got %v", ErrUniqueViolation, err)
+	}
+}
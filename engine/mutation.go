@@ -0,0 +1,180 @@
+package engine
+
+type mutationKind byte
+
+const (
+	createTableMutation mutationKind = iota + 1
+	insertMutation
+	updateMutation
+	deleteMutation
+	createIndexMutation
+	dropIndexMutation
+)
+
+// mutation is a single change to the tables. Statements are turned into a
+// list of mutations which is journaled and then applied as a whole, so a
+// statement either changes everything it matched or nothing at all.
+type mutation struct {
+	kind  mutationKind
+	table string
+	rowID int64
+	cells []memoryCell
+
+	// schema holds the columns of a created table
+	schema *memoryTable
+	// index is the created or dropped index
+	index *tableIndex
+}
+
+// journal persists the mutations of a transaction before it commits.
+// committed is called once the transaction's changes are visible.
+type journal interface {
+	append([]*mutation) error
+	committed()
+}
+
+// applyMutations replays journaled mutations on tables that aren't in use
+// by any transaction yet.
+func applyMutations(tables MemoryTables, mutations []*mutation) error {
+	// Updates and deletes are applied in a single pass over each table once
+	// the other mutations are in place
+	updated := map[string]map[int64][]memoryCell{}
+	deleted := map[string]map[int64]bool{}
+
+	for _, m := range mutations {
+		if m.kind == createTableMutation {
+			if _, ok := tables[m.table]; ok {
+				return ErrTableExists
+			}
+			tables[m.table] = &memoryTable{
+				columns:     m.schema.columns,
+				foreignKeys: m.schema.foreignKeys,
+				checks:      m.schema.checks,
+			}
+			continue
+		}
+
+		t, ok := tables[m.table]
+		if !ok {
+			return ErrTableNotFound
+		}
+
+		switch m.kind {
+		case insertMutation:
+			row := &memoryRow{
+				id:      m.rowID,
+				version: &rowVersion{cells: m.cells},
+			}
+			t.rows = append(t.rows, row)
+			t.index(row, m.cells)
+			if m.rowID > t.lastRowID {
+				t.lastRowID = m.rowID
+			}
+		case updateMutation:
+			if updated[m.table] == nil {
+				updated[m.table] = map[int64][]memoryCell{}
+			}
+			updated[m.table][m.rowID] = m.cells
+		case deleteMutation:
+			if deleted[m.table] == nil {
+				deleted[m.table] = map[int64]bool{}
+			}
+			deleted[m.table][m.rowID] = true
+		case createIndexMutation:
+			// The index was decoded along with the mutation
+			m.index.build(t, nil)
+			t.indexes = append(t.indexes, m.index)
+		case dropIndexMutation:
+			for _, idx := range t.indexes {
+				if idx.name == m.index.name {
+					t.removeIndex(idx)
+					break
+				}
+			}
+		}
+	}
+
+	for name, rows := range updated {
+		t := tables[name]
+		for _, row := range t.rows {
+			if cells, ok := rows[row.id]; ok {
+				old := row.version
+				row.version = &rowVersion{cells: cells}
+				t.index(row, cells)
+				t.unindex(row, old.cells)
+			}
+		}
+	}
+
+	for name, rows := range deleted {
+		t := tables[name]
+		for _, row := range t.rows {
+			if rows[row.id] {
+				old := row.version
+				row.version = nil
+				t.unindex(row, old.cells)
+			}
+		}
+		t.compact()
+	}
+
+	return nil
+}
+
+func encodeMutations(e *encoder, mutations []*mutation) {
+	e.putUvarint(uint64(len(mutations)))
+	for _, m := range mutations {
+		e.putByte(byte(m.kind))
+		e.putString(m.table)
+
+		switch m.kind {
+		case createTableMutation:
+			encodeTableSchema(e, m.schema)
+		case insertMutation, updateMutation:
+			e.putUvarint(uint64(m.rowID))
+			e.putUvarint(uint64(len(m.cells)))
+			for _, cell := range m.cells {
+				e.putCell(cell)
+			}
+		case deleteMutation:
+			e.putUvarint(uint64(m.rowID))
+		case createIndexMutation:
+			encodeIndex(e, m.index)
+		case dropIndexMutation:
+			e.putString(m.index.name)
+		}
+	}
+}
+
+func decodeMutations(d *decoder) []*mutation {
+	count := d.uvarint()
+	mutations := []*mutation{}
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		m := &mutation{
+			kind:  mutationKind(d.byte()),
+			table: d.string(),
+		}
+
+		switch m.kind {
+		case createTableMutation:
+			m.schema = decodeTableSchema(d)
+		case insertMutation, updateMutation:
+			m.rowID = int64(d.uvarint())
+			cells := d.uvarint()
+			for j := uint64(0); j < cells && d.err == nil; j++ {
+				m.cells = append(m.cells, d.cell())
+			}
+		case deleteMutation:
+			m.rowID = int64(d.uvarint())
+		case createIndexMutation:
+			m.index = decodeIndex(d)
+		case dropIndexMutation:
+			m.index = &tableIndex{name: d.string()}
+		default:
+			d.err = errCorruptData
+		}
+
+		mutations = append(mutations, m)
+	}
+	return mutations
+}
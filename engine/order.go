@@ -0,0 +1,342 @@
+package engine
+
+import (
+	"container/heap"
+	"fmt"
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
+	"sort"
+)
+
+// orderKey is a term of ORDER BY, resolved against the result of a SELECT.
+type orderKey struct {
+	term *ast.OrderingTerm
+	// column is the index of the result column the term refers to, or -1
+	// if its expression has to be evaluated for every row
+	column int
+}
+
+// orderKeys resolves the ORDER BY terms of stmt. A term can be the
+// position of a result column, counting from 1, the alias of one or the
+// expression it selects; anything else is an expression over the columns
+// of r. With SELECT DISTINCT, every term must be a result column, as
+// other expressions may differ between the rows that are dropped.
+func (r *relation) orderKeys(stmt *ast.SelectStatement, projections []*projection) ([]*orderKey, error) {
+	keys := []*orderKey{}
+	for _, term := range stmt.OrderBy {
+		key := &orderKey{term: term, column: -1}
+
+		switch expr := term.Expression.(type) {
+		case *ast.IntegerLiteral:
+			if expr.Value < 1 || expr.Value > int64(len(projections)) {
+				return nil, ErrColumnNotFound
+			}
+			key.column = int(expr.Value) - 1
+		case *ast.Identifier:
+			if expr.Table != nil {
+				break
+			}
+			for i, p := range projections {
+				if p.alias != "" && p.alias == expr.Value {
+					key.column = i
+					break
+				}
+			}
+		}
+
+		if key.column < 0 {
+			if _, err := r.exprType(term.Expression); err != nil {
+				return nil, err
+			}
+			key.column = r.selectedColumn(term.Expression, projections)
+		}
+
+		if key.column < 0 && stmt.Distinct && len(stmt.DistinctOn) == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrDistinctOrder, term.Expression)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// selectedColumn returns the index of the result column that selects
+// expr, or -1 if none does.
+func (r *relation) selectedColumn(expr ast.Expression, projections []*projection) int {
+	colIdx := -1
+	if ident, ok := expr.(*ast.Identifier); ok {
+		colIdx, _ = r.lookup(ident)
+	}
+
+	for i, p := range projections {
+		if colIdx >= 0 && p.colIdx == colIdx {
+			return i
+		}
+		if p.expr != nil && ast.Equal(p.expr, expr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// limits evaluates the LIMIT and OFFSET of stmt, which must be constant.
+// The limit is -1 if there's none.
+func limits(stmt *ast.SelectStatement) (int, int, error) {
+	limit, offset := -1, 0
+	for _, l := range []struct {
+		expr ast.Expression
+		dest *int
+	}{{stmt.Limit, &limit}, {stmt.Offset, &offset}} {
+		if l.expr == nil {
+			continue
+		}
+
+		value, err := evaluator.EvalExpression(l.expr, nil)
+		if err != nil {
+			return 0, 0, fmt.Errorf("%w: %s", ErrInvalidLimit, err)
+		}
+		i, ok := value.(*evaluator.Int)
+		if !ok || i.Value < 0 {
+			return 0, 0, ErrInvalidLimit
+		}
+		*l.dest = int(i.Value)
+	}
+
+	return limit, offset, nil
+}
+
+// indexOrder returns a btree index of t that lists the rows in the order
+// stmt asks for, and whether it has to be read backwards. rel holds the
+// columns of t. Only plain columns can be ordered by an index, all in the
+// same direction and with NULLs where the index puts them: first when
+// ascending, last when descending.
+func indexOrder(t *memoryTable, rel *relation, stmt *ast.SelectStatement) (*tableIndex, bool) {
+	descending := stmt.OrderBy[0].Descending
+	columns := []int{}
+	for _, term := range stmt.OrderBy {
+		ident, ok := term.Expression.(*ast.Identifier)
+		if !ok || term.Descending != descending {
+			return nil, false
+		}
+		if term.Nulls == ast.NULLS_LAST && !descending || term.Nulls == ast.NULLS_FIRST && descending {
+			return nil, false
+		}
+
+		// An alias of the SELECT list takes precedence over a column
+		for _, col := range stmt.Columns {
+			if ident.Table == nil && col.Alias != nil && col.Alias.Literal == ident.Value {
+				return nil, false
+			}
+		}
+
+		colIdx, err := rel.lookup(ident)
+		if err != nil {
+			return nil, false
+		}
+		columns = append(columns, colIdx)
+	}
+
+	for _, idx := range t.indexes {
+		if idx.method != btreeIndex || len(idx.columns) < len(columns) {
+			continue
+		}
+
+		matches := true
+		for i, col := range columns {
+			if idx.columns[i] != col {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return idx, descending
+		}
+	}
+
+	return nil, false
+}
+
+// sortedRow is a row of a result along with the values it's ordered by.
+type sortedRow struct {
+	cells []Cell
+	keys  []evaluator.Value
+	// seq is the position the row was added in, which orders rows whose
+	// keys are equal
+	seq int
+}
+
+// rowSorter collects the rows of a result in the order of keys, and keeps
+// those selected by LIMIT and OFFSET. With a limit, only the rows that may
+// end up in the result are held, in a heap topped by the one that would be
+// dropped next, so the rest of the rows are never sorted.
+type rowSorter struct {
+	keys []*orderKey
+	rows []*sortedRow
+	// max is the number of rows to keep, including those skipped by
+	// OFFSET, or -1 to keep every row
+	max    int
+	offset int
+	seq    int
+	// seen holds the row kept for each distinct key, for SELECT DISTINCT.
+	// With first set, it's the first in order of the rows with the key,
+	// and it's replaced as better rows come in, so they aren't put in a
+	// heap.
+	seen  map[string]*sortedRow
+	first bool
+	// err is the first error comparing rows. The heap and sort functions
+	// can't return one.
+	err error
+}
+
+func newRowSorter(keys []*orderKey, limit int, offset int) *rowSorter {
+	rs := &rowSorter{keys: keys, max: -1, offset: offset}
+	if limit >= 0 {
+		rs.max = limit + offset
+	}
+	return rs
+}
+
+// distinct makes the sorter keep a single row for each distinct key. If
+// first is set, it's the first row in order, otherwise any of them.
+func (rs *rowSorter) distinct(first bool) {
+	rs.seen = map[string]*sortedRow{}
+	rs.first = first
+}
+
+// full reports whether the rows still to be added, if any, can't end up in
+// the result. That's only known when rows are added in order.
+func (rs *rowSorter) full() bool {
+	return len(rs.keys) == 0 && rs.max >= 0 && len(rs.rows) >= rs.max
+}
+
+// add adds a row with the values of the ORDER BY keys. distinctKey is
+// only used by sorters made distinct.
+func (rs *rowSorter) add(cells []Cell, keys []evaluator.Value, distinctKey string) error {
+	row := &sortedRow{cells: cells, keys: keys, seq: rs.seq}
+	rs.seq++
+
+	if rs.seen != nil {
+		if kept, ok := rs.seen[distinctKey]; ok {
+			if rs.first && rs.compare(row, kept) < 0 {
+				*kept = *row
+			}
+			return rs.err
+		}
+		rs.seen[distinctKey] = row
+	}
+
+	switch {
+	case rs.max < 0 || rs.first && len(rs.keys) != 0:
+		rs.rows = append(rs.rows, row)
+	case len(rs.keys) == 0:
+		if len(rs.rows) < rs.max {
+			rs.rows = append(rs.rows, row)
+		}
+	case len(rs.rows) < rs.max:
+		heap.Push(rs, row)
+	case rs.max > 0 && rs.compare(row, rs.rows[0]) < 0:
+		rs.rows[0] = row
+		heap.Fix(rs, 0)
+	}
+
+	return rs.err
+}
+
+// result returns the rows in order, without those skipped by OFFSET or
+// past the limit.
+func (rs *rowSorter) result() ([][]Cell, error) {
+	if len(rs.keys) != 0 {
+		sort.Slice(rs.rows, func(i, j int) bool {
+			return rs.compare(rs.rows[i], rs.rows[j]) < 0
+		})
+	}
+	if rs.err != nil {
+		return nil, rs.err
+	}
+
+	rows := [][]Cell{}
+	for i, row := range rs.rows {
+		if rs.max >= 0 && i >= rs.max {
+			break
+		}
+		if i >= rs.offset {
+			rows = append(rows, row.cells)
+		}
+	}
+	return rows, nil
+}
+
+// compare returns -1, 0 or 1 as a comes before, with or after b.
+func (rs *rowSorter) compare(a *sortedRow, b *sortedRow) int {
+	for i, key := range rs.keys {
+		c, err := compareKeys(key.term, a.keys[i], b.keys[i])
+		if err != nil {
+			if rs.err == nil {
+				rs.err = err
+			}
+			return 0
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case a.seq < b.seq:
+		return -1
+	case a.seq > b.seq:
+		return 1
+	}
+	return 0
+}
+
+// compareKeys compares two values of an ORDER BY term. NULLs sort before
+// any value, so they come first when ascending and last when descending,
+// unless the term places them.
+func compareKeys(term *ast.OrderingTerm, a evaluator.Value, b evaluator.Value) (int, error) {
+	aNull, bNull := a.Type() == evaluator.NULL_VALUE, b.Type() == evaluator.NULL_VALUE
+	if aNull || bNull {
+		nullsFirst := !term.Descending
+		if term.Nulls != "" {
+			nullsFirst = term.Nulls == ast.NULLS_FIRST
+		}
+
+		switch {
+		case aNull && bNull:
+			return 0, nil
+		case aNull == nullsFirst:
+			return -1, nil
+		}
+		return 1, nil
+	}
+
+	c, err := evaluator.Compare(a, b)
+	if term.Descending {
+		c = -c
+	}
+	return c, err
+}
+
+// The heap is a max-heap, so the row that would be dropped next is on top.
+
+func (rs *rowSorter) Len() int {
+	return len(rs.rows)
+}
+
+func (rs *rowSorter) Less(i, j int) bool {
+	return rs.compare(rs.rows[i], rs.rows[j]) > 0
+}
+
+func (rs *rowSorter) Swap(i, j int) {
+	rs.rows[i], rs.rows[j] = rs.rows[j], rs.rows[i]
+}
+
+func (rs *rowSorter) Push(x any) {
+	rs.rows = append(rs.rows, x.(*sortedRow))
+}
+
+func (rs *rowSorter) Pop() any {
+	row := rs.rows[len(rs.rows)-1]
+	rs.rows = rs.rows[:len(rs.rows)-1]
+	return row
+}
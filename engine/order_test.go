@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"errors"
+	"jnafolayan/sql-db/ast"
+	"strings"
+	"testing"
+)
+
+func TestOrderBy(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT, balance FLOAT)")
+	execStatement(t, mb, "INSERT INTO people (name, age, balance) VALUES ('John', 40, 10.5)")
+	execStatement(t, mb, "INSERT INTO people (name, age, balance) VALUES ('Julia', 30, 20)")
+	execStatement(t, mb, "INSERT INTO people (name, age, balance) VALUES ('Jake', 20, 1.5)")
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('Jane', 30)")
+	execStatement(t, mb, "INSERT INTO people (name) VALUES ('Jim')")
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// Rows that are ordered the same keep the order they were read in
+		{"SELECT name, age FROM people ORDER BY age", []string{"Jim,NULL", "Jake,20", "Julia,30", "Jane,30", "John,40"}},
+		{"SELECT name, age FROM people ORDER BY age DESC, name", []string{"John,40", "Jane,30", "Julia,30", "Jake,20", "Jim,NULL"}},
+		{"SELECT name, age FROM people ORDER BY age ASC NULLS LAST, name DESC", []string{"Jake,20", "Julia,30", "Jane,30", "John,40", "Jim,NULL"}},
+		{"SELECT name, age FROM people ORDER BY age DESC NULLS FIRST LIMIT 2", []string{"Jim,NULL", "John,40"}},
+		{"SELECT name, balance * 2 AS double FROM people ORDER BY double DESC, 1", []string{"Julia,40.000000", "John,21.000000", "Jake,3.000000", "Jane,NULL", "Jim,NULL"}},
+		{"SELECT name, age FROM people ORDER BY 2, name LIMIT 2 OFFSET 1", []string{"Jake,20", "Jane,30"}},
+		{"SELECT name FROM people WHERE age IS NOT NULL ORDER BY -age, people.name", []string{"John", "Jane", "Julia", "Jake"}},
+		{"SELECT name FROM people ORDER BY age > 25, name", []string{"Jim", "Jake", "Jane", "John", "Julia"}},
+		// Grouping tells apart expressions that are written alike
+		{
+			"SELECT name, age + balance * 2 AS x FROM people WHERE balance IS NOT NULL ORDER BY (age + balance) * 2",
+			[]string{"Jake,23.000000", "Julia,70.000000", "John,61.000000"},
+		},
+		{"SELECT 'name', name FROM people ORDER BY name LIMIT 2", []string{"name,Jake", "name,Jane"}},
+		{"SELECT name FROM people ORDER BY name LIMIT 0", []string{}},
+		{"SELECT name FROM people ORDER BY name LIMIT 10 OFFSET 4", []string{"Julia"}},
+		{"SELECT name FROM people LIMIT 2", []string{"John", "Julia"}},
+		{"SELECT name FROM people LIMIT 1 + 1 OFFSET 2", []string{"Jake", "Jane"}},
+		{"SELECT age, COUNT(*) FROM people GROUP BY age ORDER BY COUNT(*) DESC, age LIMIT 2", []string{"30,2", "NULL,1"}},
+		{"SELECT age FROM people GROUP BY age HAVING age > 0 ORDER BY MAX(balance) DESC", []string{"30", "40", "20"}},
+	}
+
+	for _, tt := range tests {
+		rows := orderedRows(t, mb, tt.input)
+		if strings.Join(rows, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%s: expected %v, got %v", tt.input, tt.expected, rows)
+		}
+	}
+
+	for _, tt := range []struct {
+		input    string
+		expected error
+	}{
+		{"SELECT name FROM people ORDER BY height", ErrColumnNotFound},
+		{"SELECT name FROM people ORDER BY 3", ErrColumnNotFound},
+		{"SELECT name FROM people ORDER BY 0", ErrColumnNotFound},
+		{"SELECT name FROM people LIMIT -1", ErrInvalidLimit},
+		{"SELECT name FROM people LIMIT 1.5", ErrInvalidLimit},
+		{"SELECT name FROM people LIMIT 1 OFFSET 'a'", ErrInvalidLimit},
+		{"SELECT name FROM people LIMIT age", ErrInvalidLimit},
+	} {
+		_, err := mb.Select(parseStatement(t, tt.input).(*ast.SelectStatement))
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %q error, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestIndexOrder(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE scores (name TEXT, score INT)")
+	execStatement(t, mb, "INSERT INTO scores (name, score) VALUES ('b', 20)")
+	execStatement(t, mb, "INSERT INTO scores (name, score) VALUES ('a', 30)")
+	execStatement(t, mb, "INSERT INTO scores (name) VALUES ('n')")
+	execStatement(t, mb, "INSERT INTO scores (name, score) VALUES ('c', 10)")
+	execStatement(t, mb, "INSERT INTO scores (name, score) VALUES ('d', 30)")
+	execStatement(t, mb, "CREATE INDEX scores_score_name ON scores (score, name)")
+
+	table := mb.tables["scores"]
+	for _, tt := range []struct {
+		orderBy    string
+		indexed    bool
+		descending bool
+	}{
+		{"score", true, false},
+		{"s.score, s.name", true, false},
+		{"score DESC, name DESC", true, true},
+		{"score NULLS FIRST", true, false},
+		{"score DESC NULLS LAST", true, true},
+		{"score NULLS LAST", false, false},
+		{"score, name DESC", false, false},
+		{"name", false, false},
+		{"score + 1", false, false},
+		{"other.score", false, false},
+		// The alias names the expression rather than the column
+		{"total", false, false},
+	} {
+		stmt := parseStatement(t, "SELECT name, score * 2 AS total FROM scores s ORDER BY "+tt.orderBy).(*ast.SelectStatement)
+		from := stmt.From.(*ast.TableName)
+		idx, descending := indexOrder(table, tableRelation(table, from), stmt)
+		if (idx != nil) != tt.indexed || descending != tt.descending {
+			t.Errorf("ORDER BY %s: expected indexed %t descending %t, got %v %t", tt.orderBy, tt.indexed, tt.descending, idx, descending)
+		}
+	}
+
+	expectRows := func(input string, expected ...string) {
+		t.Helper()
+		rows := orderedRows(t, mb, input)
+		if strings.Join(rows, "|") != strings.Join(expected, "|") {
+			t.Errorf("%s: expected %v, got %v", input, expected, rows)
+		}
+	}
+
+	expectRows("SELECT name, score FROM scores ORDER BY score", "n,NULL", "c,10", "b,20", "a,30", "d,30")
+	expectRows("SELECT name FROM scores ORDER BY score DESC, name DESC LIMIT 3", "d", "a", "b")
+	expectRows("SELECT name FROM scores WHERE name != 'a' ORDER BY score DESC LIMIT 2 OFFSET 1", "b", "c")
+	// The index on score can't be used both to order and to look up
+	expectRows("SELECT name FROM scores WHERE score > 15 ORDER BY score DESC", "a", "d", "b")
+
+	// A reader sees the order of its snapshot, although the index has
+	// entries for newer versions of the rows
+	reader := mb.NewSession()
+	if err := reader.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	execStatement(t, mb, "UPDATE scores SET score = 5 WHERE name = 'a'")
+	execStatement(t, mb, "DELETE FROM scores WHERE name = 'c'")
+	execStatement(t, mb, "INSERT INTO scores (name, score) VALUES ('e', 25)")
+
+	expectRows("SELECT name FROM scores ORDER BY score", "n", "a", "b", "e", "d")
+	expectRows("SELECT name FROM scores ORDER BY score DESC", "d", "e", "b", "a", "n")
+
+	res, err := reader.Select(parseStatement(t, "SELECT name FROM scores ORDER BY score").(*ast.SelectStatement))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, row := range res.Rows {
+		names = append(names, row[0].AsText())
+	}
+	if strings.Join(names, ",") != "n,c,b,a,d" {
+		t.Errorf("expected the reader's snapshot in order, got %v", names)
+	}
+	reader.Rollback()
+}
@@ -0,0 +1,487 @@
+package engine
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"jnafolayan/sql-db/parser"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// A database file is a sequence of fixed-size pages. Page 0 describes the
+// file, the remaining pages hold streams of bytes (the catalog and the rows
+// of each table) chained together through their headers.
+const (
+	pageSize        = 4096
+	pageHeaderSize  = 11 // kind (1), next page (4), payload length (2), checksum (4)
+	pagePayloadSize = pageSize - pageHeaderSize
+
+	databaseMagic   = "SQLITDB\x00"
+	databaseVersion = 7
+)
+
+type pageKind byte
+
+const (
+	headerPage pageKind = iota + 1
+	catalogPage
+	dataPage
+)
+
+var ErrCorruptDatabase = errors.New("Corrupt database file")
+
+type pageWriter struct {
+	file  *os.File
+	count uint32
+}
+
+func (pw *pageWriter) writePage(kind pageKind, next uint32, payload []byte) error {
+	page := make([]byte, pageSize)
+	page[0] = byte(kind)
+	binary.BigEndian.PutUint32(page[1:5], next)
+	binary.BigEndian.PutUint16(page[5:7], uint16(len(payload)))
+	copy(page[pageHeaderSize:], payload)
+	binary.BigEndian.PutUint32(page[7:11], pageChecksum(page))
+
+	_, err := pw.file.WriteAt(page, int64(pw.count)*pageSize)
+	if err != nil {
+		return err
+	}
+	pw.count++
+	return nil
+}
+
+// writeStream spreads data over as many consecutive pages as needed and
+// returns the number of the first one.
+func (pw *pageWriter) writeStream(kind pageKind, data []byte) (uint32, error) {
+	first := pw.count
+	for {
+		n := len(data)
+		if n > pagePayloadSize {
+			n = pagePayloadSize
+		}
+
+		var next uint32
+		if len(data) > n {
+			next = pw.count + 1
+		}
+
+		if err := pw.writePage(kind, next, data[:n]); err != nil {
+			return 0, err
+		}
+
+		data = data[n:]
+		if len(data) == 0 {
+			return first, nil
+		}
+	}
+}
+
+type pageReader struct {
+	file  *os.File
+	count uint32
+}
+
+func (pr *pageReader) readPage(n uint32, kind pageKind) (next uint32, payload []byte, err error) {
+	if pr.count != 0 && n >= pr.count {
+		return 0, nil, ErrCorruptDatabase
+	}
+
+	page := make([]byte, pageSize)
+	if _, err := pr.file.ReadAt(page, int64(n)*pageSize); err != nil {
+		return 0, nil, ErrCorruptDatabase
+	}
+
+	if pageKind(page[0]) != kind || binary.BigEndian.Uint32(page[7:11]) != pageChecksum(page) {
+		return 0, nil, ErrCorruptDatabase
+	}
+
+	length := binary.BigEndian.Uint16(page[5:7])
+	if int(length) > pagePayloadSize {
+		return 0, nil, ErrCorruptDatabase
+	}
+
+	return binary.BigEndian.Uint32(page[1:5]), page[pageHeaderSize : pageHeaderSize+int(length)], nil
+}
+
+func (pr *pageReader) readStream(first uint32, kind pageKind) ([]byte, error) {
+	var data []byte
+	n := first
+	for visited := uint32(0); ; visited++ {
+		if visited >= pr.count {
+			// a chain can't be longer than the file
+			return nil, ErrCorruptDatabase
+		}
+
+		next, payload, err := pr.readPage(n, kind)
+		if err != nil {
+			return nil, err
+		}
+
+		data = append(data, payload...)
+		if next == 0 {
+			return data, nil
+		}
+		n = next
+	}
+}
+
+func pageChecksum(page []byte) uint32 {
+	h := crc32.NewIEEE()
+	h.Write(page[:7])
+	h.Write(page[pageHeaderSize:])
+	return h.Sum32()
+}
+
+// writeDatabase stores every table in a fresh file next to path and then
+// renames it over path, so readers never observe a partially written file.
+// Only the tables and rows visible to tx are stored. seq is the sequence
+// number of the last log record they include.
+func writeDatabase(path string, tables MemoryTables, tx *transaction, seq uint64) error {
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	// Page 0 is written last, once the page count is known
+	pw := &pageWriter{file: file, count: 1}
+
+	names := []string{}
+	for name, t := range tables {
+		if tx.visible(t.createdBy) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	catalog := &encoder{}
+	catalog.putUvarint(uint64(len(names)))
+	for _, name := range names {
+		t := tables[name]
+		first, err := pw.writeStream(dataPage, encodeTableRows(t, tx))
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		indexes := []*tableIndex{}
+		for _, idx := range t.indexes {
+			if tx.visible(idx.createdBy) {
+				indexes = append(indexes, idx)
+			}
+		}
+
+		catalog.putString(name)
+		encodeTableSchema(catalog, t)
+		catalog.putUvarint(uint64(len(indexes)))
+		for _, idx := range indexes {
+			encodeIndex(catalog, idx)
+		}
+		catalog.putUint32(first)
+	}
+
+	root, err := pw.writeStream(catalogPage, catalog.Bytes())
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	header := &encoder{}
+	header.buf.WriteString(databaseMagic)
+	header.putUvarint(databaseVersion)
+	header.putUint32(pageSize)
+	header.putUint32(pw.count)
+	header.putUint32(root)
+	header.putUvarint(seq)
+
+	count := pw.count
+	pw.count = 0
+	if err := pw.writePage(headerPage, 0, header.Bytes()); err != nil {
+		file.Close()
+		return err
+	}
+	pw.count = count
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(path))
+}
+
+// readDatabase loads all tables stored in the file at path, along with the
+// sequence number of the last log record they include.
+func readDatabase(path string) (MemoryTables, uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	pr := &pageReader{file: file}
+	_, payload, err := pr.readPage(0, headerPage)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(payload) < len(databaseMagic) || string(payload[:len(databaseMagic)]) != databaseMagic {
+		return nil, 0, ErrCorruptDatabase
+	}
+
+	header := newDecoder(payload[len(databaseMagic):])
+	version := header.uvarint()
+	size := header.uint32()
+	pr.count = header.uint32()
+	root := header.uint32()
+	seq := header.uvarint()
+	if header.err != nil || version != databaseVersion || size != pageSize {
+		return nil, 0, ErrCorruptDatabase
+	}
+
+	data, err := pr.readStream(root, catalogPage)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tables := NewMemoryBackendTables()
+	catalog := newDecoder(data)
+	count := catalog.uvarint()
+	for i := uint64(0); i < count && catalog.err == nil; i++ {
+		name := catalog.string()
+		t := decodeTableSchema(catalog)
+		indexes := catalog.uvarint()
+		for j := uint64(0); j < indexes && catalog.err == nil; j++ {
+			t.indexes = append(t.indexes, decodeIndex(catalog))
+		}
+		first := catalog.uint32()
+		if catalog.err != nil {
+			break
+		}
+
+		rows, err := pr.readStream(first, dataPage)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := decodeTableRows(rows, t); err != nil {
+			return nil, 0, err
+		}
+		for _, idx := range t.indexes {
+			for _, col := range idx.columns {
+				if col >= len(t.columns) {
+					return nil, 0, ErrCorruptDatabase
+				}
+			}
+			idx.build(t, nil)
+		}
+
+		tables[name] = t
+	}
+
+	if catalog.err != nil {
+		return nil, 0, ErrCorruptDatabase
+	}
+
+	return tables, seq, nil
+}
+
+// Flags stored along with each column
+const (
+	notNullFlag byte = 1 << iota
+)
+
+// Flags stored along with each index
+const (
+	uniqueFlag byte = 1 << iota
+	primaryFlag
+	constraintFlag
+)
+
+func encodeTableSchema(e *encoder, t *memoryTable) {
+	e.putUvarint(uint64(len(t.columns)))
+	for _, col := range t.columns {
+		e.putString(col.name)
+		e.putString(string(col.columnType))
+
+		flags := byte(0)
+		if col.notNull {
+			flags |= notNullFlag
+		}
+		e.putByte(flags)
+	}
+
+	e.putUvarint(uint64(len(t.foreignKeys)))
+	for _, fk := range t.foreignKeys {
+		e.putString(fk.parent)
+		putPositions(e, fk.columns)
+		putPositions(e, fk.parentColumns)
+		e.putByte(byte(fk.onDelete))
+	}
+
+	e.putUvarint(uint64(len(t.checks)))
+	for _, check := range t.checks {
+		e.putString(check.name)
+		e.putString(check.source)
+	}
+}
+
+func putPositions(e *encoder, positions []int) {
+	e.putUvarint(uint64(len(positions)))
+	for _, pos := range positions {
+		e.putUvarint(uint64(pos))
+	}
+}
+
+func decodePositions(d *decoder) []int {
+	positions := []int{}
+	count := d.uvarint()
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		positions = append(positions, int(d.uvarint()))
+	}
+	return positions
+}
+
+func decodeTableSchema(d *decoder) *memoryTable {
+	t := &memoryTable{}
+	count := d.uvarint()
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		col := &tableColumn{
+			name:       d.string(),
+			columnType: ColumnType(d.string()),
+		}
+		col.notNull = d.byte()&notNullFlag != 0
+		t.columns = append(t.columns, col)
+	}
+
+	count = d.uvarint()
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		t.foreignKeys = append(t.foreignKeys, &foreignKey{
+			parent:        d.string(),
+			columns:       decodePositions(d),
+			parentColumns: decodePositions(d),
+			onDelete:      referentialAction(d.byte()),
+		})
+	}
+
+	count = d.uvarint()
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		check := &checkConstraint{name: d.string(), source: d.string()}
+		if d.err != nil {
+			break
+		}
+
+		expr, err := parser.ParseExpression(check.source)
+		if err != nil {
+			d.err = errCorruptData
+			break
+		}
+		check.expr = expr
+		t.checks = append(t.checks, check)
+	}
+	return t
+}
+
+func encodeIndex(e *encoder, idx *tableIndex) {
+	e.putString(idx.name)
+	e.putByte(byte(idx.method))
+
+	flags := byte(0)
+	if idx.unique {
+		flags |= uniqueFlag
+	}
+	if idx.primary {
+		flags |= primaryFlag
+	}
+	if idx.constraint {
+		flags |= constraintFlag
+	}
+	e.putByte(flags)
+
+	e.putUvarint(uint64(len(idx.columns)))
+	for _, col := range idx.columns {
+		e.putUvarint(uint64(col))
+	}
+}
+
+func decodeIndex(d *decoder) *tableIndex {
+	name := d.string()
+	method := indexMethod(d.byte())
+	flags := d.byte()
+	columns := decodePositions(d)
+
+	idx := newTableIndex(name, method, flags&uniqueFlag != 0, columns)
+	idx.primary = flags&primaryFlag != 0
+	idx.constraint = flags&constraintFlag != 0
+	return idx
+}
+
+func encodeTableRows(t *memoryTable, tx *transaction) []byte {
+	type visibleRow struct {
+		id      int64
+		version *rowVersion
+	}
+
+	rows := []visibleRow{}
+	for _, row := range t.rows {
+		if v := tx.version(row); v != nil {
+			rows = append(rows, visibleRow{row.id, v})
+		}
+	}
+
+	e := &encoder{}
+	e.putUvarint(uint64(t.lastRowID))
+	e.putUvarint(uint64(len(rows)))
+	for _, row := range rows {
+		e.putUvarint(uint64(row.id))
+		for _, cell := range row.version.cells {
+			e.putCell(cell)
+		}
+	}
+	return e.Bytes()
+}
+
+func decodeTableRows(data []byte, t *memoryTable) error {
+	d := newDecoder(data)
+	t.lastRowID = int64(d.uvarint())
+	count := d.uvarint()
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		id := int64(d.uvarint())
+		cells := make([]memoryCell, len(t.columns))
+		for j := range cells {
+			cells[j] = d.cell()
+		}
+		t.rows = append(t.rows, &memoryRow{
+			id:      id,
+			version: &rowVersion{cells: cells},
+		})
+	}
+
+	if d.err != nil {
+		return ErrCorruptDatabase
+	}
+	return nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	// Some platforms don't support syncing directories; the rename has
+	// already happened at this point so that isn't fatal.
+	d.Sync()
+	return nil
+}
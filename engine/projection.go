@@ -0,0 +1,272 @@
+package engine
+
+import (
+	"errors"
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
+	"strings"
+)
+
+// projection is a column of the result of a SELECT.
+type projection struct {
+	column *ResultColumn
+	// colIdx is the index of the column of the relation that is selected
+	// as is, or -1 if expr has to be evaluated for every row
+	colIdx int
+	expr   ast.Expression
+	// alias is the name given to the column with AS, if any
+	alias string
+}
+
+// projections resolves the SELECT list of stmt against the columns of r,
+// in the order the columns are listed.
+func (r *relation) projections(stmt *ast.SelectStatement) ([]*projection, error) {
+	projections := []*projection{}
+	for _, col := range stmt.Columns {
+		if col.Star {
+			found := false
+			for i, c := range r.columns {
+				// A star for every table leaves out the columns merged by
+				// USING, while one for a table includes them
+				if (col.Table == nil && c.hidden) || (col.Table != nil && col.Table.Literal != c.table) {
+					continue
+				}
+
+				projections = append(projections, &projection{
+					column: &ResultColumn{Type: c.columnType, Name: c.name},
+					colIdx: i,
+				})
+				found = true
+			}
+
+			if !found {
+				return nil, ErrTableNotFound
+			}
+			continue
+		}
+
+		colType, err := r.exprType(col.Expression)
+		if err != nil {
+			return nil, err
+		}
+		// Expressions that are always NULL have no type of their own
+		if colType == "" {
+			colType = TEXT_COLUMN
+		}
+
+		p := &projection{
+			column: &ResultColumn{Type: colType, Name: col.Expression.String()},
+			colIdx: -1,
+			expr:   col.Expression,
+		}
+		if ident, ok := col.Expression.(*ast.Identifier); ok {
+			p.colIdx, _ = r.lookup(ident)
+			p.column.Name = ident.Value
+		}
+		if col.Alias != nil {
+			p.column.Name = col.Alias.Literal
+			p.alias = col.Alias.Literal
+		}
+
+		projections = append(projections, p)
+	}
+
+	return projections, nil
+}
+
+// cell returns the cell of the projection for row. scope must be bound to
+// row.
+func (p *projection) cell(scope *evaluator.Scope, row []memoryCell) (memoryCell, error) {
+	if p.colIdx >= 0 {
+		return row[p.colIdx], nil
+	}
+
+	value, err := evaluator.EvalExpression(p.expr, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return resultCell(p.column.Type, value)
+}
+
+// exprType infers the type of the values of expr from the types of the
+// columns of r it refers to. Conditions are INT, as their results are
+// stored as 1 or 0. The type is empty if expr is always NULL.
+func (r *relation) exprType(expr ast.Expression) (ColumnType, error) {
+	switch node := expr.(type) {
+	case *ast.IntegerLiteral, *ast.Boolean:
+		return INT_COLUMN, nil
+	case *ast.FloatLiteral:
+		return FLOAT_COLUMN, nil
+	case *ast.StringLiteral:
+		return TEXT_COLUMN, nil
+	case *ast.Null:
+		return "", nil
+	case *ast.Identifier:
+		colIdx, err := r.lookup(node)
+		if errors.Is(err, ErrColumnNotFound) && r.ctx != nil && r.ctx.outer != nil {
+			// A subquery can refer to the columns of the outer row
+			r.ctx.outer.referenced = true
+			return r.ctx.outer.rel.exprType(node)
+		}
+		if err != nil {
+			return "", err
+		}
+		return r.columns[colIdx].columnType, nil
+	case *ast.SubqueryExpression:
+		return r.subqueryType(node.Select)
+	case *ast.ExistsExpression:
+		if _, err := r.subqueryColumns(node.Subquery.Select); err != nil {
+			return "", err
+		}
+		return INT_COLUMN, nil
+	case *ast.PrefixExpression:
+		colType, err := r.exprType(node.Right)
+		if err != nil || strings.ToUpper(node.Operator) != "NOT" {
+			return colType, err
+		}
+		return INT_COLUMN, nil
+	case *ast.CallExpression:
+		if node.Over != nil {
+			return r.windowType(node)
+		}
+		return r.aggregateType(node)
+	case *ast.InfixExpression:
+		left, err := r.exprType(node.Left)
+		if err != nil {
+			return "", err
+		}
+		right, err := r.exprType(node.Right)
+		if err != nil {
+			return "", err
+		}
+
+		switch strings.ToUpper(node.Operator) {
+		case "+", "-", "*", "/", "%":
+			if left == FLOAT_COLUMN || right == FLOAT_COLUMN {
+				return FLOAT_COLUMN, nil
+			}
+			if left == "" && right == "" {
+				return "", nil
+			}
+			return INT_COLUMN, nil
+		}
+		return INT_COLUMN, nil
+	case *ast.CaseExpression:
+		var colType ColumnType
+		operands := []ast.Expression{node.Operand}
+		results := []ast.Expression{node.Else}
+		for _, when := range node.Whens {
+			operands = append(operands, when.Condition)
+			results = append(results, when.Result)
+		}
+
+		for _, operand := range operands {
+			if operand == nil {
+				continue
+			}
+			if _, err := r.exprType(operand); err != nil {
+				return "", err
+			}
+		}
+
+		for _, result := range results {
+			if result == nil {
+				continue
+			}
+			resultType, err := r.exprType(result)
+			if err != nil {
+				return "", err
+			}
+			colType = widerType(colType, resultType)
+		}
+		return colType, nil
+	}
+
+	// The rest are conditions, whose operands only need to exist
+	var operands []ast.Expression
+	switch node := expr.(type) {
+	case *ast.InExpression:
+		operands = append([]ast.Expression{node.Left}, node.Values...)
+		if node.Subquery != nil {
+			operands = append(operands, node.Subquery)
+		}
+	case *ast.BetweenExpression:
+		operands = []ast.Expression{node.Left, node.Low, node.High}
+	case *ast.PatternExpression:
+		operands = []ast.Expression{node.Left, node.Pattern, node.Escape}
+	case *ast.IsExpression:
+		operands = []ast.Expression{node.Left}
+	}
+
+	for _, operand := range operands {
+		if operand == nil {
+			continue
+		}
+		if _, err := r.exprType(operand); err != nil {
+			return "", err
+		}
+	}
+	return INT_COLUMN, nil
+}
+
+// aggregateType returns the type of the result of an aggregate call.
+func (r *relation) aggregateType(call *ast.CallExpression) (ColumnType, error) {
+	if _, err := evaluator.NewAccumulator(call); err != nil {
+		return "", err
+	}
+
+	var argType ColumnType
+	for _, arg := range call.Arguments {
+		t, err := r.exprType(arg)
+		if err != nil {
+			return "", err
+		}
+		argType = t
+	}
+
+	switch strings.ToUpper(call.Name) {
+	case "COUNT":
+		return INT_COLUMN, nil
+	case "AVG":
+		return FLOAT_COLUMN, nil
+	case "SUM":
+		if argType == INT_COLUMN || argType == "" {
+			return argType, nil
+		}
+		return FLOAT_COLUMN, nil
+	}
+	// MIN and MAX return one of the values
+	return argType, nil
+}
+
+// widerType returns a type that can hold the values of both a and b.
+func widerType(a, b ColumnType) ColumnType {
+	switch {
+	case a == "" || a == b:
+		return b
+	case b == "":
+		return a
+	case a != TEXT_COLUMN && b != TEXT_COLUMN:
+		return FLOAT_COLUMN
+	}
+	return TEXT_COLUMN
+}
+
+// resultCell converts a computed value to a cell of a result column of
+// colType.
+func resultCell(colType ColumnType, v evaluator.Value) (memoryCell, error) {
+	if b, ok := v.(*evaluator.Bool); ok {
+		v = &evaluator.Int{Value: 0}
+		if b.Value {
+			v = &evaluator.Int{Value: 1}
+		}
+	}
+
+	// Numbers mixed with text are shown as text
+	if colType == TEXT_COLUMN && (v.Type() == evaluator.INT_VALUE || v.Type() == evaluator.FLOAT_VALUE) {
+		v = &evaluator.Text{Value: v.String()}
+	}
+
+	return valueCell(colType, v)
+}
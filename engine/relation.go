@@ -0,0 +1,365 @@
+package engine
+
+import (
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
+)
+
+// relationColumn is a column of the rows a FROM clause produces.
+type relationColumn struct {
+	// table is the name or alias that qualifies the column, if any
+	table      string
+	name       string
+	columnType ColumnType
+	// hidden columns can only be referred to by their qualified name
+	hidden bool
+}
+
+// relation is a set of rows read from one or more tables.
+type relation struct {
+	columns []*relationColumn
+	rows    [][]memoryCell
+
+	// ambiguous are the names that more than one column can be referred
+	// to by
+	ambiguous map[string]bool
+	// ctx is the query the relation is read by
+	ctx *queryContext
+}
+
+func newRelation(columns []*relationColumn) *relation {
+	r := &relation{columns: columns, ambiguous: map[string]bool{}}
+
+	seen := map[string]bool{}
+	for _, col := range columns {
+		if col.hidden {
+			continue
+		}
+		if seen[col.name] {
+			r.ambiguous[col.name] = true
+		}
+		seen[col.name] = true
+	}
+
+	return r
+}
+
+// lookup returns the index of the column ident refers to.
+func (r *relation) lookup(ident *ast.Identifier) (int, error) {
+	found := -1
+	for i, col := range r.columns {
+		if col.name != ident.Value {
+			continue
+		}
+
+		if ident.Table != nil {
+			if col.table == ident.Table.Literal {
+				return i, nil
+			}
+			continue
+		}
+
+		if !col.hidden {
+			if found >= 0 {
+				return -1, ErrAmbiguousColumn
+			}
+			found = i
+		}
+	}
+
+	if found < 0 {
+		return -1, ErrColumnNotFound
+	}
+	return found, nil
+}
+
+// bind sets the variables of scope to the cells of row. Columns can be
+// referred to by their qualified names, and by their names alone if no
+// other column has them.
+func (r *relation) bind(scope *evaluator.Scope, row []memoryCell) {
+	for i, col := range r.columns {
+		value := row[i].Value(col.columnType)
+		if col.table != "" {
+			scope.SetQualifiedVar(col.table, col.name, value)
+		}
+		if !col.hidden && !r.ambiguous[col.name] {
+			scope.SetVar(col.name, value)
+		}
+	}
+}
+
+// columnIndexes maps the names of the columns of r, alone and qualified,
+// to their positions.
+func (r *relation) columnIndexes() map[string]int {
+	colNameToIdx := map[string]int{}
+	for i, col := range r.columns {
+		colNameToIdx[col.name] = i
+		if col.table != "" {
+			colNameToIdx[col.table+"."+col.name] = i
+		}
+	}
+	return colNameToIdx
+}
+
+// hasTable reports whether columns of r are qualified by name.
+func (r *relation) hasTable(name string) bool {
+	for _, col := range r.columns {
+		if col.table == name {
+			return true
+		}
+	}
+	return false
+}
+
+// scan reads the rows of a FROM clause. The rows of a single table are
+// looked up with predicate, through an index if one fits.
+func (c *queryContext) scan(expr ast.TableExpression, predicate ast.Expression) (*relation, error) {
+	switch expr := expr.(type) {
+	case *ast.TableName:
+		if table := c.with.table(expr.Name.Literal); table != nil {
+			rel := table.relation(expr)
+			rel.ctx = c
+			rel.rows = table.rows
+			table.read = true
+			return rel, nil
+		}
+
+		t := c.session.backend.table(c.tx, expr.Name.Literal)
+		if t == nil {
+			return nil, ErrTableNotFound
+		}
+
+		rel := tableRelation(t, expr)
+		rel.ctx = c
+		for _, row := range t.candidateRows(predicate, rel.columnIndexes()) {
+			if version := c.tx.version(row); version != nil {
+				rel.rows = append(rel.rows, version.cells)
+			}
+		}
+		return rel, nil
+	case *ast.JoinExpression:
+		left, err := c.scan(expr.Left, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := c.scan(expr.Right, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return c.join(expr, left, right)
+	}
+
+	return nil, ErrTableNotFound
+}
+
+// schema returns a relation with the columns of a FROM clause, without
+// reading any rows.
+func (c *queryContext) schema(expr ast.TableExpression) (*relation, error) {
+	switch expr := expr.(type) {
+	case *ast.TableName:
+		if table := c.with.table(expr.Name.Literal); table != nil {
+			rel := table.relation(expr)
+			rel.ctx = c
+			return rel, nil
+		}
+
+		t := c.session.backend.table(c.tx, expr.Name.Literal)
+		if t == nil {
+			return nil, ErrTableNotFound
+		}
+
+		rel := tableRelation(t, expr)
+		rel.ctx = c
+		return rel, nil
+	case *ast.JoinExpression:
+		left, err := c.schema(expr.Left)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := c.schema(expr.Right)
+		if err != nil {
+			return nil, err
+		}
+
+		return c.join(expr, left, right)
+	}
+
+	return nil, ErrTableNotFound
+}
+
+// tableRelation returns a relation with the columns of t, qualified by
+// the name or alias it has in from, and no rows.
+func tableRelation(t *memoryTable, from *ast.TableName) *relation {
+	columns := []*relationColumn{}
+	for _, col := range t.columns {
+		columns = append(columns, &relationColumn{
+			table:      qualifier(from),
+			name:       col.name,
+			columnType: col.columnType,
+		})
+	}
+	return newRelation(columns)
+}
+
+// qualifier returns the name the columns of a table in a FROM clause are
+// qualified by.
+func qualifier(from *ast.TableName) string {
+	if from.Alias != nil {
+		return from.Alias.Literal
+	}
+	return from.Name.Literal
+}
+
+// join combines the rows of left and right with a nested loop.
+func (c *queryContext) join(expr *ast.JoinExpression, left *relation, right *relation) (*relation, error) {
+	for _, col := range right.columns {
+		if col.table != "" && left.hasTable(col.table) {
+			return nil, ErrDuplicateTable
+		}
+	}
+
+	columns := append(append([]*relationColumn{}, left.columns...), right.columns...)
+	combined := newRelation(columns)
+	combined.ctx = c
+
+	if expr.On != nil {
+		if _, err := combined.exprType(expr.On); err != nil {
+			return nil, err
+		}
+	}
+
+	// Each column named in USING is compared on both sides
+	using := []usingColumn{}
+	for _, tok := range expr.Using {
+		ident := &ast.Identifier{Token: tok, Value: tok.Literal}
+		l, err := left.lookup(ident)
+		if err != nil {
+			return nil, err
+		}
+		r, err := right.lookup(ident)
+		if err != nil {
+			return nil, err
+		}
+		using = append(using, usingColumn{l, r})
+	}
+
+	scope := c.newScope(combined)
+	matches := func(row []memoryCell) (bool, error) {
+		if expr.On != nil {
+			combined.bind(scope, row)
+			value, err := evaluator.EvalExpression(expr.On, scope)
+			if err != nil {
+				return false, err
+			}
+			return evaluator.Truthy(value), nil
+		}
+
+		for _, u := range using {
+			l := row[u.left].Value(left.columns[u.left].columnType)
+			r := row[len(left.columns)+u.right].Value(right.columns[u.right].columnType)
+			eq, err := evaluator.Equal(l, r)
+			if err != nil {
+				return false, err
+			}
+			if !evaluator.Truthy(eq) {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+
+	rows := [][]memoryCell{}
+	rightMatched := make([]bool, len(right.rows))
+	for _, l := range left.rows {
+		matched := false
+		for j, r := range right.rows {
+			row := append(append([]memoryCell{}, l...), r...)
+			ok, err := matches(row)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				matched = true
+				rightMatched[j] = true
+				rows = append(rows, row)
+			}
+		}
+
+		// Rows of an outer side that match nothing are kept, with NULLs for
+		// the other side
+		if !matched && (expr.Kind == ast.LEFT_JOIN || expr.Kind == ast.FULL_JOIN) {
+			rows = append(rows, append(append([]memoryCell{}, l...), make([]memoryCell, len(right.columns))...))
+		}
+	}
+
+	if expr.Kind == ast.RIGHT_JOIN || expr.Kind == ast.FULL_JOIN {
+		for j, r := range right.rows {
+			if !rightMatched[j] {
+				rows = append(rows, append(make([]memoryCell, len(left.columns)), r...))
+			}
+		}
+	}
+
+	if len(using) == 0 {
+		combined.rows = rows
+		return combined, nil
+	}
+
+	return mergeUsing(left, right, using, rows)
+}
+
+// usingColumn is a column named in USING, by its index on either side of
+// a join.
+type usingColumn struct {
+	left  int
+	right int
+}
+
+// mergeUsing puts a single column in front of the rows for each pair of
+// columns compared by USING. It holds the value of the left column, or of
+// the right one if that's missing. The columns it replaces are hidden.
+func mergeUsing(left *relation, right *relation, using []usingColumn, rows [][]memoryCell) (*relation, error) {
+	columns := []*relationColumn{}
+	hidden := map[int]bool{}
+	for _, u := range using {
+		l, r := left.columns[u.left], right.columns[u.right]
+		columns = append(columns, &relationColumn{
+			name:       l.name,
+			columnType: widerType(l.columnType, r.columnType),
+		})
+
+		hidden[u.left] = true
+		hidden[len(left.columns)+u.right] = true
+	}
+
+	for i, col := range append(append([]*relationColumn{}, left.columns...), right.columns...) {
+		c := *col
+		c.hidden = c.hidden || hidden[i]
+		columns = append(columns, &c)
+	}
+
+	merged := newRelation(columns)
+	merged.ctx = left.ctx
+	for _, row := range rows {
+		cells := make([]memoryCell, 0, len(columns))
+		for i, u := range using {
+			value := row[u.left].Value(left.columns[u.left].columnType)
+			if value.Type() == evaluator.NULL_VALUE {
+				value = row[len(left.columns)+u.right].Value(right.columns[u.right].columnType)
+			}
+
+			cell, err := resultCell(columns[i].columnType, value)
+			if err != nil {
+				return nil, err
+			}
+			cells = append(cells, cell)
+		}
+
+		merged.rows = append(merged.rows, append(cells, row...))
+	}
+
+	return merged, nil
+}
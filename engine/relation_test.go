@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"errors"
+	"jnafolayan/sql-db/ast"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// joinedRows runs a query and returns its rows as strings of
+// comma-separated values, sorted.
+func joinedRows(t *testing.T, e Engine, input string) []string {
+	t.Helper()
+
+	rows := orderedRows(t, e, input)
+	sort.Strings(rows)
+	return rows
+}
+
+// orderedRows runs a query and returns its rows as strings of
+// comma-separated values, in the order they come in.
+func orderedRows(t *testing.T, e Engine, input string) []string {
+	t.Helper()
+
+	res := execStatement(t, e, input).(*FetchResult)
+	rows := []string{}
+	for _, row := range res.Rows {
+		values := []string{}
+		for i, cell := range row {
+			values = append(values, cell.Value(res.Columns[i].Type).String())
+		}
+		rows = append(rows, strings.Join(values, ","))
+	}
+	return rows
+}
+
+func TestJoins(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (id INT, name TEXT)")
+	execStatement(t, mb, "CREATE TABLE pets (id INT, owner INT, name TEXT)")
+	execStatement(t, mb, "INSERT INTO people (id, name) VALUES (1, 'John')")
+	execStatement(t, mb, "INSERT INTO people (id, name) VALUES (2, 'Julia')")
+	execStatement(t, mb, "INSERT INTO people (id, name) VALUES (3, 'Jake')")
+	execStatement(t, mb, "INSERT INTO pets (id, owner, name) VALUES (1, 1, 'Rex')")
+	execStatement(t, mb, "INSERT INTO pets (id, owner, name) VALUES (2, 1, 'Tom')")
+	execStatement(t, mb, "INSERT INTO pets (id, owner, name) VALUES (3, 2, 'Kitty')")
+	execStatement(t, mb, "INSERT INTO pets (id, owner, name) VALUES (4, 9, 'Stray')")
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"SELECT p.name, pets.name FROM people p JOIN pets ON p.id = pets.owner",
+			[]string{"John,Rex", "John,Tom", "Julia,Kitty"},
+		},
+		{
+			"SELECT p.name, pets.name FROM people p INNER JOIN pets ON p.id = pets.owner WHERE pets.name != 'Rex'",
+			[]string{"John,Tom", "Julia,Kitty"},
+		},
+		{
+			"SELECT p.name, x.name FROM people AS p LEFT JOIN pets x ON p.id = x.owner",
+			[]string{"Jake,NULL", "John,Rex", "John,Tom", "Julia,Kitty"},
+		},
+		{
+			"SELECT p.name, x.name FROM people AS p RIGHT OUTER JOIN pets x ON p.id = x.owner",
+			[]string{"John,Rex", "John,Tom", "Julia,Kitty", "NULL,Stray"},
+		},
+		{
+			"SELECT p.name, x.name FROM people AS p FULL JOIN pets x ON p.id = x.owner",
+			[]string{"Jake,NULL", "John,Rex", "John,Tom", "Julia,Kitty", "NULL,Stray"},
+		},
+		{
+			"SELECT p.name, x.name FROM people p LEFT JOIN pets x ON p.id = x.owner WHERE x.name IS NULL",
+			[]string{"Jake,NULL"},
+		},
+		{
+			"SELECT p.id, x.id FROM people p CROSS JOIN pets x WHERE x.id > 3",
+			[]string{"1,4", "2,4", "3,4"},
+		},
+		{
+			"SELECT a.id, b.id FROM people a, people b WHERE a.id < b.id",
+			[]string{"1,2", "1,3", "2,3"},
+		},
+		// Names that only one table has don't need to be qualified
+		{
+			"SELECT owner, people.name FROM people JOIN pets ON people.id = owner AND owner = 2",
+			[]string{"2,Julia"},
+		},
+		{
+			"SELECT * FROM people JOIN pets USING (id)",
+			[]string{"1,John,1,Rex", "2,Julia,1,Tom", "3,Jake,2,Kitty"},
+		},
+		{
+			"SELECT id, people.id, pets.id FROM people FULL JOIN pets USING (id) WHERE id > 2",
+			[]string{"3,3,3", "4,NULL,4"},
+		},
+		{
+			"SELECT pets.*, o.name FROM pets JOIN people o ON o.id = pets.owner JOIN pets AS other ON other.owner = o.id AND other.id != pets.id",
+			[]string{"1,1,Rex,John", "2,1,Tom,John"},
+		},
+	}
+
+	for _, tt := range tests {
+		rows := joinedRows(t, mb, tt.input)
+		if strings.Join(rows, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%s: expected %v, got %v", tt.input, tt.expected, rows)
+		}
+	}
+
+	// Columns are listed in the order of the tables
+	res := execStatement(t, mb, "SELECT * FROM people JOIN pets ON people.id = pets.owner").(*FetchResult)
+	names := []string{}
+	for _, col := range res.Columns {
+		names = append(names, col.Name)
+	}
+	if strings.Join(names, ",") != "id,name,id,owner,name" {
+		t.Errorf("unexpected columns %v", names)
+	}
+
+	for _, tt := range []struct {
+		input    string
+		expected error
+	}{
+		{"SELECT name FROM people JOIN pets ON people.id = pets.owner", ErrAmbiguousColumn},
+		{"SELECT people.name FROM people JOIN pets ON id = owner", ErrAmbiguousColumn},
+		{"SELECT people.name FROM people JOIN pets ON people.id = pets.person", ErrColumnNotFound},
+		{"SELECT p.name FROM people JOIN pets ON people.id = pets.owner", ErrColumnNotFound},
+		{"SELECT people.name FROM people JOIN pets USING (owner)", ErrColumnNotFound},
+		{"SELECT people.name FROM people JOIN people ON people.id = people.id", ErrDuplicateTable},
+		{"SELECT people.name FROM people JOIN owners ON people.id = owners.id", ErrTableNotFound},
+		{"SELECT x.* FROM people JOIN pets USING (id)", ErrTableNotFound},
+	} {
+		_, err := mb.Select(parseStatement(t, tt.input).(*ast.SelectStatement))
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %q error, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package engine
+
+import (
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
+)
+
+// query runs a SELECT. A context is only used for one query.
+func (c *queryContext) query(stmt *ast.SelectStatement) (*FetchResult, error) {
+	if stmt.With != nil {
+		if err := c.materialize(stmt.With); err != nil {
+			return nil, err
+		}
+	}
+	if len(stmt.Compound) != 0 {
+		return c.compound(stmt)
+	}
+
+	// Rows are filtered and grouped before aggregates are computed
+	if err := noAggregates(append([]ast.Expression{stmt.Predicate}, stmt.GroupBy...)...); err != nil {
+		return nil, err
+	}
+	// and window functions after HAVING
+	if err := noWindows(append([]ast.Expression{stmt.Predicate, stmt.Having}, stmt.GroupBy...)...); err != nil {
+		return nil, err
+	}
+
+	exprs := []ast.Expression{stmt.Having}
+	for _, col := range stmt.Columns {
+		exprs = append(exprs, col.Expression)
+	}
+	for _, term := range stmt.OrderBy {
+		exprs = append(exprs, term.Expression)
+	}
+	exprs = append(exprs, stmt.DistinctOn...)
+
+	calls := aggregateCalls(exprs...)
+	aggregated := len(stmt.GroupBy) != 0 || stmt.Having != nil || len(calls) != 0
+	windows := windowCalls(exprs[1:]...)
+
+	limit, offset, err := limits(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	rel, ordered, err := c.scanSelect(stmt, aggregated)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, expr := range append([]ast.Expression{stmt.Predicate, stmt.Having}, stmt.GroupBy...) {
+		if expr == nil {
+			continue
+		}
+		if _, err := rel.exprType(expr); err != nil {
+			return nil, err
+		}
+	}
+
+	projections, err := rel.projections(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := rel.orderKeys(stmt, projections)
+	if err != nil {
+		return nil, err
+	}
+	// Rows read in order don't need to be sorted
+	if ordered {
+		keys = nil
+	}
+
+	columns := []*ResultColumn{}
+	computed := false
+	for _, p := range projections {
+		columns = append(columns, p.column)
+		computed = computed || p.colIdx < 0
+	}
+	for _, key := range keys {
+		computed = computed || key.column < 0
+	}
+
+	sorter := newRowSorter(keys, limit, offset)
+	var distinct *distinction
+	if stmt.Distinct {
+		distinct, err = rel.distinction(stmt, columns)
+		if err != nil {
+			return nil, err
+		}
+		sorter.distinct(len(stmt.DistinctOn) != 0)
+		computed = computed || len(stmt.DistinctOn) != 0
+	}
+
+	project := func(scope *evaluator.Scope, row []memoryCell) error {
+		cells := []memoryCell{}
+		res := []Cell{}
+		for _, p := range projections {
+			cell, err := p.cell(scope, row)
+			if err != nil {
+				return err
+			}
+
+			cells = append(cells, cell)
+			res = append(res, cell)
+		}
+
+		distinctKey := ""
+		if distinct != nil {
+			key, err := distinct.key(scope, cells)
+			if err != nil {
+				return err
+			}
+			distinctKey = key
+		}
+
+		values := []evaluator.Value{}
+		for _, key := range keys {
+			if key.column >= 0 {
+				values = append(values, res[key.column].Value(columns[key.column].Type))
+				continue
+			}
+
+			value, err := evaluator.EvalExpression(key.term.Expression, scope)
+			if err != nil {
+				return err
+			}
+			values = append(values, value)
+		}
+
+		return sorter.add(res, values, distinctKey)
+	}
+
+	// The scope is shared by the rows of the statement
+	scope := c.newScope(rel)
+	rows := [][]memoryCell{}
+	// Rows of a query with window calls are projected once they're computed
+	windowed := []*windowRow{}
+	for _, row := range rel.rows {
+		if sorter.full() {
+			break
+		}
+
+		if stmt.Predicate != nil {
+			rel.bind(scope, row)
+			value, err := evaluator.EvalExpression(stmt.Predicate, scope)
+			if err != nil {
+				return nil, err
+			}
+			if !evaluator.Truthy(value) {
+				continue
+			}
+		}
+
+		if aggregated {
+			rows = append(rows, row)
+			continue
+		}
+		if len(windows) != 0 {
+			windowed = append(windowed, &windowRow{row: row})
+			continue
+		}
+
+		if computed && stmt.Predicate == nil {
+			rel.bind(scope, row)
+		}
+		if err := project(scope, row); err != nil {
+			return nil, err
+		}
+	}
+
+	if aggregated {
+		groups, err := rel.groupRows(scope, rows, stmt.GroupBy, calls)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, g := range groups {
+			rel.bindGroup(scope, g, calls)
+			if stmt.Having != nil {
+				value, err := evaluator.EvalExpression(stmt.Having, scope)
+				if err != nil {
+					return nil, err
+				}
+				if !evaluator.Truthy(value) {
+					continue
+				}
+			}
+
+			if len(windows) != 0 {
+				windowed = append(windowed, &windowRow{row: g.row, group: g})
+				continue
+			}
+			if err := project(scope, g.row); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(windows) != 0 {
+		bind := func(w *windowRow) {
+			if w.group != nil {
+				rel.bindGroup(scope, w.group, calls)
+				return
+			}
+			rel.bind(scope, w.row)
+		}
+
+		results, err := computeWindows(scope, windowed, windows, bind)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, w := range windowed {
+			bind(w)
+			for j, call := range windows {
+				scope.SetWindow(call, results[i][j])
+			}
+			if err := project(scope, w.row); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	resultRows, err := sorter.result()
+	if err != nil {
+		return nil, err
+	}
+
+	return &FetchResult{
+		Rows:    resultRows,
+		Columns: columns,
+	}, nil
+}
+
+// scanSelect reads the rows of the FROM clause of stmt. The rows of a
+// single table are read in ORDER BY order from an index that lists them
+// so, unless an index is of better use to find the rows matching WHERE.
+// ordered reports whether they were.
+func (c *queryContext) scanSelect(stmt *ast.SelectStatement, aggregated bool) (rel *relation, ordered bool, err error) {
+	from, ok := stmt.From.(*ast.TableName)
+	if !ok || aggregated || len(stmt.OrderBy) == 0 || c.with.table(from.Name.Literal) != nil {
+		rel, err := c.scan(stmt.From, stmt.Predicate)
+		return rel, false, err
+	}
+
+	t := c.session.backend.table(c.tx, from.Name.Literal)
+	if t == nil {
+		return nil, false, ErrTableNotFound
+	}
+
+	rel = tableRelation(t, from)
+	rel.ctx = c
+	idx, descending := indexOrder(t, rel, stmt)
+	if idx == nil || t.bestScan(stmt.Predicate, rel.columnIndexes()) != nil {
+		rel, err := c.scan(stmt.From, stmt.Predicate)
+		return rel, false, err
+	}
+
+	rel.rows = idx.orderedRows(t, c.tx, descending)
+	return rel, true, nil
+}
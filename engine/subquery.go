@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
+)
+
+// queryContext is what the relations read by a query share.
+type queryContext struct {
+	session *Session
+	tx      *transaction
+	// outer is set when the query is a subquery
+	outer *outerRow
+	// with are the tables of the WITH clauses the query can read
+	with *withTables
+}
+
+// outerRow is the row of the statement a subquery is run for. The columns
+// the subquery doesn't have are looked up in it.
+type outerRow struct {
+	rel *relation
+	// scope is bound to the row. It's nil while the subquery is only
+	// being described.
+	scope *evaluator.Scope
+	// referenced is set once the subquery refers to the row
+	referenced bool
+}
+
+// newScope returns a scope for the rows of rel, in which subqueries can be
+// run. Inside a subquery, it's nested in the scope of the outer row.
+func (c *queryContext) newScope(rel *relation) *evaluator.Scope {
+	scope := evaluator.NewScope()
+	if c.outer != nil {
+		scope = evaluator.NewInnerScope(c.outer.scope)
+	}
+
+	scope.SetQuerier(&subqueries{ctx: c, rel: rel, results: map[*ast.SelectStatement]*subqueryResult{}})
+	return scope
+}
+
+// subqueries runs the subqueries of the expressions evaluated over the
+// rows of rel.
+type subqueries struct {
+	ctx *queryContext
+	rel *relation
+	// results holds the results of the subqueries that don't refer to the
+	// rows of rel, which are the same for every row
+	results map[*ast.SelectStatement]*subqueryResult
+}
+
+type subqueryResult struct {
+	columns int
+	rows    [][]evaluator.Value
+}
+
+func (q *subqueries) Query(stmt *ast.SelectStatement, scope *evaluator.Scope) (int, [][]evaluator.Value, error) {
+	if res, ok := q.results[stmt]; ok {
+		return res.columns, res.rows, nil
+	}
+
+	outer := &outerRow{rel: q.rel, scope: scope}
+	ctx := &queryContext{session: q.ctx.session, tx: q.ctx.tx, outer: outer, with: q.ctx.with}
+	fetched, err := ctx.query(stmt)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	res := &subqueryResult{columns: len(fetched.Columns)}
+	for _, row := range fetched.Rows {
+		values := []evaluator.Value{}
+		for i, cell := range row {
+			values = append(values, cell.Value(fetched.Columns[i].Type))
+		}
+		res.rows = append(res.rows, values)
+	}
+
+	if !outer.referenced {
+		q.results[stmt] = res
+	}
+	return res.columns, res.rows, nil
+}
+
+// subqueryColumns returns the columns of the result of a subquery run for
+// the rows of r, without running it.
+func (r *relation) subqueryColumns(stmt *ast.SelectStatement) ([]*ResultColumn, error) {
+	if r.ctx == nil {
+		return nil, evaluator.ErrNoQuerier
+	}
+
+	ctx := &queryContext{session: r.ctx.session, tx: r.ctx.tx, outer: &outerRow{rel: r}, with: r.ctx.with}
+	return ctx.describe(stmt)
+}
+
+// subqueryType returns the type of the single column of a subquery run for
+// the rows of r.
+func (r *relation) subqueryType(stmt *ast.SelectStatement) (ColumnType, error) {
+	columns, err := r.subqueryColumns(stmt)
+	if err != nil {
+		return "", err
+	}
+	if len(columns) != 1 {
+		return "", evaluator.ErrSubqueryColumns
+	}
+	return columns[0].Type, nil
+}
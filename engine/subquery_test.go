@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"errors"
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
+	"strings"
+	"testing"
+)
+
+func TestSubqueries(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (id INT, name TEXT, age INT)")
+	execStatement(t, mb, "CREATE TABLE pets (id INT, owner INT, kind TEXT)")
+	execStatement(t, mb, "INSERT INTO people (id, name, age) VALUES (1, 'John', 40)")
+	execStatement(t, mb, "INSERT INTO people (id, name, age) VALUES (2, 'Julia', 30)")
+	execStatement(t, mb, "INSERT INTO people (id, name, age) VALUES (3, 'Jake', 20)")
+	execStatement(t, mb, "INSERT INTO people (id, name) VALUES (4, 'Jim')")
+	execStatement(t, mb, "INSERT INTO pets (id, owner, kind) VALUES (1, 1, 'dog')")
+	execStatement(t, mb, "INSERT INTO pets (id, owner, kind) VALUES (2, 1, 'cat')")
+	execStatement(t, mb, "INSERT INTO pets (id, owner, kind) VALUES (3, 2, 'cat')")
+	execStatement(t, mb, "INSERT INTO pets (id, kind) VALUES (4, 'cat')")
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"SELECT name FROM people WHERE age > (SELECT AVG(age) FROM people)", []string{"John"}},
+		{"SELECT name FROM people WHERE age = (SELECT age FROM people WHERE id = 9)", []string{}},
+		{"SELECT name FROM people WHERE id IN (SELECT owner FROM pets)", []string{"John", "Julia"}},
+		{"SELECT name FROM people WHERE id IN (SELECT owner FROM pets WHERE kind = 'dog') OR age < 25", []string{"John", "Jake"}},
+		// A NULL in the subquery makes NOT IN unknown for the other values
+		{"SELECT name FROM people WHERE id NOT IN (SELECT owner FROM pets)", []string{}},
+		{"SELECT name FROM people WHERE id NOT IN (SELECT owner FROM pets WHERE owner IS NOT NULL)", []string{"Jake", "Jim"}},
+		{"SELECT name FROM people WHERE EXISTS (SELECT * FROM pets WHERE kind = 'fish')", []string{}},
+
+		// Correlated subqueries
+		{"SELECT name FROM people AS p WHERE EXISTS (SELECT * FROM pets WHERE owner = p.id)", []string{"John", "Julia"}},
+		{"SELECT name FROM people WHERE NOT EXISTS (SELECT * FROM pets WHERE pets.owner = people.id)", []string{"Jake", "Jim"}},
+		// NOT of an unknown result is unknown, so Jim matches neither
+		{"SELECT name FROM people WHERE NOT (age > 25) AND NOT EXISTS (SELECT * FROM pets WHERE owner = people.id)", []string{"Jake"}},
+		{"SELECT name FROM people WHERE NOT age > 25 OR NOT (age < 26)", []string{"John", "Julia", "Jake"}},
+		{"SELECT name, NOT EXISTS (SELECT * FROM pets WHERE owner = people.id), NOT age = 40 FROM people", []string{"John,0,0", "Julia,0,1", "Jake,1,1", "Jim,1,NULL"}},
+		{"SELECT name, (SELECT COUNT(*) FROM pets WHERE owner = people.id) FROM people", []string{"John,2", "Julia,1", "Jake,0", "Jim,0"}},
+		{"SELECT name FROM people WHERE (SELECT COUNT(*) FROM pets WHERE kind = 'cat' AND owner = people.id) = 1", []string{"John", "Julia"}},
+		{
+			"SELECT name FROM people AS p WHERE EXISTS (SELECT * FROM pets WHERE owner = p.id AND EXISTS (SELECT * FROM people WHERE age < p.age AND id = pets.id + 1))",
+			[]string{"John"},
+		},
+		{"SELECT name FROM people ORDER BY (SELECT COUNT(*) FROM pets WHERE owner = people.id) DESC, name LIMIT 2", []string{"John", "Julia"}},
+		{"SELECT kind, COUNT(*) FROM pets GROUP BY kind HAVING COUNT(*) > (SELECT COUNT(*) FROM people WHERE age < 40)", []string{"cat,3"}},
+	}
+
+	for _, tt := range tests {
+		rows := orderedRows(t, mb, tt.input)
+		if strings.Join(rows, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%s: expected %v, got %v", tt.input, tt.expected, rows)
+		}
+	}
+
+	// Subqueries have the type of their column
+	res := execStatement(t, mb, "SELECT (SELECT AVG(age) FROM people), (SELECT name FROM pets WHERE owner = people.id AND kind = 'dog') FROM people").(*FetchResult)
+	if res.Columns[0].Type != FLOAT_COLUMN || res.Columns[1].Type != TEXT_COLUMN {
+		t.Errorf("expected FLOAT and TEXT columns, got %s and %s", res.Columns[0].Type, res.Columns[1].Type)
+	}
+
+	for _, tt := range []struct {
+		input    string
+		expected error
+	}{
+		{"SELECT name FROM people WHERE age = (SELECT age FROM people)", evaluator.ErrSubqueryRows},
+		{"SELECT name FROM people WHERE id IN (SELECT id, owner FROM pets)", evaluator.ErrSubqueryColumns},
+		{"SELECT (SELECT * FROM pets) FROM people", evaluator.ErrSubqueryColumns},
+		{"SELECT name FROM people WHERE EXISTS (SELECT * FROM pets WHERE owner = p.id)", ErrColumnNotFound},
+		{"SELECT name FROM people WHERE EXISTS (SELECT * FROM animals)", ErrTableNotFound},
+	} {
+		_, err := mb.Select(parseStatement(t, tt.input).(*ast.SelectStatement))
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %q error, got %v", tt.input, tt.expected, err)
+		}
+	}
+
+	// Subqueries are run before rows are changed
+	execStatement(t, mb, "UPDATE people SET age = (SELECT MAX(age) FROM people) + 1 WHERE id IN (SELECT owner FROM pets WHERE kind = 'dog')")
+	execStatement(t, mb, "DELETE FROM people WHERE NOT EXISTS (SELECT * FROM pets WHERE pets.owner = people.id)")
+	rows := orderedRows(t, mb, "SELECT name, age FROM people")
+	if strings.Join(rows, "|") != "John,41|Julia,30" {
+		t.Errorf("expected [John,41 Julia,30], got %v", rows)
+	}
+}
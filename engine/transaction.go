@@ -0,0 +1,471 @@
+package engine
+
+// snapshot records which transactions had committed when it was taken.
+type snapshot struct {
+	// next is the first transaction ID that wasn't assigned yet
+	next uint64
+	// active holds the transactions that were still running
+	active map[uint64]bool
+	// xmin is the oldest transaction the snapshot doesn't see as committed
+	xmin uint64
+}
+
+type touchedRow struct {
+	kind  mutationKind
+	table *memoryTable
+	row   *memoryRow
+}
+
+type touchedIndex struct {
+	kind  mutationKind
+	table *memoryTable
+	index *tableIndex
+}
+
+// txMark is the point a transaction can be rolled back to, as the lengths
+// of its logs when it was taken.
+type txMark struct {
+	mutations int
+	rows      int
+	tables    int
+	indexes   int
+}
+
+// transaction writes new row versions tagged with its ID and reads the
+// versions visible in its snapshot. A transaction with ID 0 only reads.
+type transaction struct {
+	id   uint64
+	snap *snapshot
+	// mutations are journaled when the transaction commits
+	mutations []*mutation
+	rows      []*touchedRow
+	tables    []string
+	indexes   []*touchedIndex
+}
+
+// visible reports whether the changes of transaction xid are visible to tx.
+func (tx *transaction) visible(xid uint64) bool {
+	if xid == tx.id && xid != 0 {
+		return true
+	}
+	return xid < tx.snap.next && !tx.snap.active[xid]
+}
+
+// version returns the version of row visible to tx, if any.
+func (tx *transaction) version(row *memoryRow) *rowVersion {
+	for v := row.version; v != nil; v = v.prev {
+		if tx.visible(v.xmin) && (v.xmax == 0 || !tx.visible(v.xmax)) {
+			return v
+		}
+	}
+	return nil
+}
+
+func (tx *transaction) mark() txMark {
+	return txMark{
+		mutations: len(tx.mutations),
+		rows:      len(tx.rows),
+		tables:    len(tx.tables),
+		indexes:   len(tx.indexes),
+	}
+}
+
+func (tx *transaction) createTable(mb *MemoryBackend, name string, t *memoryTable) {
+	t.createdBy = tx.id
+	mb.tables[name] = t
+	tx.tables = append(tx.tables, name)
+
+	tx.mutations = append(tx.mutations, &mutation{
+		kind:   createTableMutation,
+		table:  name,
+		schema: t,
+	})
+}
+
+func (tx *transaction) createIndex(name string, t *memoryTable, idx *tableIndex) {
+	idx.createdBy = tx.id
+	t.indexes = append(t.indexes, idx)
+	tx.indexes = append(tx.indexes, &touchedIndex{kind: createIndexMutation, table: t, index: idx})
+
+	tx.mutations = append(tx.mutations, &mutation{
+		kind:  createIndexMutation,
+		table: name,
+		index: idx,
+	})
+}
+
+// dropIndex hides the index from other statements. It keeps being
+// maintained, and used by running transactions, until tx commits.
+func (tx *transaction) dropIndex(name string, t *memoryTable, idx *tableIndex) {
+	idx.droppedBy = tx.id
+	tx.indexes = append(tx.indexes, &touchedIndex{kind: dropIndexMutation, table: t, index: idx})
+
+	tx.mutations = append(tx.mutations, &mutation{
+		kind:  dropIndexMutation,
+		table: name,
+		index: idx,
+	})
+}
+
+func (tx *transaction) insert(name string, t *memoryTable, cells []memoryCell) {
+	t.lastRowID++
+	row := &memoryRow{
+		id:      t.lastRowID,
+		version: &rowVersion{cells: cells, xmin: tx.id},
+	}
+	t.rows = append(t.rows, row)
+	t.index(row, cells)
+	tx.rows = append(tx.rows, &touchedRow{kind: insertMutation, table: t, row: row})
+
+	tx.mutations = append(tx.mutations, &mutation{
+		kind:  insertMutation,
+		table: name,
+		rowID: row.id,
+		cells: cells,
+	})
+}
+
+// update replaces the newest version of row, which the caller has checked
+// is visible to tx and not being replaced by another transaction.
+func (tx *transaction) update(name string, t *memoryTable, row *memoryRow, cells []memoryCell) {
+	row.version.xmax = tx.id
+	row.version = &rowVersion{cells: cells, xmin: tx.id, prev: row.version}
+	t.index(row, cells)
+	tx.rows = append(tx.rows, &touchedRow{kind: updateMutation, table: t, row: row})
+
+	tx.mutations = append(tx.mutations, &mutation{
+		kind:  updateMutation,
+		table: name,
+		rowID: row.id,
+		cells: cells,
+	})
+}
+
+// delete marks the newest version of row as deleted, under the same
+// conditions as update.
+func (tx *transaction) delete(name string, t *memoryTable, row *memoryRow) {
+	row.version.xmax = tx.id
+	tx.rows = append(tx.rows, &touchedRow{kind: deleteMutation, table: t, row: row})
+
+	tx.mutations = append(tx.mutations, &mutation{
+		kind:  deleteMutation,
+		table: name,
+		rowID: row.id,
+	})
+}
+
+// Session runs statements against a MemoryBackend and holds at most one
+// open transaction. A session must not be used by several goroutines at
+// once; create one per goroutine instead.
+type Session struct {
+	backend *MemoryBackend
+	tx      *transaction
+}
+
+func (mb *MemoryBackend) NewSession() *Session {
+	return &Session{backend: mb}
+}
+
+// Begin starts a transaction. Until it is committed, changes made in the
+// session are only visible to the session itself, and the session doesn't
+// see changes committed by others in the meantime.
+func (s *Session) Begin() error {
+	mb := s.backend
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if s.tx != nil {
+		return ErrTransactionInProgress
+	}
+
+	s.tx = mb.begin()
+	return nil
+}
+
+// Commit journals every change made during the transaction as a single
+// unit and makes them visible.
+func (s *Session) Commit() error {
+	mb := s.backend
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if s.tx == nil {
+		return ErrNoTransaction
+	}
+
+	tx := s.tx
+	s.tx = nil
+	return mb.commit(tx)
+}
+
+// Rollback discards every change made during the transaction.
+func (s *Session) Rollback() error {
+	mb := s.backend
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if s.tx == nil {
+		return ErrNoTransaction
+	}
+
+	mb.rollback(s.tx)
+	s.tx = nil
+	return nil
+}
+
+// InTransaction reports whether a transaction is in progress.
+func (s *Session) InTransaction() bool {
+	mb := s.backend
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+
+	return s.tx != nil
+}
+
+// read runs fn in the session's transaction, or against the latest
+// committed state of the tables if there is none.
+func (s *Session) read(fn func(*transaction) error) error {
+	mb := s.backend
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+
+	tx := s.tx
+	if tx == nil {
+		tx = mb.latest()
+	}
+
+	return fn(tx)
+}
+
+// write runs fn in the session's transaction, or in a transaction of its
+// own that is committed if fn succeeds. If fn fails inside the session's
+// transaction, only the changes fn made are undone.
+func (s *Session) write(fn func(*transaction) error) error {
+	mb := s.backend
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if s.tx != nil {
+		mark := s.tx.mark()
+		if err := fn(s.tx); err != nil {
+			mb.undo(s.tx, mark)
+			return err
+		}
+		return nil
+	}
+
+	tx := mb.begin()
+	if err := fn(tx); err != nil {
+		mb.rollback(tx)
+		return err
+	}
+
+	return mb.commit(tx)
+}
+
+// The methods below must be called with mb.mu held.
+
+func (mb *MemoryBackend) snapshot() *snapshot {
+	snap := &snapshot{
+		next:   mb.nextTxID,
+		active: map[uint64]bool{},
+		xmin:   mb.nextTxID,
+	}
+
+	for id := range mb.active {
+		snap.active[id] = true
+		if id < snap.xmin {
+			snap.xmin = id
+		}
+	}
+
+	return snap
+}
+
+// latest returns a read-only transaction that sees everything committed
+// so far.
+func (mb *MemoryBackend) latest() *transaction {
+	return &transaction{snap: mb.snapshot()}
+}
+
+func (mb *MemoryBackend) begin() *transaction {
+	tx := &transaction{id: mb.nextTxID}
+	mb.nextTxID++
+
+	tx.snap = mb.snapshot()
+	if tx.id < tx.snap.xmin {
+		tx.snap.xmin = tx.id
+	}
+
+	mb.active[tx.id] = tx
+	return tx
+}
+
+// table returns the table called name if tx can see it.
+func (mb *MemoryBackend) table(tx *transaction, name string) *memoryTable {
+	t, ok := mb.tables[name]
+	if !ok || !tx.visible(t.createdBy) {
+		return nil
+	}
+	return t
+}
+
+func (mb *MemoryBackend) commit(tx *transaction) error {
+	if len(tx.mutations) > 0 && mb.journal != nil {
+		if err := mb.journal.append(tx.mutations); err != nil {
+			mb.rollback(tx)
+			return err
+		}
+	}
+
+	delete(mb.active, tx.id)
+	mb.pendingRows = append(mb.pendingRows, tx.rows...)
+	for _, name := range tx.tables {
+		mb.pendingTables = append(mb.pendingTables, mb.tables[name])
+	}
+	for _, ti := range tx.indexes {
+		if ti.kind == dropIndexMutation {
+			ti.table.removeIndex(ti.index)
+		} else {
+			ti.index.createdBy = 0
+		}
+	}
+	mb.vacuum()
+
+	if mb.journal != nil {
+		mb.journal.committed()
+	}
+	return nil
+}
+
+func (mb *MemoryBackend) rollback(tx *transaction) {
+	mb.undo(tx, txMark{})
+	delete(mb.active, tx.id)
+	mb.vacuum()
+}
+
+// undo reverts the changes tx made since mark was taken, newest first.
+func (mb *MemoryBackend) undo(tx *transaction, mark txMark) {
+	removed := map[*memoryTable]bool{}
+
+	for i := len(tx.rows) - 1; i >= mark.rows; i-- {
+		tr := tx.rows[i]
+		row := tr.row
+
+		if tr.kind == deleteMutation {
+			row.version.xmax = 0
+			continue
+		}
+
+		undone := row.version
+		row.version = undone.prev
+		if row.version == nil {
+			removed[tr.table] = true
+		} else {
+			row.version.xmax = 0
+		}
+		tr.table.unindex(row, undone.cells)
+	}
+
+	for t := range removed {
+		t.compact()
+	}
+
+	for i := len(tx.indexes) - 1; i >= mark.indexes; i-- {
+		ti := tx.indexes[i]
+		if ti.kind == createIndexMutation {
+			ti.table.removeIndex(ti.index)
+		} else {
+			ti.index.droppedBy = 0
+		}
+	}
+
+	for _, name := range tx.tables[mark.tables:] {
+		delete(mb.tables, name)
+	}
+
+	tx.mutations = tx.mutations[:mark.mutations]
+	tx.rows = tx.rows[:mark.rows]
+	tx.tables = tx.tables[:mark.tables]
+	tx.indexes = tx.indexes[:mark.indexes]
+}
+
+// vacuum drops the row versions no transaction can see anymore and marks
+// the ones every transaction can see as such, so transaction IDs don't
+// have to be remembered forever.
+func (mb *MemoryBackend) vacuum() {
+	horizon := mb.nextTxID
+	for _, tx := range mb.active {
+		if tx.snap.xmin < horizon {
+			horizon = tx.snap.xmin
+		}
+	}
+
+	// settled reports whether xid committed before every running
+	// transaction started
+	settled := func(xid uint64) bool {
+		return xid != 0 && xid < horizon && mb.active[xid] == nil
+	}
+
+	removed := map[*memoryTable]bool{}
+	pendingRows := mb.pendingRows[:0]
+	for _, tr := range mb.pendingRows {
+		v := tr.row.version
+		if v == nil {
+			continue
+		}
+
+		if v.xmax != 0 {
+			if settled(v.xmax) {
+				tr.row.version = nil
+				for ; v != nil; v = v.prev {
+					tr.table.unindex(tr.row, v.cells)
+				}
+				removed[tr.table] = true
+			} else {
+				pendingRows = append(pendingRows, tr)
+			}
+			continue
+		}
+
+		if settled(v.xmin) {
+			v.xmin = 0
+		}
+
+		if v.xmin == 0 {
+			dropped := v.prev
+			v.prev = nil
+			for ; dropped != nil; dropped = dropped.prev {
+				tr.table.unindex(tr.row, dropped.cells)
+			}
+		} else {
+			pendingRows = append(pendingRows, tr)
+		}
+	}
+	mb.pendingRows = pendingRows
+
+	for t := range removed {
+		t.compact()
+	}
+
+	pendingTables := mb.pendingTables[:0]
+	for _, t := range mb.pendingTables {
+		if settled(t.createdBy) {
+			t.createdBy = 0
+		} else if t.createdBy != 0 {
+			pendingTables = append(pendingTables, t)
+		}
+	}
+	mb.pendingTables = pendingTables
+}
+
+// compact removes the rows that no longer have any version.
+func (t *memoryTable) compact() {
+	rows := make([]*memoryRow, 0, len(t.rows))
+	for _, row := range t.rows {
+		if row.version != nil {
+			rows = append(rows, row)
+		}
+	}
+	t.rows = rows
+}
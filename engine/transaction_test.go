@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"fmt"
+	"jnafolayan/sql-db/ast"
+	"sync"
+	"testing"
+)
+
+func TestSnapshotIsolation(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT)")
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('John', 40)")
+
+	reader := mb.NewSession()
+	writer := mb.NewSession()
+
+	reader.Begin()
+	writer.Begin()
+	execStatement(t, writer, "INSERT INTO people (name, age) VALUES ('Julia', 30)")
+	execStatement(t, writer, "UPDATE people SET age=41 WHERE age=40")
+
+	// Uncommitted changes are invisible to everybody else
+	expectRowCount(t, reader, "SELECT name FROM people", 1)
+	expectRowCount(t, reader, "SELECT name FROM people WHERE age=40", 1)
+	expectRowCount(t, mb, "SELECT name FROM people", 1)
+	expectRowCount(t, writer, "SELECT name FROM people", 2)
+
+	if err := writer.Commit(); err != nil {
+		t.Fatalf("error committing transaction: %s", err)
+	}
+
+	// The reader keeps its snapshot until its transaction ends
+	expectRowCount(t, reader, "SELECT name FROM people", 1)
+	expectRowCount(t, reader, "SELECT name FROM people WHERE age=40", 1)
+	expectRowCount(t, mb, "SELECT name FROM people WHERE age=41", 1)
+
+	reader.Commit()
+	expectRowCount(t, reader, "SELECT name FROM people", 2)
+	expectRowCount(t, reader, "SELECT name FROM people WHERE age=41", 1)
+
+	// Tables are subject to the same rules
+	writer.Begin()
+	execStatement(t, writer, "CREATE TABLE pets (name TEXT)")
+	if _, err := reader.Select(parseStatement(t, "SELECT name FROM pets").(*ast.SelectStatement)); err != ErrTableNotFound {
+		t.Fatalf("expected %q error, got %v", ErrTableNotFound, err)
+	}
+	writer.Commit()
+	expectRowCount(t, reader, "SELECT name FROM pets", 0)
+}
+
+func TestSerializationFailure(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT)")
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('John', 40)")
+	execStatement(t, mb, "INSERT INTO people (name, age) VALUES ('Julia', 30)")
+
+	first := mb.NewSession()
+	second := mb.NewSession()
+
+	first.Begin()
+	second.Begin()
+	execStatement(t, first, "UPDATE people SET age=41 WHERE age=40")
+
+	// The row is being updated by a running transaction
+	update := parseStatement(t, "UPDATE people SET age=42 WHERE age=40").(*ast.UpdateStatement)
+	if _, err := second.Update(update); err != ErrSerializationFailure {
+		t.Fatalf("expected %q error, got %v", ErrSerializationFailure, err)
+	}
+
+	// Other rows can still be changed
+	execStatement(t, second, "DELETE FROM people WHERE age=30")
+
+	first.Commit()
+
+	// The row was updated by a transaction that committed after the snapshot
+	// was taken
+	remove := parseStatement(t, "DELETE FROM people WHERE age=40").(*ast.DeleteStatement)
+	if _, err := second.Delete(remove); err != ErrSerializationFailure {
+		t.Fatalf("expected %q error, got %v", ErrSerializationFailure, err)
+	}
+
+	second.Commit()
+	expectRowCount(t, mb, "SELECT name FROM people", 1)
+	expectRowCount(t, mb, "SELECT name FROM people WHERE age=41", 1)
+
+	// Once nothing is running, old versions are gone
+	for _, row := range mb.tables["people"].rows {
+		if row.version.prev != nil || row.version.xmin != 0 || row.version.xmax != 0 {
+			t.Errorf("expected row %d to have a single settled version", row.id)
+		}
+	}
+	if len(mb.pendingRows) != 0 {
+		t.Errorf("expected no pending rows, got %d", len(mb.pendingRows))
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE people (name TEXT, age INT)")
+
+	const workers = 8
+	const rowsPerWorker = 50
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*rowsPerWorker*2)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rowsPerWorker; i++ {
+				stmt := parseStatement(t, fmt.Sprintf("INSERT INTO people (name, age) VALUES ('worker %d', %d)", w, i))
+				if err := mb.Insert(stmt.(*ast.InsertStatement)); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+
+		go func(w int) {
+			defer wg.Done()
+
+			// Rows inserted by a worker show up in insertion order, and a
+			// transaction never sees the count change under it
+			s := mb.NewSession()
+			stmt := parseStatement(t, fmt.Sprintf("SELECT age FROM people WHERE name='worker %d'", w)).(*ast.SelectStatement)
+			for i := 0; i < rowsPerWorker; i++ {
+				s.Begin()
+				first, err := s.Select(stmt)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				second, err := s.Select(stmt)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				s.Commit()
+
+				if len(first.Rows) != len(second.Rows) {
+					errs <- fmt.Errorf("snapshot changed from %d to %d rows", len(first.Rows), len(second.Rows))
+				}
+				for j, row := range first.Rows {
+					if row[0].AsInt() != int64(j) {
+						errs <- fmt.Errorf("expected age %d, got %d", j, row[0].AsInt())
+					}
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	expectRowCount(t, mb, "SELECT name FROM people", workers*rowsPerWorker)
+}
+
+func expectRowCount(t *testing.T, e Engine, input string, expected int) {
+	t.Helper()
+
+	res := execStatement(t, e, input).(*FetchResult)
+	if len(res.Rows) != expected {
+		t.Fatalf("%s: expected %d rows, got %d", input, expected, len(res.Rows))
+	}
+}
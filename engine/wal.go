@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// walRecordHeaderSize is the size of a record's length and checksum fields
+const walRecordHeaderSize = 8
+
+// writeAheadLog is an append-only file of mutation records. Every record is
+// fsynced before its mutations are applied to the tables, and the records
+// written since the last checkpoint are replayed when the database is
+// opened. Each record is numbered so that a record already included in the
+// database file is never applied twice.
+type writeAheadLog struct {
+	file *os.File
+	seq  uint64
+	size int64
+}
+
+func openWriteAheadLog(path string) (*writeAheadLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &writeAheadLog{file: file}, nil
+}
+
+// replay calls apply for every intact record numbered after seq. Reading
+// stops at the first truncated or torn record, which is then cut off so
+// that new records are appended after the last intact one.
+func (w *writeAheadLog) replay(seq uint64, apply func([]*mutation) error) error {
+	data, err := io.ReadAll(io.NewSectionReader(w.file, 0, 1<<62))
+	if err != nil {
+		return err
+	}
+
+	w.seq = seq
+	offset := int64(0)
+	for {
+		payload, n := readWALRecord(data[offset:])
+		if payload == nil {
+			break
+		}
+
+		d := newDecoder(payload)
+		recordSeq := d.uvarint()
+		mutations := decodeMutations(d)
+		if d.err != nil {
+			break
+		}
+
+		if recordSeq > w.seq {
+			if err := apply(mutations); err != nil {
+				return err
+			}
+			w.seq = recordSeq
+		}
+		offset += n
+	}
+
+	if offset != int64(len(data)) {
+		if err := w.truncate(offset); err != nil {
+			return err
+		}
+	}
+	w.size = offset
+	return nil
+}
+
+func (w *writeAheadLog) append(mutations []*mutation) error {
+	e := &encoder{}
+	e.putUvarint(w.seq + 1)
+	encodeMutations(e, mutations)
+	payload := e.Bytes()
+
+	record := make([]byte, walRecordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[walRecordHeaderSize:], payload)
+
+	if _, err := w.file.WriteAt(record, w.size); err != nil {
+		// Don't leave a partial record behind for the next append
+		w.truncate(w.size)
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		w.truncate(w.size)
+		return err
+	}
+
+	w.seq++
+	w.size += int64(len(record))
+	return nil
+}
+
+// reset empties the log once its records are part of the database file.
+func (w *writeAheadLog) reset() error {
+	if err := w.truncate(0); err != nil {
+		return err
+	}
+	w.size = 0
+	return nil
+}
+
+func (w *writeAheadLog) truncate(size int64) error {
+	if err := w.file.Truncate(size); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+func (w *writeAheadLog) close() error {
+	return w.file.Close()
+}
+
+// readWALRecord returns the payload of the record at the start of data and
+// the record's total size, or nil if the record is incomplete or corrupt.
+func readWALRecord(data []byte) ([]byte, int64) {
+	if len(data) < walRecordHeaderSize {
+		return nil, 0
+	}
+
+	length := binary.BigEndian.Uint32(data[0:4])
+	if uint64(len(data)-walRecordHeaderSize) < uint64(length) {
+		return nil, 0
+	}
+
+	payload := data[walRecordHeaderSize : walRecordHeaderSize+int(length)]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(data[4:8]) {
+		return nil, 0
+	}
+
+	return payload, walRecordHeaderSize + int64(length)
+}
@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"jnafolayan/sql-db/ast"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteAheadLogRecovery(t *testing.T) {
+	tests := []struct {
+		name         string
+		damage       func(t *testing.T, walPath string)
+		expectedRows int
+	}{
+		{"intact", func(t *testing.T, walPath string) {}, 3},
+		{"truncated tail", func(t *testing.T, walPath string) {
+			info, err := os.Stat(walPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Truncate(walPath, info.Size()-3); err != nil {
+				t.Fatal(err)
+			}
+		}, 2},
+		{"torn tail", func(t *testing.T, walPath string) {
+			info, err := os.Stat(walPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			corruptFile(t, walPath, info.Size()-1)
+		}, 2},
+		{"missing header", func(t *testing.T, walPath string) {
+			f, err := os.OpenFile(walPath, os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Write([]byte{0, 0})
+			f.Close()
+		}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(sub *testing.T) {
+			path := filepath.Join(sub.TempDir(), "test.db")
+
+			fb, err := NewFileBackend(path)
+			if err != nil {
+				sub.Fatalf("error opening database: %s", err)
+			}
+			execStatement(sub, fb, "CREATE TABLE people (name TEXT, age INT)")
+			execStatement(sub, fb, "INSERT INTO people (name, age) VALUES ('John', 40)")
+			execStatement(sub, fb, "INSERT INTO people (name, age) VALUES ('Julia', 30)")
+			execStatement(sub, fb, "INSERT INTO people (name, age) VALUES ('Jake', 20)")
+
+			// Simulate a crash: the database is never closed
+			tt.damage(sub, path+"-wal")
+
+			fb, err = NewFileBackend(path)
+			if err != nil {
+				sub.Fatalf("error reopening database: %s", err)
+			}
+
+			res := execStatement(sub, fb, "SELECT name FROM people").(*FetchResult)
+			if len(res.Rows) != tt.expectedRows {
+				sub.Fatalf("expected %d rows, got %d", tt.expectedRows, len(res.Rows))
+			}
+
+			// The log must still accept records after recovery
+			execStatement(sub, fb, "INSERT INTO people (name, age) VALUES ('Jane', 10)")
+			fb, err = NewFileBackend(path)
+			if err != nil {
+				sub.Fatalf("error reopening database: %s", err)
+			}
+
+			res = execStatement(sub, fb, "SELECT name FROM people").(*FetchResult)
+			if len(res.Rows) != tt.expectedRows+1 {
+				sub.Fatalf("expected %d rows, got %d", tt.expectedRows+1, len(res.Rows))
+			}
+		})
+	}
+}
+
+func TestWriteAheadLogCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	fb, err := NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("error opening database: %s", err)
+	}
+	fb.checkpointSize = 1
+
+	execStatement(t, fb, "CREATE TABLE people (name TEXT, age INT)")
+	execStatement(t, fb, "INSERT INTO people (name, age) VALUES ('John', 40)")
+
+	if fb.wal.size != 0 {
+		t.Fatalf("expected the log to be checkpointed, got %d bytes", fb.wal.size)
+	}
+
+	fb.checkpointSize = defaultCheckpointSize
+	execStatement(t, fb, "INSERT INTO people (name, age) VALUES ('Julia', 30)")
+
+	// Simulate a crash between writing the database file and emptying the
+	// log: the record must not be applied a second time.
+	if err := writeDatabase(fb.path, fb.tables, fb.latest(), fb.wal.seq); err != nil {
+		t.Fatalf("error writing database: %s", err)
+	}
+
+	fb, err = NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("error reopening database: %s", err)
+	}
+
+	res := execStatement(t, fb, "SELECT name FROM people").(*FetchResult)
+	if len(res.Rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(res.Rows))
+	}
+}
+
+func TestWriteAheadLogFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	fb, err := NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("error opening database: %s", err)
+	}
+
+	execStatement(t, fb, "CREATE TABLE people (name TEXT, age INT)")
+	execStatement(t, fb, "INSERT INTO people (name, age) VALUES ('John', 40)")
+	execStatement(t, fb, "INSERT INTO people (name, age) VALUES ('Julia', 30)")
+
+	// Appending to the log fails, so the update must not touch the table
+	fb.wal.file.Close()
+
+	_, err = fb.Update(parseStatement(t, "UPDATE people SET age=1").(*ast.UpdateStatement))
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	for _, row := range fb.tables["people"].rows {
+		if row.version.cells[1].AsInt() == 1 {
+			t.Fatalf("expected row %d to be left untouched", row.id)
+		}
+	}
+}
+
+func TestWriteAheadLogTransactions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	fb, err := NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("error opening database: %s", err)
+	}
+	execStatement(t, fb, "CREATE TABLE people (name TEXT, age INT)")
+
+	fb.Begin()
+	execStatement(t, fb, "INSERT INTO people (name, age) VALUES ('John', 40)")
+	execStatement(t, fb, "INSERT INTO people (name, age) VALUES ('Julia', 30)")
+	seq := fb.wal.seq
+	if err := fb.Commit(); err != nil {
+		t.Fatalf("error committing transaction: %s", err)
+	}
+	if fb.wal.seq != seq+1 {
+		t.Fatalf("expected the transaction to be logged as one record")
+	}
+
+	// A transaction that is still open when the process dies is lost
+	fb.Begin()
+	execStatement(t, fb, "DELETE FROM people")
+
+	fb, err = NewFileBackend(path)
+	if err != nil {
+		t.Fatalf("error reopening database: %s", err)
+	}
+
+	res := execStatement(t, fb, "SELECT name FROM people").(*FetchResult)
+	if len(res.Rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(res.Rows))
+	}
+}
@@ -0,0 +1,392 @@
+package engine
+
+import (
+	"fmt"
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
+	"sort"
+	"strings"
+)
+
+// windowCalls returns the window calls exprs are made of. The arguments
+// and definitions of window calls aren't searched.
+func windowCalls(exprs ...ast.Expression) []*ast.CallExpression {
+	calls := []*ast.CallExpression{}
+	for _, expr := range exprs {
+		ast.Inspect(expr, func(e ast.Expression) bool {
+			call, ok := e.(*ast.CallExpression)
+			if ok && call.Over != nil {
+				calls = append(calls, call)
+				return false
+			}
+			return true
+		})
+	}
+	return calls
+}
+
+// noWindows returns an error if exprs call window functions, which are
+// computed after rows are filtered and grouped.
+func noWindows(exprs ...ast.Expression) error {
+	if calls := windowCalls(exprs...); len(calls) != 0 {
+		return fmt.Errorf("%w %s()", evaluator.ErrMisusedWindow, calls[0].Name)
+	}
+	return nil
+}
+
+// windowType returns the type of the result of a window call. Aggregate
+// calls have the type they have without OVER.
+func (r *relation) windowType(call *ast.CallExpression) (ColumnType, error) {
+	if err := evaluator.CheckWindowCall(call); err != nil {
+		return "", err
+	}
+
+	exprs := append([]ast.Expression{}, call.Arguments...)
+	exprs = append(exprs, call.Over.PartitionBy...)
+	for _, term := range call.Over.OrderBy {
+		exprs = append(exprs, term.Expression)
+	}
+	if err := noWindows(exprs...); err != nil {
+		return "", err
+	}
+	for _, expr := range exprs[len(call.Arguments):] {
+		if _, err := r.exprType(expr); err != nil {
+			return "", err
+		}
+	}
+
+	switch strings.ToUpper(call.Name) {
+	case "ROW_NUMBER", "RANK", "DENSE_RANK":
+		return INT_COLUMN, nil
+	case "LAG", "LEAD":
+		types := []ColumnType{}
+		for _, arg := range call.Arguments {
+			t, err := r.exprType(arg)
+			if err != nil {
+				return "", err
+			}
+			types = append(types, t)
+		}
+
+		// The default takes the place of the value when there's no row
+		if len(types) == 3 {
+			return widerType(types[0], types[2]), nil
+		}
+		return types[0], nil
+	}
+	return r.aggregateType(call)
+}
+
+// windowRow is a row of a query with window calls, kept until the calls
+// are computed: a row of the FROM clause, or a group and its first row.
+type windowRow struct {
+	row   []memoryCell
+	group *group
+}
+
+// windowEntry is a row of a partition of a window call, with the values
+// of the ORDER BY terms and arguments of the call for it.
+type windowEntry struct {
+	// pos is the position of the row among those of the query
+	pos  int
+	keys []evaluator.Value
+	args []evaluator.Value
+}
+
+// computeWindows computes calls over rows, and returns the results of each
+// call for each row. bind binds scope to a row.
+func computeWindows(scope *evaluator.Scope, rows []*windowRow, calls []*ast.CallExpression, bind func(*windowRow)) ([][]evaluator.Value, error) {
+	results := make([][]evaluator.Value, len(rows))
+	for i := range results {
+		results[i] = make([]evaluator.Value, len(calls))
+	}
+
+	for j, call := range calls {
+		values, err := windowValues(scope, rows, call, bind)
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			results[i][j] = v
+		}
+	}
+	return results, nil
+}
+
+// windowValues splits rows into the partitions of call, in the order the
+// partitions are first seen, sorts each of them and computes call for
+// every row of it.
+func windowValues(scope *evaluator.Scope, rows []*windowRow, call *ast.CallExpression, bind func(*windowRow)) ([]evaluator.Value, error) {
+	def := call.Over
+	partitions := [][]*windowEntry{}
+	byKey := map[string]int{}
+	for i, w := range rows {
+		bind(w)
+
+		keys := []string{}
+		for _, expr := range def.PartitionBy {
+			v, err := evaluator.EvalExpression(expr, scope)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, evaluator.Key(v))
+		}
+
+		entry := &windowEntry{pos: i}
+		for _, term := range def.OrderBy {
+			v, err := evaluator.EvalExpression(term.Expression, scope)
+			if err != nil {
+				return nil, err
+			}
+			entry.keys = append(entry.keys, v)
+		}
+
+		if call.Star {
+			entry.args = []evaluator.Value{&evaluator.Int{Value: 1}}
+		}
+		for _, arg := range call.Arguments {
+			v, err := evaluator.EvalExpression(arg, scope)
+			if err != nil {
+				return nil, err
+			}
+			entry.args = append(entry.args, v)
+		}
+
+		key := strings.Join(keys, "\x00")
+		idx, ok := byKey[key]
+		if !ok {
+			idx = len(partitions)
+			byKey[key] = idx
+			partitions = append(partitions, nil)
+		}
+		partitions[idx] = append(partitions[idx], entry)
+	}
+
+	results := make([]evaluator.Value, len(rows))
+	for _, part := range partitions {
+		var sortErr error
+		sort.SliceStable(part, func(a, b int) bool {
+			c, err := compareEntries(def.OrderBy, part[a], part[b])
+			if err != nil && sortErr == nil {
+				sortErr = err
+			}
+			return c < 0
+		})
+		if sortErr != nil {
+			return nil, sortErr
+		}
+
+		values, err := partitionValues(call, part)
+		if err != nil {
+			return nil, err
+		}
+		for i, e := range part {
+			results[e.pos] = values[i]
+		}
+	}
+	return results, nil
+}
+
+// compareEntries compares two rows of a partition by the ORDER BY terms
+// of its window.
+func compareEntries(terms []*ast.OrderingTerm, a *windowEntry, b *windowEntry) (int, error) {
+	for i, term := range terms {
+		c, err := compareKeys(term, a.keys[i], b.keys[i])
+		if c != 0 || err != nil {
+			return c, err
+		}
+	}
+	return 0, nil
+}
+
+// partitionValues computes call for each row of a sorted partition.
+func partitionValues(call *ast.CallExpression, part []*windowEntry) ([]evaluator.Value, error) {
+	// Rows that tie on ORDER BY are peers, and share their rank. starts and
+	// ends hold where the peers of each row start and end.
+	starts, ends := make([]int, len(part)), make([]int, len(part))
+	for i := range part {
+		starts[i] = i
+		if i != 0 {
+			c, err := compareEntries(call.Over.OrderBy, part[i-1], part[i])
+			if err != nil {
+				return nil, err
+			}
+			if c == 0 {
+				starts[i] = starts[i-1]
+			}
+		}
+	}
+	for i := len(part) - 1; i >= 0; i-- {
+		ends[i] = i + 1
+		if i != len(part)-1 && starts[i+1] == starts[i] {
+			ends[i] = ends[i+1]
+		}
+	}
+
+	values := make([]evaluator.Value, len(part))
+	name := strings.ToUpper(call.Name)
+	switch name {
+	case "ROW_NUMBER":
+		for i := range part {
+			values[i] = &evaluator.Int{Value: int64(i + 1)}
+		}
+	case "RANK":
+		for i := range part {
+			values[i] = &evaluator.Int{Value: int64(starts[i] + 1)}
+		}
+	case "DENSE_RANK":
+		rank := int64(0)
+		for i := range part {
+			if starts[i] == i {
+				rank++
+			}
+			values[i] = &evaluator.Int{Value: rank}
+		}
+	case "LAG", "LEAD":
+		for i, e := range part {
+			offset := int64(1)
+			if len(e.args) > 1 {
+				n, ok := e.args[1].(*evaluator.Int)
+				if !ok || n.Value < 0 {
+					return nil, ErrWindowOffset
+				}
+				offset = n.Value
+			}
+			if name == "LAG" {
+				offset = -offset
+			}
+
+			target := int64(i) + offset
+			switch {
+			case target >= 0 && target < int64(len(part)):
+				values[i] = part[target].args[0]
+			case len(e.args) == 3:
+				values[i] = e.args[2]
+			default:
+				values[i] = &evaluator.Null{}
+			}
+		}
+	default:
+		return frameValues(call, part, starts, ends)
+	}
+	return values, nil
+}
+
+// frameValues computes an aggregate call over the frame of each row of a
+// sorted partition. Without a frame clause, the frame ends at the last
+// peer of the row if the window is ordered, and holds the whole partition
+// otherwise.
+func frameValues(call *ast.CallExpression, part []*windowEntry, starts []int, ends []int) ([]evaluator.Value, error) {
+	frame := call.Over.Frame
+	if frame == nil {
+		frame = &ast.WindowFrame{
+			Unit:  ast.ROWS_FRAME,
+			Start: &ast.FrameBound{Kind: ast.UNBOUNDED_PRECEDING},
+			End:   &ast.FrameBound{Kind: ast.UNBOUNDED_FOLLOWING},
+		}
+		if len(call.Over.OrderBy) != 0 {
+			frame.Unit = ast.RANGE_FRAME
+			frame.End = &ast.FrameBound{Kind: ast.CURRENT_ROW}
+		}
+	}
+
+	startOffset, err := frameOffset(frame.Start)
+	if err != nil {
+		return nil, err
+	}
+	endOffset, err := frameOffset(frame.End)
+	if err != nil {
+		return nil, err
+	}
+
+	// bound returns the position of a bound of the frame of row i. The
+	// frame ends before the position of its end.
+	bound := func(b *ast.FrameBound, offset int, i int, end bool) int {
+		var pos int
+		switch b.Kind {
+		case ast.UNBOUNDED_PRECEDING:
+			pos = 0
+		case ast.OFFSET_PRECEDING:
+			pos = i - offset
+		case ast.CURRENT_ROW:
+			pos = i
+			if frame.Unit == ast.RANGE_FRAME {
+				pos = starts[i]
+				if end {
+					pos = ends[i] - 1
+				}
+			}
+		case ast.OFFSET_FOLLOWING:
+			pos = i + offset
+		case ast.UNBOUNDED_FOLLOWING:
+			pos = len(part) - 1
+		}
+		if end {
+			pos++
+		}
+
+		if pos < 0 {
+			return 0
+		}
+		if pos > len(part) {
+			return len(part)
+		}
+		return pos
+	}
+
+	values := make([]evaluator.Value, len(part))
+
+	// Frames that start at the first row grow with every row, so one
+	// accumulator is stepped through the partition
+	if frame.Start.Kind == ast.UNBOUNDED_PRECEDING {
+		acc, err := evaluator.NewAccumulator(call)
+		if err != nil {
+			return nil, err
+		}
+
+		added := 0
+		for i := range part {
+			for end := bound(frame.End, endOffset, i, true); added < end; added++ {
+				if err := acc.Add(part[added].args[0]); err != nil {
+					return nil, err
+				}
+			}
+			values[i] = acc.Result()
+		}
+		return values, nil
+	}
+
+	for i := range part {
+		acc, err := evaluator.NewAccumulator(call)
+		if err != nil {
+			return nil, err
+		}
+
+		start, end := bound(frame.Start, startOffset, i, false), bound(frame.End, endOffset, i, true)
+		for j := start; j < end; j++ {
+			if err := acc.Add(part[j].args[0]); err != nil {
+				return nil, err
+			}
+		}
+		values[i] = acc.Result()
+	}
+	return values, nil
+}
+
+// frameOffset evaluates the offset of a frame bound, which must be
+// constant. It's 0 if the bound has none.
+func frameOffset(b *ast.FrameBound) (int, error) {
+	if b.Offset == nil {
+		return 0, nil
+	}
+
+	value, err := evaluator.EvalExpression(b.Offset, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrWindowOffset, err)
+	}
+	i, ok := value.(*evaluator.Int)
+	if !ok || i.Value < 0 {
+		return 0, ErrWindowOffset
+	}
+	return int(i.Value), nil
+}
@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"errors"
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
+	"strings"
+	"testing"
+)
+
+func TestWindows(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE sales (id INT, team TEXT, amount INT)")
+	execStatement(t, mb, "INSERT INTO sales (id, team, amount) VALUES (1, 'a', 10)")
+	execStatement(t, mb, "INSERT INTO sales (id, team, amount) VALUES (2, 'b', 20)")
+	execStatement(t, mb, "INSERT INTO sales (id, team, amount) VALUES (3, 'a', 30)")
+	execStatement(t, mb, "INSERT INTO sales (id, team, amount) VALUES (4, 'a', 30)")
+	execStatement(t, mb, "INSERT INTO sales (id, team, amount) VALUES (5, 'b', 5)")
+	execStatement(t, mb, "INSERT INTO sales (id, team) VALUES (6, 'a')")
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"SELECT id, ROW_NUMBER() OVER (ORDER BY id DESC) FROM sales", []string{"1,6", "2,5", "3,4", "4,3", "5,2", "6,1"}},
+		{
+			"SELECT id, ROW_NUMBER() OVER (PARTITION BY team ORDER BY amount DESC, id) FROM sales",
+			[]string{"1,3", "2,1", "3,1", "4,2", "5,2", "6,4"},
+		},
+		// Peers share their rank
+		{
+			"SELECT id, RANK() OVER (PARTITION BY team ORDER BY amount), DENSE_RANK() OVER (PARTITION BY team ORDER BY amount) FROM sales",
+			[]string{"1,2,2", "2,2,2", "3,3,3", "4,3,3", "5,1,1", "6,1,1"},
+		},
+		{"SELECT id, RANK() OVER () FROM sales WHERE team = 'b'", []string{"2,1", "5,1"}},
+		{
+			"SELECT id, LAG(amount) OVER (ORDER BY id), LEAD(amount, 2, -1) OVER (ORDER BY id) FROM sales",
+			[]string{"1,NULL,30", "2,10,30", "3,20,5", "4,30,NULL", "5,30,-1", "6,5,-1"},
+		},
+		{"SELECT id, LAG(id, 0) OVER (PARTITION BY team) FROM sales WHERE id < 3", []string{"1,1", "2,2"}},
+
+		// Ordered windows add up the rows up to the last peer of the row,
+		// and others the whole partition
+		{
+			"SELECT id, SUM(amount) OVER (PARTITION BY team ORDER BY amount), COUNT(*) OVER (PARTITION BY team) FROM sales",
+			[]string{"1,10,4", "2,25,2", "3,70,4", "4,70,4", "5,5,2", "6,NULL,4"},
+		},
+		{
+			"SELECT id, SUM(amount) OVER (ORDER BY id ROWS BETWEEN 2 PRECEDING AND CURRENT ROW) FROM sales",
+			[]string{"1,10", "2,30", "3,60", "4,80", "5,65", "6,35"},
+		},
+		{
+			"SELECT id, MAX(amount) OVER (ORDER BY id ROWS BETWEEN 1 FOLLOWING AND 2 FOLLOWING), COUNT(amount) OVER (ORDER BY id ROWS UNBOUNDED PRECEDING) FROM sales",
+			[]string{"1,30,1", "2,30,2", "3,30,3", "4,5,4", "5,NULL,5", "6,NULL,5"},
+		},
+		{
+			"SELECT id, AVG(amount) OVER (PARTITION BY team ORDER BY id RANGE BETWEEN CURRENT ROW AND UNBOUNDED FOLLOWING) FROM sales WHERE team = 'b'",
+			[]string{"2,12.500000", "5,5.000000"},
+		},
+
+		// Windows are computed over groups, and can be ordered by
+		{
+			"SELECT team, SUM(amount), RANK() OVER (ORDER BY SUM(amount) DESC) FROM sales GROUP BY team",
+			[]string{"a,70,1", "b,25,2"},
+		},
+		{
+			"SELECT id FROM sales ORDER BY ROW_NUMBER() OVER (PARTITION BY team ORDER BY id) DESC, id LIMIT 3",
+			[]string{"6", "4", "3"},
+		},
+		{
+			"SELECT DISTINCT team, COUNT(*) OVER (PARTITION BY team) FROM sales ORDER BY team",
+			[]string{"a,4", "b,2"},
+		},
+		{
+			"WITH ranked AS (SELECT id, RANK() OVER (PARTITION BY team ORDER BY amount DESC) AS r FROM sales) SELECT id FROM ranked WHERE r = 1",
+			[]string{"2", "3", "4"},
+		},
+	}
+
+	for _, tt := range tests {
+		rows := orderedRows(t, mb, tt.input)
+		if strings.Join(rows, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%s: expected %v, got %v", tt.input, tt.expected, rows)
+		}
+	}
+
+	// Results are new columns of the rows
+	res := execStatement(t, mb, "SELECT ROW_NUMBER() OVER () AS n, LAG(amount, 1, 0.5) OVER () FROM sales").(*FetchResult)
+	if res.Columns[0].Name != "n" || res.Columns[0].Type != INT_COLUMN || res.Columns[1].Type != FLOAT_COLUMN {
+		t.Errorf("expected columns n INT and FLOAT, got %s %s and %s", res.Columns[0].Name, res.Columns[0].Type, res.Columns[1].Type)
+	}
+
+	for _, tt := range []struct {
+		input    string
+		expected error
+	}{
+		{"SELECT id FROM sales WHERE ROW_NUMBER() OVER () > 1", evaluator.ErrMisusedWindow},
+		{"SELECT team FROM sales GROUP BY team HAVING RANK() OVER () = 1", evaluator.ErrMisusedWindow},
+		{"SELECT ROW_NUMBER() FROM sales", evaluator.ErrMisusedWindow},
+		{"SELECT RANK(id) OVER () FROM sales", evaluator.ErrMisusedWindow},
+		{"SELECT LAG() OVER () FROM sales", evaluator.ErrMisusedWindow},
+		{"SELECT SUM(id) OVER (PARTITION BY ROW_NUMBER() OVER ()) FROM sales", evaluator.ErrMisusedWindow},
+		{"SELECT SUM(id) OVER (ORDER BY id ROWS 'a' PRECEDING) FROM sales", ErrWindowOffset},
+		{"SELECT LEAD(id, -1) OVER () FROM sales", ErrWindowOffset},
+		{"SELECT RANK() OVER (ORDER BY score) FROM sales", ErrColumnNotFound},
+	} {
+		_, err := mb.Select(parseStatement(t, tt.input).(*ast.SelectStatement))
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %q error, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package engine
+
+import (
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/parser"
+)
+
+// withTable is a table of a WITH clause. Its query is run once, and its
+// rows are kept for the rest of the statement.
+type withTable struct {
+	columns []*ResultColumn
+	rows    [][]memoryCell
+	// read is set once a query reads the rows of the table
+	read bool
+}
+
+// relation returns a relation with the columns of t, qualified by the
+// name or alias it has in from, and no rows.
+func (t *withTable) relation(from *ast.TableName) *relation {
+	columns := []*relationColumn{}
+	for _, col := range t.columns {
+		columns = append(columns, &relationColumn{
+			table:      qualifier(from),
+			name:       col.Name,
+			columnType: col.Type,
+		})
+	}
+	return newRelation(columns)
+}
+
+// withTables are the tables of a WITH clause. The tables of the clauses of
+// the queries it's nested in are looked up after them.
+type withTables struct {
+	tables map[string]*withTable
+	outer  *withTables
+}
+
+// table returns the table called name, or nil if there's none.
+func (w *withTables) table(name string) *withTable {
+	for ; w != nil; w = w.outer {
+		if t, ok := w.tables[name]; ok {
+			return t
+		}
+	}
+	return nil
+}
+
+// withContext returns a context for a query of a WITH clause of the query
+// of c, which reads the tables in w.
+func (c *queryContext) withContext(w *withTables) *queryContext {
+	return &queryContext{session: c.session, tx: c.tx, outer: c.outer, with: w}
+}
+
+// materialize runs the queries of a WITH clause and adds their results to
+// the tables c reads. A query reads the tables before its own, and a
+// recursive one its own as well.
+func (c *queryContext) materialize(clause *ast.WithClause) error {
+	w := &withTables{tables: map[string]*withTable{}, outer: c.with}
+	for _, cte := range clause.Tables {
+		name := cte.Name.Literal
+		if _, ok := w.tables[name]; ok {
+			return ErrDuplicateTable
+		}
+
+		query, recursive := cte.Select, (*ast.CompoundSelect)(nil)
+		if clause.Recursive {
+			query, recursive = splitRecursive(cte.Select)
+		}
+
+		res, err := c.withContext(w).query(query)
+		if err != nil {
+			return err
+		}
+
+		table, err := newWithTable(cte, res)
+		if err != nil {
+			return err
+		}
+
+		w.tables[name] = table
+		if recursive != nil {
+			if err := c.recurse(recursive, table, w); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.with = w
+	return nil
+}
+
+// splitRecursive splits the query of a table of a recursive clause into
+// the query before its last UNION and the query after it, which reads the
+// table. The query isn't recursive if it doesn't end with a UNION that
+// it's only ordered and limited by.
+func splitRecursive(stmt *ast.SelectStatement) (*ast.SelectStatement, *ast.CompoundSelect) {
+	last := len(stmt.Compound) - 1
+	if last < 0 || stmt.Compound[last].Operator != ast.UNION || len(stmt.OrderBy) != 0 || stmt.Limit != nil || stmt.Offset != nil {
+		return stmt, nil
+	}
+
+	query := *stmt
+	query.Compound = stmt.Compound[:last]
+	return &query, stmt.Compound[last]
+}
+
+// newWithTable returns a table with the result of the query of cte, or of
+// the queries before the recursive one, named as cte lists.
+func newWithTable(cte *ast.CommonTableExpression, res *FetchResult) (*withTable, error) {
+	if len(cte.Columns) != 0 && len(cte.Columns) != len(res.Columns) {
+		return nil, ErrWithColumns
+	}
+
+	t := &withTable{}
+	for i, col := range res.Columns {
+		name := col.Name
+		if len(cte.Columns) != 0 {
+			name = cte.Columns[i].Literal
+		}
+		t.columns = append(t.columns, &ResultColumn{Type: col.Type, Name: name})
+	}
+
+	for _, row := range res.Rows {
+		t.rows = append(t.rows, memoryRowCells(row))
+	}
+	return t, nil
+}
+
+// Recursion that doesn't end on its own is stopped once the recursive
+// query of a table has run maxRecursion times, or the table holds more
+// than maxRecursiveRows rows.
+const (
+	maxRecursion     = 10000
+	maxRecursiveRows = 100000
+)
+
+// recurse adds the rows of the recursive query of a table to t, which
+// holds the rows of the queries before it. Each run of the query reads the
+// rows the run before it added, until it adds none.
+func (c *queryContext) recurse(recursive *ast.CompoundSelect, t *withTable, w *withTables) error {
+	previous := t.columns
+	types, err := c.recursiveTypes(recursive, t, w)
+	if err != nil {
+		return err
+	}
+	rows, err := convertRows(t.rows, previous, types)
+	if err != nil {
+		return err
+	}
+
+	// Without ALL, rows that were already added are left out
+	seen := map[string]bool{}
+	unique := func(rows [][]memoryCell) [][]memoryCell {
+		if recursive.All {
+			return rows
+		}
+
+		added := [][]memoryCell{}
+		for _, row := range rows {
+			key := rowKey(row, types)
+			if !seen[key] {
+				seen[key] = true
+				added = append(added, row)
+			}
+		}
+		return added
+	}
+
+	all := unique(rows)
+	working := all
+	for runs := 0; len(working) != 0; runs++ {
+		if runs == maxRecursion {
+			return ErrRecursionLimit
+		}
+
+		t.rows = working
+		t.read = false
+
+		res, err := c.withContext(w).query(recursive.Select)
+		if err != nil {
+			return err
+		}
+
+		fetched := [][]memoryCell{}
+		for _, row := range res.Rows {
+			fetched = append(fetched, memoryRowCells(row))
+		}
+		working, err = convertRows(fetched, res.Columns, types)
+		if err != nil {
+			return err
+		}
+		working = unique(working)
+		all = append(all, working...)
+		if len(all) > maxRecursiveRows {
+			return ErrRecursionLimit
+		}
+
+		// A query that doesn't read the table adds the same rows every time
+		if !t.read {
+			break
+		}
+	}
+
+	t.rows = all
+	return nil
+}
+
+// recursiveTypes widens the types of the columns of t, which has the
+// columns of the queries before the recursive one, to hold the values of
+// the recursive query as well. It returns the new types.
+func (c *queryContext) recursiveTypes(recursive *ast.CompoundSelect, t *withTable, w *withTables) ([]ColumnType, error) {
+	columns, err := c.withContext(w).describe(recursive.Select)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) != len(t.columns) {
+		return nil, parser.ErrCompoundColumns
+	}
+
+	types := []ColumnType{}
+	widened := []*ResultColumn{}
+	for i, col := range t.columns {
+		types = append(types, widerType(col.Type, columns[i].Type))
+		widened = append(widened, &ResultColumn{Type: types[i], Name: col.Name})
+	}
+	t.columns = widened
+	return types, nil
+}
+
+// convertRows converts the cells of rows, which have the given columns,
+// to cells of columns of the given types.
+func convertRows(rows [][]memoryCell, columns []*ResultColumn, types []ColumnType) ([][]memoryCell, error) {
+	converted := [][]memoryCell{}
+	for _, row := range rows {
+		cells := []memoryCell{}
+		for i, cell := range row {
+			if columns[i].Type != types[i] {
+				c, err := resultCell(types[i], cell.Value(columns[i].Type))
+				if err != nil {
+					return nil, err
+				}
+				cell = c
+			}
+			cells = append(cells, cell)
+		}
+		converted = append(converted, cells)
+	}
+	return converted, nil
+}
+
+// memoryRowCells returns the cells of a result row, as stored.
+func memoryRowCells(row []Cell) []memoryCell {
+	cells := []memoryCell{}
+	for _, cell := range row {
+		cells = append(cells, cell.(memoryCell))
+	}
+	return cells
+}
+
+// describe returns the columns of the result of stmt, without running it.
+// Like query, it's only called once on a context.
+func (c *queryContext) describe(stmt *ast.SelectStatement) ([]*ResultColumn, error) {
+	if stmt.With != nil {
+		if err := c.describeWith(stmt.With); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(stmt.Compound) != 0 {
+		described := [][]*ResultColumn{}
+		for _, sel := range compoundSelects(stmt) {
+			columns, err := c.withContext(c.with).describe(sel)
+			if err != nil {
+				return nil, err
+			}
+			described = append(described, columns)
+		}
+		return compoundColumns(described)
+	}
+
+	rel, err := c.schema(stmt.From)
+	if err != nil {
+		return nil, err
+	}
+
+	projections, err := rel.projections(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := []*ResultColumn{}
+	for _, p := range projections {
+		columns = append(columns, p.column)
+	}
+	return columns, nil
+}
+
+// describeWith adds the tables of a WITH clause to the tables c reads,
+// with their columns but without rows.
+func (c *queryContext) describeWith(clause *ast.WithClause) error {
+	w := &withTables{tables: map[string]*withTable{}, outer: c.with}
+	for _, cte := range clause.Tables {
+		name := cte.Name.Literal
+		if _, ok := w.tables[name]; ok {
+			return ErrDuplicateTable
+		}
+
+		query, recursive := cte.Select, (*ast.CompoundSelect)(nil)
+		if clause.Recursive {
+			query, recursive = splitRecursive(cte.Select)
+		}
+
+		columns, err := c.withContext(w).describe(query)
+		if err != nil {
+			return err
+		}
+
+		table, err := newWithTable(cte, &FetchResult{Columns: columns})
+		if err != nil {
+			return err
+		}
+
+		w.tables[name] = table
+		if recursive != nil {
+			if _, err := c.recursiveTypes(recursive, table, w); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.with = w
+	return nil
+}
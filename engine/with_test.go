@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"errors"
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/parser"
+	"strings"
+	"testing"
+)
+
+func TestWith(t *testing.T) {
+	mb := NewMemoryBackend(nil)
+	execStatement(t, mb, "CREATE TABLE employees (id INT, name TEXT, manager INT, salary FLOAT)")
+	execStatement(t, mb, "INSERT INTO employees (id, name, salary) VALUES (1, 'Ada', 300)")
+	execStatement(t, mb, "INSERT INTO employees (id, name, manager, salary) VALUES (2, 'Bob', 1, 200)")
+	execStatement(t, mb, "INSERT INTO employees (id, name, manager, salary) VALUES (3, 'Cy', 1, 150)")
+	execStatement(t, mb, "INSERT INTO employees (id, name, manager, salary) VALUES (4, 'Di', 2, 100)")
+	execStatement(t, mb, "INSERT INTO employees (id, name, manager, salary) VALUES (5, 'Ed', 4, 50)")
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"WITH rich AS (SELECT name FROM employees WHERE salary > 120) SELECT * FROM rich", []string{"Ada", "Bob", "Cy"}},
+		{
+			"WITH rich (who, pay) AS (SELECT name, salary FROM employees WHERE salary > 120) SELECT who FROM rich WHERE pay < 250 ORDER BY who DESC",
+			[]string{"Cy", "Bob"},
+		},
+		// Later tables read the ones before them, and tables hide the ones
+		// they share their name with
+		{
+			"WITH bosses AS (SELECT DISTINCT manager FROM employees), employees AS (SELECT name FROM employees AS e JOIN bosses ON e.id = bosses.manager) SELECT name FROM employees",
+			[]string{"Ada", "Bob", "Di"},
+		},
+		{
+			"WITH teams AS (SELECT manager, COUNT(*) AS size FROM employees WHERE manager IS NOT NULL GROUP BY manager) SELECT e.name, t.size FROM employees AS e JOIN teams AS t ON t.manager = e.id",
+			[]string{"Ada,2", "Bob,1", "Di,1"},
+		},
+		{
+			"SELECT name FROM employees WHERE id IN (WITH low AS (SELECT id FROM employees WHERE salary < 120) SELECT id FROM low)",
+			[]string{"Di", "Ed"},
+		},
+		{
+			"WITH top AS (SELECT id FROM employees WHERE manager IS NULL) SELECT name FROM employees WHERE manager IN (SELECT id FROM top)",
+			[]string{"Bob", "Cy"},
+		},
+
+		// Recursive tables
+		{
+			"WITH RECURSIVE chain (id, name, depth) AS (SELECT id, name, 0 FROM employees WHERE manager IS NULL UNION ALL SELECT e.id, e.name, c.depth + 1 FROM employees AS e JOIN chain AS c ON e.manager = c.id) SELECT name, depth FROM chain ORDER BY depth, name",
+			[]string{"Ada,0", "Bob,1", "Cy,1", "Di,2", "Ed,3"},
+		},
+		{
+			"WITH RECURSIVE bosses (id, manager) AS (SELECT id, manager FROM employees WHERE name = 'Ed' UNION SELECT e.id, e.manager FROM employees AS e JOIN bosses AS b ON e.id = b.manager) SELECT name FROM employees WHERE id IN (SELECT id FROM bosses)",
+			[]string{"Ada", "Bob", "Di", "Ed"},
+		},
+		{
+			"WITH RECURSIVE total (id, pay) AS (SELECT id, salary FROM employees WHERE id = 2 UNION ALL SELECT e.id, e.salary + t.pay FROM employees AS e JOIN total AS t ON e.manager = t.id) SELECT id, pay FROM total",
+			[]string{"2,200.000000", "4,300.000000", "5,350.000000"},
+		},
+		// Without ALL, rows that were already added end the recursion
+		{
+			"WITH RECURSIVE cycle (n) AS (SELECT id FROM employees WHERE id = 1 UNION SELECT n % 3 + 1 FROM cycle) SELECT n FROM cycle",
+			[]string{"1", "2", "3"},
+		},
+		{
+			"WITH RECURSIVE counter (n) AS (SELECT id FROM employees WHERE id = 1 UNION ALL SELECT n + 1 FROM counter WHERE n < 5) SELECT COUNT(*), SUM(n) FROM counter",
+			[]string{"5,15"},
+		},
+		// Columns take the type of the values of both queries
+		{
+			"WITH RECURSIVE halves (n) AS (SELECT id FROM employees WHERE id = 1 UNION ALL SELECT n / 2.0 FROM halves WHERE n > 0.3) SELECT n FROM halves",
+			[]string{"1.000000", "0.500000", "0.250000"},
+		},
+		// A query that doesn't read its table is only run once
+		{
+			"WITH RECURSIVE names (name) AS (SELECT name FROM employees WHERE id = 1 UNION ALL SELECT name FROM employees WHERE id = 2) SELECT name FROM names",
+			[]string{"Ada", "Bob"},
+		},
+	}
+
+	for _, tt := range tests {
+		rows := orderedRows(t, mb, tt.input)
+		if strings.Join(rows, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%s: expected %v, got %v", tt.input, tt.expected, rows)
+		}
+	}
+
+	// Subqueries have the types of the columns of the tables they read
+	res := execStatement(t, mb, "SELECT (WITH pay AS (SELECT salary FROM employees) SELECT MAX(salary) FROM pay) FROM employees").(*FetchResult)
+	if res.Columns[0].Type != FLOAT_COLUMN {
+		t.Errorf("expected a FLOAT column, got %s", res.Columns[0].Type)
+	}
+
+	for _, tt := range []struct {
+		input    string
+		expected error
+	}{
+		{"WITH a AS (SELECT id FROM employees), a AS (SELECT id FROM employees) SELECT * FROM a", ErrDuplicateTable},
+		{"WITH a (x, y) AS (SELECT id FROM employees) SELECT * FROM a", ErrWithColumns},
+		{"WITH RECURSIVE a (x) AS (SELECT id FROM employees UNION SELECT * FROM employees) SELECT * FROM a", parser.ErrCompoundColumns},
+		{"WITH a AS (SELECT id FROM a) SELECT * FROM a", ErrTableNotFound},
+		{"WITH a AS (SELECT id FROM employees) SELECT name FROM a", ErrColumnNotFound},
+		// Recursion that doesn't end on its own is stopped
+		{"WITH RECURSIVE n (x) AS (SELECT id FROM employees WHERE id = 1 UNION ALL SELECT x + 1 FROM n) SELECT x FROM n LIMIT 5", ErrRecursionLimit},
+		{"WITH RECURSIVE n (x) AS (SELECT id FROM employees UNION ALL SELECT x FROM n, employees) SELECT x FROM n", ErrRecursionLimit},
+	} {
+		_, err := mb.Select(parseStatement(t, tt.input).(*ast.SelectStatement))
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %q error, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
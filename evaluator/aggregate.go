@@ -0,0 +1,235 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"jnafolayan/sql-db/ast"
+	"strings"
+)
+
+var (
+	ErrUnknownFunction  = errors.New("unknown function")
+	ErrMisusedAggregate = errors.New("misuse of aggregate function")
+)
+
+// aggregate computes the result of an aggregate function from the values
+// of a group, which are never NULL.
+type aggregate interface {
+	step(v Value) error
+	result() Value
+}
+
+var aggregateFns = map[string]func() aggregate{
+	"COUNT": func() aggregate { return &countAggregate{} },
+	"SUM":   func() aggregate { return &sumAggregate{} },
+	"AVG":   func() aggregate { return &avgAggregate{} },
+	"MIN":   func() aggregate { return &extremeAggregate{op: "<"} },
+	"MAX":   func() aggregate { return &extremeAggregate{op: ">"} },
+}
+
+// IsAggregate reports whether call is a call of an aggregate function
+// over a group of rows. Called with OVER, it's a window function.
+func IsAggregate(call *ast.CallExpression) bool {
+	_, ok := aggregateFns[strings.ToUpper(call.Name)]
+	return ok && call.Over == nil
+}
+
+// Accumulator computes an aggregate call over the rows of a group. The
+// rows are fed to it one at a time.
+type Accumulator struct {
+	call *ast.CallExpression
+	agg  aggregate
+	// seen holds the keys of the values of a DISTINCT call
+	seen map[string]bool
+}
+
+func NewAccumulator(call *ast.CallExpression) (*Accumulator, error) {
+	newAggregate, ok := aggregateFns[strings.ToUpper(call.Name)]
+	if !ok {
+		return nil, unknownFunctionError(call)
+	}
+
+	if call.Star && strings.ToUpper(call.Name) != "COUNT" {
+		return nil, fmt.Errorf("%w %s(*)", ErrMisusedAggregate, call.Name)
+	}
+	if !call.Star && len(call.Arguments) != 1 {
+		return nil, fmt.Errorf("%w %s(): expected 1 argument", ErrMisusedAggregate, call.Name)
+	}
+
+	acc := &Accumulator{call: call, agg: newAggregate()}
+	if call.Distinct {
+		acc.seen = map[string]bool{}
+	}
+	return acc, nil
+}
+
+// Step adds the row scope is bound to. Rows whose argument is NULL are
+// skipped.
+func (a *Accumulator) Step(scope *Scope) error {
+	if a.call.Star {
+		return a.agg.step(&Int{Value: 1})
+	}
+
+	v, err := EvalExpression(a.call.Arguments[0], scope)
+	if err != nil {
+		return err
+	}
+	return a.Add(v)
+}
+
+// Add adds the value of the argument of a row. NULL is skipped.
+func (a *Accumulator) Add(v Value) error {
+	if isNull(v) {
+		return nil
+	}
+
+	if a.seen != nil {
+		key := Key(v)
+		if a.seen[key] {
+			return nil
+		}
+		a.seen[key] = true
+	}
+
+	return a.agg.step(v)
+}
+
+func (a *Accumulator) Result() Value {
+	return a.agg.result()
+}
+
+// SetAggregate sets the result of an aggregate call for the group the
+// scope is bound to.
+func (s *Scope) SetAggregate(call *ast.CallExpression, value Value) {
+	s.aggregates[call] = value
+}
+
+// evalCall evaluates a function call. Aggregate and window calls are
+// computed over sets of rows beforehand, and only their results are looked
+// up here.
+func evalCall(node *ast.CallExpression, scope *Scope) (Value, error) {
+	if node.Over != nil {
+		if scope != nil {
+			if v, ok := scope.windows[node]; ok {
+				return v, nil
+			}
+		}
+		return nil, fmt.Errorf("%w %s()", ErrMisusedWindow, node.Name)
+	}
+
+	if !IsAggregate(node) {
+		return nil, unknownFunctionError(node)
+	}
+
+	if scope != nil {
+		if v, ok := scope.aggregates[node]; ok {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %s()", ErrMisusedAggregate, node.Name)
+}
+
+// unknownFunctionError returns the error for a call of a function that
+// isn't an aggregate one. Window functions are only known with OVER.
+func unknownFunctionError(call *ast.CallExpression) error {
+	if windowFns[strings.ToUpper(call.Name)] && call.Over == nil {
+		return fmt.Errorf("%w %s(): OVER is required", ErrMisusedWindow, call.Name)
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownFunction, call.Name)
+}
+
+type countAggregate struct {
+	count int64
+}
+
+func (c *countAggregate) step(v Value) error {
+	c.count++
+	return nil
+}
+
+func (c *countAggregate) result() Value {
+	return &Int{Value: c.count}
+}
+
+// sumAggregate adds numbers up. The sum of INT values is an INT.
+type sumAggregate struct {
+	sum Value
+}
+
+func (s *sumAggregate) step(v Value) error {
+	if !isNumber(v) {
+		return fmt.Errorf("%w: SUM(%s)", ErrInvalidOperation, v.Type())
+	}
+
+	if s.sum == nil {
+		s.sum = v
+		return nil
+	}
+
+	sum, err := evalInfix(s.sum, "+", v)
+	if err != nil {
+		return err
+	}
+	s.sum = sum
+	return nil
+}
+
+func (s *sumAggregate) result() Value {
+	if s.sum == nil {
+		return &Null{}
+	}
+	return s.sum
+}
+
+type avgAggregate struct {
+	sum   float64
+	count int64
+}
+
+func (a *avgAggregate) step(v Value) error {
+	f, ok := ToFloat(v)
+	if !ok {
+		return fmt.Errorf("%w: AVG(%s)", ErrInvalidOperation, v.Type())
+	}
+
+	a.sum += f.Value
+	a.count++
+	return nil
+}
+
+func (a *avgAggregate) result() Value {
+	if a.count == 0 {
+		return &Null{}
+	}
+	return &Float{Value: a.sum / float64(a.count)}
+}
+
+// extremeAggregate keeps the value for which op holds against every other
+// value: the smallest with <, the largest with >.
+type extremeAggregate struct {
+	op    string
+	value Value
+}
+
+func (e *extremeAggregate) step(v Value) error {
+	if e.value == nil {
+		e.value = v
+		return nil
+	}
+
+	better, err := evalInfix(v, e.op, e.value)
+	if err != nil {
+		return err
+	}
+	if isTrue(better) {
+		e.value = v
+	}
+	return nil
+}
+
+func (e *extremeAggregate) result() Value {
+	if e.value == nil {
+		return &Null{}
+	}
+	return e.value
+}
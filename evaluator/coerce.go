@@ -0,0 +1,95 @@
+package evaluator
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// comparisonOperators are the operators whose TEXT operands take on the
+// affinity of a numeric operand.
+var comparisonOperators = map[string]bool{
+	"=":  true,
+	"!=": true,
+	"<":  true,
+	">":  true,
+}
+
+// coerce converts the operands of op to a pair of the same type where it
+// can:
+//
+//   - INT is promoted to FLOAT when the other operand is a FLOAT.
+//   - In comparisons with a number, TEXT that reads as a number is compared
+//     as that number.
+//
+// Anything else is left as is.
+func coerce(left Value, op string, right Value) (Value, Value) {
+	if comparisonOperators[op] {
+		if isNumber(left) {
+			right = numericAffinity(right)
+		}
+		if isNumber(right) {
+			left = numericAffinity(left)
+		}
+	}
+
+	if left.Type() == FLOAT_VALUE || right.Type() == FLOAT_VALUE {
+		if l, ok := ToFloat(left); ok {
+			if r, ok := ToFloat(right); ok {
+				return l, r
+			}
+		}
+	}
+
+	return left, right
+}
+
+// compareClasses compares values of types that can't be converted into
+// each other. Numbers sort before TEXT, so they are never equal.
+func compareClasses(left Value, op string, right Value) (Value, bool) {
+	if !comparisonOperators[op] {
+		return nil, false
+	}
+
+	var less bool
+	switch {
+	case isNumber(left) && right.Type() == TEXT_VALUE:
+		less = true
+	case left.Type() == TEXT_VALUE && isNumber(right):
+		less = false
+	default:
+		return nil, false
+	}
+
+	switch op {
+	case "=":
+		return &Bool{Value: false}, true
+	case "!=":
+		return &Bool{Value: true}, true
+	case "<":
+		return &Bool{Value: less}, true
+	}
+	return &Bool{Value: !less}, true
+}
+
+func isNumber(v Value) bool {
+	return v.Type() == INT_VALUE || v.Type() == FLOAT_VALUE
+}
+
+// numericAffinity converts TEXT that reads as a number to that number.
+func numericAffinity(v Value) Value {
+	text, ok := v.(*Text)
+	if !ok {
+		return v
+	}
+
+	s := strings.TrimSpace(text.Value)
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return &Int{Value: i}
+	}
+	// Words such as "inf" aren't numbers here
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return &Float{Value: f}
+	}
+	return v
+}
@@ -4,198 +4,383 @@ import (
 	"errors"
 	"fmt"
 	"jnafolayan/sql-db/ast"
+	"math"
+	"regexp"
 	"strings"
 )
 
-type infixEvalFn func(ast.Expression, string, ast.Expression) (ast.Expression, error)
+type infixEvalFn func(Value, string, Value) (Value, error)
 
 var infixEvalFns map[string]infixEvalFn
 
+var (
+	ErrInvalidOperation = errors.New("invalid operation")
+	ErrDivisionByZero   = errors.New("division by zero")
+	ErrIntegerOverflow  = errors.New("integer overflow")
+)
+
 func init() {
 	infixEvalFns = map[string]infixEvalFn{}
 
 	// INTEGER + INTEGER
-	registerInfixEvalFn(ast.INTEGER, "+", ast.INTEGER, func(e1 ast.Expression, s string, e2 ast.Expression) (ast.Expression, error) {
-		a, _ := e1.(*ast.IntegerLiteral)
-		b, _ := e2.(*ast.IntegerLiteral)
-		return &ast.IntegerLiteral{
+	registerInfixEvalFn(INT_VALUE, "+", INT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Int)
+		b, _ := v2.(*Int)
+		if (b.Value > 0 && a.Value > math.MaxInt64-b.Value) || (b.Value < 0 && a.Value < math.MinInt64-b.Value) {
+			return nil, ErrIntegerOverflow
+		}
+		return &Int{
 			Value: a.Value + b.Value,
 		}, nil
 	})
 
 	// INTEGER - INTEGER
-	registerInfixEvalFn(ast.INTEGER, "-", ast.INTEGER, func(e1 ast.Expression, s string, e2 ast.Expression) (ast.Expression, error) {
-		a, _ := e1.(*ast.IntegerLiteral)
-		b, _ := e2.(*ast.IntegerLiteral)
-		return &ast.IntegerLiteral{
+	registerInfixEvalFn(INT_VALUE, "-", INT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Int)
+		b, _ := v2.(*Int)
+		if (b.Value < 0 && a.Value > math.MaxInt64+b.Value) || (b.Value > 0 && a.Value < math.MinInt64+b.Value) {
+			return nil, ErrIntegerOverflow
+		}
+		return &Int{
 			Value: a.Value - b.Value,
 		}, nil
 	})
 
 	// INTEGER = INTEGER
-	registerInfixEvalFn(ast.INTEGER, "=", ast.INTEGER, func(e1 ast.Expression, s string, e2 ast.Expression) (ast.Expression, error) {
-		a, _ := e1.(*ast.IntegerLiteral)
-		b, _ := e2.(*ast.IntegerLiteral)
-		return &ast.Boolean{
+	registerInfixEvalFn(INT_VALUE, "=", INT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Int)
+		b, _ := v2.(*Int)
+		return &Bool{
 			Value: a.Value == b.Value,
 		}, nil
 	})
 
 	// FLOAT + FLOAT
-	registerInfixEvalFn(ast.FLOAT, "+", ast.FLOAT, func(e1 ast.Expression, s string, e2 ast.Expression) (ast.Expression, error) {
-		a, _ := e1.(*ast.FloatLiteral)
-		b, _ := e2.(*ast.FloatLiteral)
-		return &ast.FloatLiteral{
+	registerInfixEvalFn(FLOAT_VALUE, "+", FLOAT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Float)
+		b, _ := v2.(*Float)
+		return &Float{
 			Value: a.Value + b.Value,
 		}, nil
 	})
 
 	// FLOAT - FLOAT
-	registerInfixEvalFn(ast.FLOAT, "-", ast.FLOAT, func(e1 ast.Expression, s string, e2 ast.Expression) (ast.Expression, error) {
-		a, _ := e1.(*ast.FloatLiteral)
-		b, _ := e2.(*ast.FloatLiteral)
-		return &ast.FloatLiteral{
+	registerInfixEvalFn(FLOAT_VALUE, "-", FLOAT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Float)
+		b, _ := v2.(*Float)
+		return &Float{
 			Value: a.Value - b.Value,
 		}, nil
 	})
 
 	// FLOAT = FLOAT
-	registerInfixEvalFn(ast.FLOAT, "=", ast.FLOAT, func(e1 ast.Expression, s string, e2 ast.Expression) (ast.Expression, error) {
-		a, _ := e1.(*ast.FloatLiteral)
-		b, _ := e2.(*ast.FloatLiteral)
-		return &ast.Boolean{
+	registerInfixEvalFn(FLOAT_VALUE, "=", FLOAT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Float)
+		b, _ := v2.(*Float)
+		return &Bool{
 			Value: a.Value == b.Value,
 		}, nil
 	})
 
 	// STRING = STRING
-	registerInfixEvalFn(ast.STRING, "=", ast.STRING, func(e1 ast.Expression, s string, e2 ast.Expression) (ast.Expression, error) {
-		a, _ := e1.(*ast.StringLiteral)
-		b, _ := e2.(*ast.StringLiteral)
-		return &ast.Boolean{
+	registerInfixEvalFn(TEXT_VALUE, "=", TEXT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Text)
+		b, _ := v2.(*Text)
+		return &Bool{
 			Value: a.Value == b.Value,
 		}, nil
 	})
 
 	// INTEGER != INTEGER
-	registerInfixEvalFn(ast.INTEGER, "!=", ast.INTEGER, func(e1 ast.Expression, s string, e2 ast.Expression) (ast.Expression, error) {
-		a, _ := e1.(*ast.IntegerLiteral)
-		b, _ := e2.(*ast.IntegerLiteral)
-		return &ast.Boolean{
+	registerInfixEvalFn(INT_VALUE, "!=", INT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Int)
+		b, _ := v2.(*Int)
+		return &Bool{
 			Value: a.Value != b.Value,
 		}, nil
 	})
 
 	// FLOAT != FLOAT
-	registerInfixEvalFn(ast.FLOAT, "!=", ast.FLOAT, func(e1 ast.Expression, s string, e2 ast.Expression) (ast.Expression, error) {
-		a, _ := e1.(*ast.FloatLiteral)
-		b, _ := e2.(*ast.FloatLiteral)
-		return &ast.Boolean{
+	registerInfixEvalFn(FLOAT_VALUE, "!=", FLOAT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Float)
+		b, _ := v2.(*Float)
+		return &Bool{
 			Value: a.Value != b.Value,
 		}, nil
 	})
 
 	// STRING != STRING
-	registerInfixEvalFn(ast.STRING, "!=", ast.STRING, func(e1 ast.Expression, s string, e2 ast.Expression) (ast.Expression, error) {
-		a, _ := e1.(*ast.StringLiteral)
-		b, _ := e2.(*ast.StringLiteral)
-		return &ast.Boolean{
+	registerInfixEvalFn(TEXT_VALUE, "!=", TEXT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Text)
+		b, _ := v2.(*Text)
+		return &Bool{
 			Value: a.Value != b.Value,
 		}, nil
 	})
 
 	// INTEGER < INTEGER
-	registerInfixEvalFn(ast.INTEGER, "<", ast.INTEGER, func(e1 ast.Expression, s string, e2 ast.Expression) (ast.Expression, error) {
-		a, _ := e1.(*ast.IntegerLiteral)
-		b, _ := e2.(*ast.IntegerLiteral)
-		return &ast.Boolean{
+	registerInfixEvalFn(INT_VALUE, "<", INT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Int)
+		b, _ := v2.(*Int)
+		return &Bool{
 			Value: a.Value < b.Value,
 		}, nil
 	})
 
 	// FLOAT < FLOAT
-	registerInfixEvalFn(ast.FLOAT, "<", ast.FLOAT, func(e1 ast.Expression, s string, e2 ast.Expression) (ast.Expression, error) {
-		a, _ := e1.(*ast.FloatLiteral)
-		b, _ := e2.(*ast.FloatLiteral)
-		return &ast.Boolean{
+	registerInfixEvalFn(FLOAT_VALUE, "<", FLOAT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Float)
+		b, _ := v2.(*Float)
+		return &Bool{
 			Value: a.Value < b.Value,
 		}, nil
 	})
 
 	// INTEGER > INTEGER
-	registerInfixEvalFn(ast.INTEGER, ">", ast.INTEGER, func(e1 ast.Expression, s string, e2 ast.Expression) (ast.Expression, error) {
-		a, _ := e1.(*ast.IntegerLiteral)
-		b, _ := e2.(*ast.IntegerLiteral)
-		return &ast.Boolean{
+	registerInfixEvalFn(INT_VALUE, ">", INT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Int)
+		b, _ := v2.(*Int)
+		return &Bool{
 			Value: a.Value > b.Value,
 		}, nil
 	})
 
 	// FLOAT > FLOAT
-	registerInfixEvalFn(ast.FLOAT, ">", ast.FLOAT, func(e1 ast.Expression, s string, e2 ast.Expression) (ast.Expression, error) {
-		a, _ := e1.(*ast.FloatLiteral)
-		b, _ := e2.(*ast.FloatLiteral)
-		return &ast.Boolean{
+	registerInfixEvalFn(FLOAT_VALUE, ">", FLOAT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Float)
+		b, _ := v2.(*Float)
+		return &Bool{
+			Value: a.Value > b.Value,
+		}, nil
+	})
+
+	// INTEGER * INTEGER
+	registerInfixEvalFn(INT_VALUE, "*", INT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Int)
+		b, _ := v2.(*Int)
+		product := a.Value * b.Value
+		if a.Value != 0 && (product/a.Value != b.Value || (a.Value == -1 && b.Value == math.MinInt64)) {
+			return nil, ErrIntegerOverflow
+		}
+		return &Int{
+			Value: product,
+		}, nil
+	})
+
+	// INTEGER / INTEGER
+	registerInfixEvalFn(INT_VALUE, "/", INT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Int)
+		b, _ := v2.(*Int)
+		if b.Value == 0 {
+			return nil, ErrDivisionByZero
+		}
+		// The quotient of the smallest INT by -1 is one more than the largest
+		if a.Value == math.MinInt64 && b.Value == -1 {
+			return nil, ErrIntegerOverflow
+		}
+		return &Int{
+			Value: a.Value / b.Value,
+		}, nil
+	})
+
+	// INTEGER % INTEGER
+	registerInfixEvalFn(INT_VALUE, "%", INT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Int)
+		b, _ := v2.(*Int)
+		if b.Value == 0 {
+			return nil, ErrDivisionByZero
+		}
+		return &Int{
+			Value: a.Value % b.Value,
+		}, nil
+	})
+
+	// FLOAT * FLOAT
+	registerInfixEvalFn(FLOAT_VALUE, "*", FLOAT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Float)
+		b, _ := v2.(*Float)
+		return &Float{
+			Value: a.Value * b.Value,
+		}, nil
+	})
+
+	// FLOAT / FLOAT
+	registerInfixEvalFn(FLOAT_VALUE, "/", FLOAT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Float)
+		b, _ := v2.(*Float)
+		if b.Value == 0 {
+			return nil, ErrDivisionByZero
+		}
+		return &Float{
+			Value: a.Value / b.Value,
+		}, nil
+	})
+
+	// FLOAT % FLOAT
+	registerInfixEvalFn(FLOAT_VALUE, "%", FLOAT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Float)
+		b, _ := v2.(*Float)
+		if b.Value == 0 {
+			return nil, ErrDivisionByZero
+		}
+		return &Float{
+			Value: math.Mod(a.Value, b.Value),
+		}, nil
+	})
+
+	// TEXT < TEXT
+	registerInfixEvalFn(TEXT_VALUE, "<", TEXT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Text)
+		b, _ := v2.(*Text)
+		return &Bool{
+			Value: a.Value < b.Value,
+		}, nil
+	})
+
+	// TEXT > TEXT
+	registerInfixEvalFn(TEXT_VALUE, ">", TEXT_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Text)
+		b, _ := v2.(*Text)
+		return &Bool{
 			Value: a.Value > b.Value,
 		}, nil
 	})
 
 	// BOOLEAN && BOOLEAN
-	registerInfixEvalFn(ast.BOOLEAN, "AND", ast.BOOLEAN, func(e1 ast.Expression, s string, e2 ast.Expression) (ast.Expression, error) {
-		a, _ := e1.(*ast.Boolean)
-		b, _ := e2.(*ast.Boolean)
-		return &ast.Boolean{
+	registerInfixEvalFn(BOOL_VALUE, "AND", BOOL_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Bool)
+		b, _ := v2.(*Bool)
+		return &Bool{
 			Value: a.Value && b.Value,
 		}, nil
 	})
 
 	// BOOLEAN || BOOLEAN
-	registerInfixEvalFn(ast.BOOLEAN, "OR", ast.BOOLEAN, func(e1 ast.Expression, s string, e2 ast.Expression) (ast.Expression, error) {
-		a, _ := e1.(*ast.Boolean)
-		b, _ := e2.(*ast.Boolean)
-		return &ast.Boolean{
+	registerInfixEvalFn(BOOL_VALUE, "OR", BOOL_VALUE, func(v1 Value, s string, v2 Value) (Value, error) {
+		a, _ := v1.(*Bool)
+		b, _ := v2.(*Bool)
+		return &Bool{
 			Value: a.Value || b.Value,
 		}, nil
 	})
 }
 
+// Scope holds the values of the variables, such as column names, an
+// expression may refer to. A scope can be reused for every row of a
+// statement, which shares work such as compiling patterns between rows.
 type Scope struct {
-	vars map[string]ast.Expression
+	vars       map[string]Value
+	patterns   map[string]*regexp.Regexp
+	aggregates map[*ast.CallExpression]Value
+	windows    map[*ast.CallExpression]Value
+
+	// outer is the scope of the statement a subquery is nested in. Its
+	// variables can be referred to unless the scope has its own.
+	outer   *Scope
+	querier Querier
 }
 
 func NewScope() *Scope {
 	return &Scope{
-		vars: map[string]ast.Expression{},
+		vars:       map[string]Value{},
+		patterns:   map[string]*regexp.Regexp{},
+		aggregates: map[*ast.CallExpression]Value{},
+		windows:    map[*ast.CallExpression]Value{},
 	}
 }
 
-func (s *Scope) SetVar(key string, value ast.Expression) {
+func (s *Scope) SetVar(key string, value Value) {
 	s.vars[key] = value
 }
 
-func (s *Scope) GetVar(key string) ast.Expression {
-	val, ok := s.vars[key]
-	if !ok {
-		return nil
+// NewInnerScope returns a scope for a subquery evaluated in outer.
+func NewInnerScope(outer *Scope) *Scope {
+	s := NewScope()
+	s.outer = outer
+	return s
+}
+
+func (s *Scope) GetVar(key string) Value {
+	for ; s != nil; s = s.outer {
+		if val, ok := s.vars[key]; ok {
+			return val
+		}
+	}
+	return nil
+}
+
+// SetQualifiedVar sets the value of the column key of table, which
+// expressions refer to as table.key.
+func (s *Scope) SetQualifiedVar(table string, key string, value Value) {
+	s.vars[qualifiedName(table, key)] = value
+}
+
+func qualifiedName(table string, key string) string {
+	return table + "." + key
+}
+
+// HasVar reports whether key was set, even if to NULL.
+func (s *Scope) HasVar(key string) bool {
+	for ; s != nil; s = s.outer {
+		if _, ok := s.vars[key]; ok {
+			return true
+		}
 	}
-	return val
+	return false
 }
 
-func EvalExpression(expr ast.Expression, scope *Scope) (ast.Expression, error) {
+func EvalExpression(expr ast.Expression, scope *Scope) (Value, error) {
 	switch node := expr.(type) {
 	case *ast.StringLiteral:
-		return node, nil
+		return &Text{Value: node.Value}, nil
 	case *ast.FloatLiteral:
-		return node, nil
+		return &Float{Value: node.Value}, nil
 	case *ast.IntegerLiteral:
-		return node, nil
+		return &Int{Value: node.Value}, nil
 	case *ast.Boolean:
-		return node, nil
+		return &Bool{Value: node.Value}, nil
+	case *ast.Null:
+		return &Null{}, nil
 	case *ast.Identifier:
 		if scope == nil {
 			return nil, errors.New("a scope is required")
 		}
-		return scope.GetVar(node.Value), nil
+		key := node.Value
+		if node.Table != nil {
+			key = qualifiedName(node.Table.Literal, node.Value)
+		}
+		if !scope.HasVar(key) {
+			return nil, fmt.Errorf("unknown column %q", key)
+		}
+		return scope.GetVar(key), nil
+	case *ast.PrefixExpression:
+		right, err := EvalExpression(node.Right, scope)
+		if err != nil {
+			return nil, err
+		}
+
+		// NOT NULL is NULL, like other operations on missing values
+		if strings.ToUpper(node.Operator) == "NOT" && !isNull(right) {
+			return &Bool{Value: !Truthy(right)}, nil
+		}
+
+		switch right := right.(type) {
+		case *Null:
+			return right, nil
+		case *Int:
+			if node.Operator == "-" {
+				if right.Value == math.MinInt64 {
+					return nil, ErrIntegerOverflow
+				}
+				return &Int{Value: -right.Value}, nil
+			}
+		case *Float:
+			if node.Operator == "-" {
+				return &Float{Value: -right.Value}, nil
+			}
+		}
+
+		return nil, fmt.Errorf("%w: %s%s", ErrInvalidOperation, node.Operator, right.Type())
 	case *ast.InfixExpression:
 		left, err := EvalExpression(node.Left, scope)
 		if err != nil {
@@ -207,27 +392,266 @@ func EvalExpression(expr ast.Expression, scope *Scope) (ast.Expression, error) {
 			return nil, err
 		}
 
-		fn, ok := infixEvalFns[toFnString(left, node.Operator, right)]
-		if !ok {
-			return nil, errors.New("invalid operation")
+		switch strings.ToUpper(node.Operator) {
+		case "AND":
+			// FALSE AND NULL is FALSE
+			if isFalse(left) || isFalse(right) {
+				return &Bool{Value: false}, nil
+			}
+		case "OR":
+			// TRUE OR NULL is TRUE
+			if isTrue(left) || isTrue(right) {
+				return &Bool{Value: true}, nil
+			}
 		}
 
-		result, err := fn(left, node.Operator, right)
+		// Operations on missing values have unknown results
+		if isNull(left) || isNull(right) {
+			return &Null{}, nil
+		}
+
+		return evalInfix(left, node.Operator, right)
+	case *ast.InExpression:
+		return evalIn(node, scope)
+	case *ast.SubqueryExpression:
+		return evalSubquery(node, scope)
+	case *ast.ExistsExpression:
+		return evalExists(node, scope)
+	case *ast.BetweenExpression:
+		return evalBetween(node, scope)
+	case *ast.PatternExpression:
+		return evalPattern(node, scope)
+	case *ast.CaseExpression:
+		return evalCase(node, scope)
+	case *ast.CallExpression:
+		return evalCall(node, scope)
+	case *ast.IsExpression:
+		left, err := EvalExpression(node.Left, scope)
 		if err != nil {
 			return nil, err
 		}
 
-		return result, nil
+		return &Bool{Value: isNull(left) != node.Not}, nil
 	}
 
 	return nil, errors.New("invalid expression")
 }
 
-func toFnString(left ast.Expression, op string, right ast.Expression) string {
+func evalIn(node *ast.InExpression, scope *Scope) (Value, error) {
+	left, err := EvalExpression(node.Left, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// Values are evaluated one at a time, until one matches
+	count := len(node.Values)
+	valueAt := func(i int) (Value, error) {
+		return EvalExpression(node.Values[i], scope)
+	}
+	if node.Subquery != nil {
+		values, err := subqueryColumn(node.Subquery, scope)
+		if err != nil {
+			return nil, err
+		}
+		count = len(values)
+		valueAt = func(i int) (Value, error) {
+			return values[i], nil
+		}
+	}
+
+	// Without a match, the result is unknown if a value is missing
+	var result Value = &Bool{Value: false}
+
+	for i := 0; i < count; i++ {
+		value, err := valueAt(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if isNull(left) || isNull(value) {
+			result = &Null{}
+			continue
+		}
+
+		eq, err := evalInfix(left, "=", value)
+		if err != nil {
+			return nil, err
+		}
+
+		if isTrue(eq) {
+			return not(eq, node.Not), nil
+		}
+	}
+
+	return not(result, node.Not), nil
+}
+
+// Equal compares a and b the way = does.
+func Equal(a Value, b Value) (Value, error) {
+	if isNull(a) || isNull(b) {
+		return &Null{}, nil
+	}
+	return evalInfix(a, "=", b)
+}
+
+// Compare returns -1, 0 or 1 as a sorts before, with or after b. Neither
+// may be NULL. Conditions sort like 0 and 1.
+func Compare(a Value, b Value) (int, error) {
+	a, b = boolToInt(a), boolToInt(b)
+
+	less, err := evalInfix(a, "<", b)
+	if err != nil {
+		return 0, err
+	}
+	if isTrue(less) {
+		return -1, nil
+	}
+
+	greater, err := evalInfix(a, ">", b)
+	if err != nil {
+		return 0, err
+	}
+	if isTrue(greater) {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func boolToInt(v Value) Value {
+	if b, ok := v.(*Bool); ok {
+		if b.Value {
+			return &Int{Value: 1}
+		}
+		return &Int{Value: 0}
+	}
+	return v
+}
+
+// evalInfix applies op to operands that aren't NULL.
+func evalInfix(left Value, op string, right Value) (Value, error) {
+	left, right = coerce(left, op, right)
+
+	fn, ok := infixEvalFns[toFnString(left, op, right)]
+	if !ok {
+		if result, ok := compareClasses(left, op, right); ok {
+			return result, nil
+		}
+		return nil, fmt.Errorf("%w: %s %s %s", ErrInvalidOperation, left.Type(), op, right.Type())
+	}
+
+	return fn(left, op, right)
+}
+
+// evalBetween evaluates x BETWEEN low AND high as
+// NOT (x < low) AND NOT (x > high).
+func evalBetween(node *ast.BetweenExpression, scope *Scope) (Value, error) {
+	left, err := EvalExpression(node.Left, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	low, err := EvalExpression(node.Low, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	high, err := EvalExpression(node.High, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Value = &Bool{Value: true}
+	for _, bound := range []struct {
+		op    string
+		value Value
+	}{{"<", low}, {">", high}} {
+		if isNull(left) || isNull(bound.value) {
+			result = &Null{}
+			continue
+		}
+
+		outside, err := evalInfix(left, bound.op, bound.value)
+		if err != nil {
+			return nil, err
+		}
+		if isTrue(outside) {
+			return not(&Bool{Value: false}, node.Not), nil
+		}
+	}
+
+	return not(result, node.Not), nil
+}
+
+// evalCase evaluates the result of the first WHEN that matches, or the
+// ELSE result. Without an ELSE, the result is NULL.
+func evalCase(node *ast.CaseExpression, scope *Scope) (Value, error) {
+	var operand Value
+	if node.Operand != nil {
+		v, err := EvalExpression(node.Operand, scope)
+		if err != nil {
+			return nil, err
+		}
+		operand = v
+	}
+
+	for _, when := range node.Whens {
+		condition, err := EvalExpression(when.Condition, scope)
+		if err != nil {
+			return nil, err
+		}
+
+		matched := Truthy(condition)
+		if operand != nil {
+			// A missing value matches nothing, not even NULL
+			matched = false
+			if !isNull(operand) && !isNull(condition) {
+				eq, err := evalInfix(operand, "=", condition)
+				if err != nil {
+					return nil, err
+				}
+				matched = isTrue(eq)
+			}
+		}
+
+		if matched {
+			return EvalExpression(when.Result, scope)
+		}
+	}
+
+	if node.Else != nil {
+		return EvalExpression(node.Else, scope)
+	}
+	return &Null{}, nil
+}
+
+// not negates v if negate is set. Unknown results stay unknown.
+func not(v Value, negate bool) Value {
+	if b, ok := v.(*Bool); ok && negate {
+		return &Bool{Value: !b.Value}
+	}
+	return v
+}
+
+func isNull(v Value) bool {
+	_, ok := v.(*Null)
+	return ok
+}
+
+func isTrue(v Value) bool {
+	b, ok := v.(*Bool)
+	return ok && b.Value
+}
+
+func isFalse(v Value) bool {
+	b, ok := v.(*Bool)
+	return ok && !b.Value
+}
+
+func toFnString(left Value, op string, right Value) string {
 	return fmt.Sprintf("%s_%s_%s", left.Type(), op, right.Type())
 }
 
-func registerInfixEvalFn(left ast.NodeType, op string, right ast.NodeType, fn infixEvalFn) {
+func registerInfixEvalFn(left ValueType, op string, right ValueType, fn infixEvalFn) {
 	infixEvalFns[fmt.Sprintf("%s_%s_%s", left, op, right)] = fn
 	// Add an evaluator for lowercase operators too
 	infixEvalFns[fmt.Sprintf("%s_%s_%s", left, strings.ToLower(op), right)] = fn
@@ -0,0 +1,38 @@
+package evaluator
+
+import (
+	"jnafolayan/sql-db/ast"
+	"testing"
+)
+
+func TestNot(t *testing.T) {
+	comparison := func(left int64, right ast.Expression) ast.Expression {
+		return &ast.InfixExpression{Left: &ast.IntegerLiteral{Value: left}, Operator: "=", Right: right}
+	}
+
+	tests := []struct {
+		operand  ast.Expression
+		expected string
+	}{
+		{comparison(1, &ast.IntegerLiteral{Value: 1}), "FALSE"},
+		{comparison(1, &ast.IntegerLiteral{Value: 2}), "TRUE"},
+		{&ast.IntegerLiteral{Value: 0}, "TRUE"},
+		{&ast.StringLiteral{Value: "a"}, "FALSE"},
+		// The negation of an unknown result is unknown
+		{&ast.Null{}, "NULL"},
+		{comparison(1, &ast.Null{}), "NULL"},
+		{&ast.PrefixExpression{Operator: "NOT", Right: &ast.Null{}}, "NULL"},
+		{&ast.PrefixExpression{Operator: "not", Right: &ast.FloatLiteral{Value: 0.5}}, "TRUE"},
+	}
+
+	for _, tt := range tests {
+		expr := &ast.PrefixExpression{Operator: "NOT", Right: tt.operand}
+		value, err := EvalExpression(expr, nil)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %q", expr, err)
+		}
+		if value.String() != tt.expected {
+			t.Errorf("%s: expected %s, got %s", expr, tt.expected, value)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"jnafolayan/sql-db/ast"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalidEscape = errors.New("ESCAPE expression must be a single character")
+
+func evalPattern(node *ast.PatternExpression, scope *Scope) (Value, error) {
+	left, err := EvalExpression(node.Left, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	pattern, err := EvalExpression(node.Pattern, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	var escape Value = &Text{}
+	if node.Escape != nil {
+		escape, err = EvalExpression(node.Escape, scope)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if isNull(left) || isNull(pattern) || isNull(escape) {
+		return &Null{}, nil
+	}
+
+	text, ok1 := left.(*Text)
+	p, ok2 := pattern.(*Text)
+	esc, ok3 := escape.(*Text)
+	if !ok1 || !ok2 || !ok3 {
+		return nil, fmt.Errorf("%w: %s %s %s", ErrInvalidOperation, left.Type(), node.Operator, pattern.Type())
+	}
+
+	re, err := scope.pattern(node.Operator, p.Value, esc.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bool{Value: re.MatchString(text.Value) != node.Not}, nil
+}
+
+// pattern returns the compiled form of a LIKE, GLOB or REGEXP pattern.
+// Patterns are compiled once for every scope they are used in.
+func (s *Scope) pattern(op string, pattern string, escape string) (*regexp.Regexp, error) {
+	key := op + "\x00" + escape + "\x00" + pattern
+	if s != nil {
+		if re, ok := s.patterns[key]; ok {
+			return re, nil
+		}
+	}
+
+	var expr string
+	switch op {
+	case "LIKE":
+		e, err := likeRegexp(pattern, escape)
+		if err != nil {
+			return nil, err
+		}
+		expr = e
+	case "GLOB":
+		expr = globRegexp(pattern)
+	default:
+		expr = pattern
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s pattern: %w", op, err)
+	}
+
+	if s != nil {
+		s.patterns[key] = re
+	}
+	return re, nil
+}
+
+// likeRegexp translates a LIKE pattern, in which % matches any sequence of
+// characters and _ any single one. Matching ignores case. The escape
+// character, if any, makes the character after it match itself.
+func likeRegexp(pattern string, escape string) (string, error) {
+	var esc rune = -1
+	if escape != "" {
+		r, size := utf8.DecodeRuneInString(escape)
+		if size != len(escape) {
+			return "", ErrInvalidEscape
+		}
+		esc = r
+	}
+
+	var expr strings.Builder
+	expr.WriteString("(?is)^")
+
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == esc:
+			escaped = true
+		case r == '%':
+			expr.WriteString(".*")
+		case r == '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	// A trailing escape character matches itself
+	if escaped {
+		expr.WriteString(regexp.QuoteMeta(string(esc)))
+	}
+
+	expr.WriteString("$")
+	return expr.String(), nil
+}
+
+// globRegexp translates a GLOB pattern, in which * matches any sequence of
+// characters, ? any single one and [...] one of a set of characters.
+// Matching is case-sensitive.
+func globRegexp(pattern string) string {
+	var expr strings.Builder
+	expr.WriteString("(?s)^")
+
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		case '[':
+			class, end := globClass(runes, i)
+			if end < 0 {
+				expr.WriteString(regexp.QuoteMeta(string(r)))
+				continue
+			}
+			expr.WriteString(class)
+			i = end
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	expr.WriteString("$")
+	return expr.String()
+}
+
+// globClass translates the set of characters starting at runes[start],
+// and returns the position of the bracket that closes it, or -1 if there's
+// none.
+func globClass(runes []rune, start int) (string, int) {
+	var class strings.Builder
+	class.WriteString("[")
+
+	i := start + 1
+	if i < len(runes) && runes[i] == '^' {
+		class.WriteString("^")
+		i++
+	}
+
+	// A closing bracket right at the start is part of the set
+	first := i
+	for ; i < len(runes); i++ {
+		r := runes[i]
+		if r == ']' && i > first {
+			class.WriteString("]")
+			return class.String(), i
+		}
+		if r == '\\' || r == '[' || r == ']' {
+			class.WriteString(`\`)
+		}
+		class.WriteRune(r)
+	}
+
+	return "", -1
+}
@@ -0,0 +1,75 @@
+package evaluator
+
+import (
+	"jnafolayan/sql-db/ast"
+	"testing"
+)
+
+func TestPatterns(t *testing.T) {
+	tests := []struct {
+		op       string
+		text     string
+		pattern  string
+		escape   string
+		expected bool
+	}{
+		{"LIKE", "John", "Jo%", "", true},
+		{"LIKE", "john", "JO%", "", true},
+		{"LIKE", "Julia", "Jo%", "", false},
+		{"LIKE", "Jake", "J_ke", "", true},
+		{"LIKE", "Jke", "J_ke", "", false},
+		{"LIKE", "a.c", "a.c", "", true},
+		{"LIKE", "abc", "a.c", "", false},
+		{"LIKE", "50%", "50!%", "!", true},
+		{"LIKE", "500", "50!%", "!", false},
+		{"LIKE", "a_b", `a\_b`, `\`, true},
+		{"LIKE", "line\nbreak", "line%", "", true},
+		{"GLOB", "John", "Jo*", "", true},
+		{"GLOB", "john", "Jo*", "", false},
+		{"GLOB", "Jake", "J?ke", "", true},
+		{"GLOB", "Jake", "[HJ]ake", "", true},
+		{"GLOB", "Jake", "[^HJ]ake", "", false},
+		{"GLOB", "b1", "[a-c][0-9]", "", true},
+		{"GLOB", "]", "[]]", "", true},
+		{"GLOB", "[a", "[a", "", true},
+		{"REGEXP", "John", "^J.h", "", true},
+		{"REGEXP", "Julia", "li", "", true},
+		{"REGEXP", "Julia", "^li", "", false},
+	}
+
+	scope := NewScope()
+	for _, tt := range tests {
+		re, err := scope.pattern(tt.op, tt.pattern, tt.escape)
+		if err != nil {
+			t.Errorf("%s %s: %s", tt.op, tt.pattern, err)
+			continue
+		}
+		if re.MatchString(tt.text) != tt.expected {
+			t.Errorf("expected %q %s %q to be %v", tt.text, tt.op, tt.pattern, tt.expected)
+		}
+	}
+
+	// Patterns are compiled once per scope
+	first, _ := scope.pattern("LIKE", "Jo%", "")
+	second, _ := scope.pattern("LIKE", "Jo%", "")
+	if first != second {
+		t.Errorf("expected the compiled pattern to be reused")
+	}
+
+	if _, err := scope.pattern("LIKE", "a", "ab"); err != ErrInvalidEscape {
+		t.Errorf("expected %q error, got %v", ErrInvalidEscape, err)
+	}
+	if _, err := scope.pattern("REGEXP", "(", ""); err == nil {
+		t.Errorf("expected an invalid pattern error")
+	}
+
+	// NULL operands give unknown results
+	expr := &ast.PatternExpression{
+		Left:     &ast.Null{},
+		Operator: "LIKE",
+		Pattern:  &ast.StringLiteral{Value: "%"},
+	}
+	if v, err := EvalExpression(expr, scope); err != nil || v.Type() != NULL_VALUE {
+		t.Errorf("expected NULL, got %v (%v)", v, err)
+	}
+}
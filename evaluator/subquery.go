@@ -0,0 +1,73 @@
+package evaluator
+
+import (
+	"errors"
+	"jnafolayan/sql-db/ast"
+)
+
+var (
+	ErrSubqueryColumns = errors.New("subquery must return a single column")
+	ErrSubqueryRows    = errors.New("subquery returned more than one row")
+	ErrNoQuerier       = errors.New("subqueries can't be used here")
+)
+
+// Querier runs the subqueries of expressions. A subquery is run for the
+// row scope is bound to, and can refer to its variables.
+type Querier interface {
+	Query(stmt *ast.SelectStatement, scope *Scope) (columns int, rows [][]Value, err error)
+}
+
+// SetQuerier sets what runs the subqueries of the expressions evaluated in
+// the scope, and in the scopes nested in it.
+func (s *Scope) SetQuerier(querier Querier) {
+	s.querier = querier
+}
+
+func query(node *ast.SubqueryExpression, scope *Scope) (int, [][]Value, error) {
+	for s := scope; s != nil; s = s.outer {
+		if s.querier != nil {
+			return s.querier.Query(node.Select, scope)
+		}
+	}
+	return 0, nil, ErrNoQuerier
+}
+
+// subqueryColumn returns the values of the single column of a subquery.
+func subqueryColumn(node *ast.SubqueryExpression, scope *Scope) ([]Value, error) {
+	columns, rows, err := query(node, scope)
+	if err != nil {
+		return nil, err
+	}
+	if columns != 1 {
+		return nil, ErrSubqueryColumns
+	}
+
+	values := []Value{}
+	for _, row := range rows {
+		values = append(values, row[0])
+	}
+	return values, nil
+}
+
+func evalSubquery(node *ast.SubqueryExpression, scope *Scope) (Value, error) {
+	values, err := subqueryColumn(node, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(values) {
+	case 0:
+		return &Null{}, nil
+	case 1:
+		return values[0], nil
+	}
+	return nil, ErrSubqueryRows
+}
+
+func evalExists(node *ast.ExistsExpression, scope *Scope) (Value, error) {
+	_, rows, err := query(node.Subquery, scope)
+	if err != nil {
+		return nil, err
+	}
+	return &Bool{Value: (len(rows) != 0) != node.Not}, nil
+}
@@ -0,0 +1,119 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+type ValueType string
+
+const (
+	INT_VALUE   ValueType = "INT"
+	FLOAT_VALUE ValueType = "FLOAT"
+	TEXT_VALUE  ValueType = "TEXT"
+	BOOL_VALUE  ValueType = "BOOL"
+	NULL_VALUE  ValueType = "NULL"
+)
+
+// Value is what expressions evaluate to. Values of one type are never
+// treated as another without going through a conversion below.
+type Value interface {
+	Type() ValueType
+	String() string
+}
+
+type Int struct {
+	Value int64
+}
+
+func (i *Int) Type() ValueType { return INT_VALUE }
+func (i *Int) String() string {
+	return fmt.Sprintf("%d", i.Value)
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ValueType { return FLOAT_VALUE }
+func (f *Float) String() string {
+	return fmt.Sprintf("%f", f.Value)
+}
+
+type Text struct {
+	Value string
+}
+
+func (t *Text) Type() ValueType { return TEXT_VALUE }
+func (t *Text) String() string {
+	return t.Value
+}
+
+type Bool struct {
+	Value bool
+}
+
+func (b *Bool) Type() ValueType { return BOOL_VALUE }
+func (b *Bool) String() string {
+	if b.Value {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// Null is the missing value. Operations on it have unknown results, which
+// are NULL too.
+type Null struct{}
+
+func (n *Null) Type() ValueType { return NULL_VALUE }
+func (n *Null) String() string {
+	return "NULL"
+}
+
+// ToFloat converts numbers to a Float.
+func ToFloat(v Value) (*Float, bool) {
+	switch v := v.(type) {
+	case *Float:
+		return v, true
+	case *Int:
+		return &Float{Value: float64(v.Value)}, true
+	}
+	return nil, false
+}
+
+// Key returns a string that is the same for values that are equal, and
+// different otherwise. Numbers are equal to each other whatever their type,
+// and NULLs are equal to each other, as they are when grouping rows.
+func Key(v Value) string {
+	switch v := v.(type) {
+	case *Int:
+		return "n" + strconv.FormatInt(v.Value, 10)
+	case *Float:
+		if v.Value == math.Trunc(v.Value) && math.Abs(v.Value) < 1<<63 {
+			return "n" + strconv.FormatInt(int64(v.Value), 10)
+		}
+		return "n" + strconv.FormatFloat(v.Value, 'g', -1, 64)
+	case *Text:
+		return "t" + v.Value
+	case *Bool:
+		return "b" + v.String()
+	}
+	return "null"
+}
+
+// Truthy reports whether v counts as true where a condition is expected.
+// Unknown results don't.
+func Truthy(v Value) bool {
+	switch v := v.(type) {
+	case *Bool:
+		return v.Value
+	case *Int:
+		return v.Value != 0
+	case *Float:
+		return v.Value != 0
+	case *Text:
+		return v.Value != ""
+	}
+	return false
+}
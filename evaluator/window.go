@@ -0,0 +1,46 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"jnafolayan/sql-db/ast"
+	"strings"
+)
+
+var ErrMisusedWindow = errors.New("misuse of window function")
+
+// windowFns are the functions that are only called with OVER. Aggregate
+// functions can be called with it as well.
+var windowFns = map[string]bool{
+	"ROW_NUMBER": true,
+	"RANK":       true,
+	"DENSE_RANK": true,
+	"LAG":        true,
+	"LEAD":       true,
+}
+
+// CheckWindowCall returns an error if call, a call with OVER, isn't a call
+// of a window function with the arguments it takes.
+func CheckWindowCall(call *ast.CallExpression) error {
+	switch strings.ToUpper(call.Name) {
+	case "ROW_NUMBER", "RANK", "DENSE_RANK":
+		if call.Star || len(call.Arguments) != 0 {
+			return fmt.Errorf("%w %s(): expected no arguments", ErrMisusedWindow, call.Name)
+		}
+		return nil
+	case "LAG", "LEAD":
+		if call.Star || call.Distinct || len(call.Arguments) == 0 || len(call.Arguments) > 3 {
+			return fmt.Errorf("%w %s(): expected 1 to 3 arguments", ErrMisusedWindow, call.Name)
+		}
+		return nil
+	}
+
+	_, err := NewAccumulator(call)
+	return err
+}
+
+// SetWindow sets the result of a window call for the row the scope is
+// bound to.
+func (s *Scope) SetWindow(call *ast.CallExpression, value Value) {
+	s.windows[call] = value
+}
@@ -23,6 +23,11 @@ func New(source string) *Lexer {
 	return l
 }
 
+// Source returns the text the lexer reads tokens from.
+func (l *Lexer) Source() string {
+	return l.source
+}
+
 func (l *Lexer) Cursor() *cursor {
 	return l.cursor
 }
@@ -82,6 +87,10 @@ func (l *Lexer) Tokenize() []*token.Token {
 			tokens = append(tokens, createToken(l.cursor, token.PLUS))
 		case '-':
 			tokens = append(tokens, createToken(l.cursor, token.MINUS))
+		case '/':
+			tokens = append(tokens, createToken(l.cursor, token.SLASH))
+		case '%':
+			tokens = append(tokens, createToken(l.cursor, token.PERCENT))
 		case '<':
 			tokens = append(tokens, createToken(l.cursor, token.LT))
 		case '>':
@@ -90,13 +99,17 @@ func (l *Lexer) Tokenize() []*token.Token {
 			tokens = append(tokens, createToken(l.cursor, token.EQ))
 		case '!':
 			if l.peekChar() == '=' {
-				tokens = append(tokens, createToken(l.cursor, token.N_EQ))
+				t := createToken(l.cursor, token.N_EQ)
+				t.Literal = "!="
+				tokens = append(tokens, t)
 				l.readChar()
 			}
 		case '*':
 			tokens = append(tokens, createToken(l.cursor, token.ASTERISK))
 		case ',':
 			tokens = append(tokens, createToken(l.cursor, token.COMMA))
+		case '.':
+			tokens = append(tokens, createToken(l.cursor, token.DOT))
 		case '(':
 			tokens = append(tokens, createToken(l.cursor, token.LPAREN))
 		case ')':
@@ -108,6 +121,7 @@ func (l *Lexer) Tokenize() []*token.Token {
 					Line: l.cursor.loc.Line,
 					Col:  l.cursor.loc.Col,
 				},
+				Position: l.cursor.position,
 			}
 
 			t.Literal = l.readString()
@@ -122,6 +136,7 @@ func (l *Lexer) Tokenize() []*token.Token {
 						Line: l.cursor.loc.Line,
 						Col:  l.cursor.loc.Col,
 					},
+					Position: l.cursor.position,
 				}
 
 				t.Literal = l.readIdentifier()
@@ -136,6 +151,7 @@ func (l *Lexer) Tokenize() []*token.Token {
 						Line: l.cursor.loc.Line,
 						Col:  l.cursor.loc.Col,
 					},
+					Position: l.cursor.position,
 				}
 
 				literal, tokenType := l.readNumber()
@@ -175,6 +191,7 @@ func createToken(cursor *cursor, tokenType token.TokenType) *token.Token {
 			Line: cursor.loc.Line,
 			Col:  cursor.loc.Col,
 		},
+		Position: cursor.position,
 	}
 }
 
@@ -6,7 +6,7 @@ import (
 )
 
 func TestLexer(t *testing.T) {
-	input := "SELECT *, name, age FROM table24 44 20.45 'colors' '' WHERE AND OR;"
+	input := "SELECT *, name, age, table24.* FROM table24 44 20.45 'colors' '' WHERE AND OR;"
 	expected := []struct {
 		tokenType token.TokenType
 		literal   string
@@ -17,6 +17,10 @@ func TestLexer(t *testing.T) {
 		{token.IDENTIFIER, "name"},
 		{token.COMMA, ","},
 		{token.IDENTIFIER, "age"},
+		{token.COMMA, ","},
+		{token.IDENTIFIER, "table24"},
+		{token.DOT, "."},
+		{token.ASTERISK, "*"},
 		{token.FROM, "FROM"},
 		{token.IDENTIFIER, "table24"},
 		{token.INT, "44"},
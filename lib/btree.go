@@ -0,0 +1,328 @@
+package lib
+
+import (
+	"bytes"
+	"sort"
+)
+
+type btreeItem[V interface{}] struct {
+	key   []byte
+	value V
+}
+
+type btreeNode[V interface{}] struct {
+	items    []btreeItem[V]
+	children []*btreeNode[V]
+}
+
+// BTree is an ordered map from byte string keys to values. Keys are
+// compared bytewise.
+type BTree[V interface{}] struct {
+	root   *btreeNode[V]
+	degree int
+	length int
+}
+
+// NewBTree creates a tree whose nodes hold between degree-1 and
+// 2*degree-1 items.
+func NewBTree[V interface{}](degree int) *BTree[V] {
+	if degree < 2 {
+		degree = 2
+	}
+	return &BTree[V]{degree: degree}
+}
+
+func (t *BTree[V]) Len() int {
+	return t.length
+}
+
+func (t *BTree[V]) Get(key []byte) (v V, ok bool) {
+	n := t.root
+	for n != nil {
+		i, found := n.find(key)
+		if found {
+			return n.items[i].value, true
+		}
+		if n.leaf() {
+			break
+		}
+		n = n.children[i]
+	}
+	return
+}
+
+// Set adds key to the tree or replaces its value if it's already there.
+func (t *BTree[V]) Set(key []byte, value V) {
+	if t.root == nil {
+		t.root = &btreeNode[V]{items: []btreeItem[V]{{key, value}}}
+		t.length++
+		return
+	}
+
+	if len(t.root.items) >= t.maxItems() {
+		old := t.root
+		t.root = &btreeNode[V]{children: []*btreeNode[V]{old}}
+		t.root.splitChild(0, t.degree)
+	}
+
+	if t.root.insert(btreeItem[V]{key, value}, t.maxItems(), t.degree) {
+		t.length++
+	}
+}
+
+// Delete removes key from the tree and reports whether it was there.
+func (t *BTree[V]) Delete(key []byte) bool {
+	if t.root == nil {
+		return false
+	}
+
+	removed := t.root.remove(key, t.degree)
+	if len(t.root.items) == 0 {
+		if t.root.leaf() {
+			t.root = nil
+		} else {
+			t.root = t.root.children[0]
+		}
+	}
+
+	if removed {
+		t.length--
+	}
+	return removed
+}
+
+// Ascend calls fn for every key greater than or equal to from, in order,
+// until fn returns false. A nil from starts at the smallest key.
+func (t *BTree[V]) Ascend(from []byte, fn func(key []byte, value V) bool) {
+	if t.root != nil {
+		t.root.ascend(from, fn)
+	}
+}
+
+// Descend calls fn for every key, from the largest down, until fn returns
+// false.
+func (t *BTree[V]) Descend(fn func(key []byte, value V) bool) {
+	if t.root != nil {
+		t.root.descend(fn)
+	}
+}
+
+func (t *BTree[V]) maxItems() int {
+	return 2*t.degree - 1
+}
+
+func (n *btreeNode[V]) leaf() bool {
+	return len(n.children) == 0
+}
+
+// find returns the position of the first item not less than key, and
+// whether that item's key is key.
+func (n *btreeNode[V]) find(key []byte) (int, bool) {
+	i := sort.Search(len(n.items), func(i int) bool {
+		return bytes.Compare(n.items[i].key, key) >= 0
+	})
+	return i, i < len(n.items) && bytes.Equal(n.items[i].key, key)
+}
+
+func (n *btreeNode[V]) insertItem(i int, item btreeItem[V]) {
+	n.items = append(n.items, btreeItem[V]{})
+	copy(n.items[i+1:], n.items[i:])
+	n.items[i] = item
+}
+
+func (n *btreeNode[V]) removeItem(i int) btreeItem[V] {
+	item := n.items[i]
+	copy(n.items[i:], n.items[i+1:])
+	n.items[len(n.items)-1] = btreeItem[V]{}
+	n.items = n.items[:len(n.items)-1]
+	return item
+}
+
+func (n *btreeNode[V]) insertChild(i int, child *btreeNode[V]) {
+	n.children = append(n.children, nil)
+	copy(n.children[i+1:], n.children[i:])
+	n.children[i] = child
+}
+
+func (n *btreeNode[V]) removeChild(i int) *btreeNode[V] {
+	child := n.children[i]
+	copy(n.children[i:], n.children[i+1:])
+	n.children[len(n.children)-1] = nil
+	n.children = n.children[:len(n.children)-1]
+	return child
+}
+
+// splitChild splits the full child at i in two around its middle item,
+// which moves up into n.
+func (n *btreeNode[V]) splitChild(i int, degree int) {
+	child := n.children[i]
+	mid := child.items[degree-1]
+
+	right := &btreeNode[V]{}
+	right.items = append(right.items, child.items[degree:]...)
+	child.items = child.items[:degree-1]
+	if !child.leaf() {
+		right.children = append(right.children, child.children[degree:]...)
+		child.children = child.children[:degree]
+	}
+
+	n.insertItem(i, mid)
+	n.insertChild(i+1, right)
+}
+
+func (n *btreeNode[V]) insert(item btreeItem[V], maxItems int, degree int) bool {
+	i, found := n.find(item.key)
+	if found {
+		n.items[i].value = item.value
+		return false
+	}
+
+	if n.leaf() {
+		n.insertItem(i, item)
+		return true
+	}
+
+	if len(n.children[i].items) >= maxItems {
+		n.splitChild(i, degree)
+		switch c := bytes.Compare(item.key, n.items[i].key); {
+		case c == 0:
+			n.items[i].value = item.value
+			return false
+		case c > 0:
+			i++
+		}
+	}
+
+	return n.children[i].insert(item, maxItems, degree)
+}
+
+// remove deletes key from the subtree rooted at n. Every node it descends
+// into is first given at least degree items so that removing one from it
+// can't leave it too small.
+func (n *btreeNode[V]) remove(key []byte, degree int) bool {
+	i, found := n.find(key)
+	if n.leaf() {
+		if found {
+			n.removeItem(i)
+		}
+		return found
+	}
+
+	if found {
+		switch {
+		case len(n.children[i].items) >= degree:
+			// Replace the item with its predecessor
+			pred := n.children[i].max()
+			n.items[i] = pred
+			return n.children[i].remove(pred.key, degree)
+		case len(n.children[i+1].items) >= degree:
+			// Replace the item with its successor
+			succ := n.children[i+1].min()
+			n.items[i] = succ
+			return n.children[i+1].remove(succ.key, degree)
+		default:
+			n.merge(i)
+			return n.children[i].remove(key, degree)
+		}
+	}
+
+	if len(n.children[i].items) < degree {
+		switch {
+		case i > 0 && len(n.children[i-1].items) >= degree:
+			n.borrowFromLeft(i)
+		case i < len(n.children)-1 && len(n.children[i+1].items) >= degree:
+			n.borrowFromRight(i)
+		case i < len(n.items):
+			n.merge(i)
+		default:
+			n.merge(i - 1)
+			i--
+		}
+	}
+
+	return n.children[i].remove(key, degree)
+}
+
+// merge joins the children on both sides of the item at i, along with the
+// item itself.
+func (n *btreeNode[V]) merge(i int) {
+	left := n.children[i]
+	right := n.removeChild(i + 1)
+
+	left.items = append(left.items, n.removeItem(i))
+	left.items = append(left.items, right.items...)
+	left.children = append(left.children, right.children...)
+}
+
+func (n *btreeNode[V]) borrowFromLeft(i int) {
+	child := n.children[i]
+	left := n.children[i-1]
+
+	child.insertItem(0, n.items[i-1])
+	n.items[i-1] = left.removeItem(len(left.items) - 1)
+	if !left.leaf() {
+		child.insertChild(0, left.removeChild(len(left.children)-1))
+	}
+}
+
+func (n *btreeNode[V]) borrowFromRight(i int) {
+	child := n.children[i]
+	right := n.children[i+1]
+
+	child.items = append(child.items, n.items[i])
+	n.items[i] = right.removeItem(0)
+	if !right.leaf() {
+		child.children = append(child.children, right.removeChild(0))
+	}
+}
+
+func (n *btreeNode[V]) min() btreeItem[V] {
+	for !n.leaf() {
+		n = n.children[0]
+	}
+	return n.items[0]
+}
+
+func (n *btreeNode[V]) max() btreeItem[V] {
+	for !n.leaf() {
+		n = n.children[len(n.children)-1]
+	}
+	return n.items[len(n.items)-1]
+}
+
+func (n *btreeNode[V]) ascend(from []byte, fn func([]byte, V) bool) bool {
+	i := 0
+	if from != nil {
+		i, _ = n.find(from)
+	}
+
+	for ; i < len(n.items); i++ {
+		if !n.leaf() && !n.children[i].ascend(from, fn) {
+			return false
+		}
+		if !fn(n.items[i].key, n.items[i].value) {
+			return false
+		}
+	}
+
+	if !n.leaf() {
+		return n.children[len(n.items)].ascend(from, fn)
+	}
+	return true
+}
+
+func (n *btreeNode[V]) descend(fn func([]byte, V) bool) bool {
+	for i := len(n.items) - 1; i >= 0; i-- {
+		if !n.leaf() && !n.children[i+1].descend(fn) {
+			return false
+		}
+		if !fn(n.items[i].key, n.items[i].value) {
+			return false
+		}
+	}
+
+	if !n.leaf() {
+		return n.children[0].descend(fn)
+	}
+	return true
+}
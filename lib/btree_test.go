@@ -0,0 +1,110 @@
+package lib
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBTree(t *testing.T) {
+	for _, degree := range []int{2, 3, 16} {
+		t.Run(fmt.Sprintf("degree_%d", degree), func(sub *testing.T) {
+			tree := NewBTree[int](degree)
+			expected := map[string]int{}
+			r := rand.New(rand.NewSource(int64(degree)))
+
+			for i := 0; i < 5000; i++ {
+				key := fmt.Sprintf("key%04d", r.Intn(1000))
+				if r.Intn(3) == 0 {
+					_, ok := expected[key]
+					if tree.Delete([]byte(key)) != ok {
+						sub.Fatalf("expected Delete(%q) to return %v", key, ok)
+					}
+					delete(expected, key)
+				} else {
+					tree.Set([]byte(key), i)
+					expected[key] = i
+				}
+			}
+
+			if tree.Len() != len(expected) {
+				sub.Fatalf("expected %d keys, got %d", len(expected), tree.Len())
+			}
+
+			keys := []string{}
+			for k, v := range expected {
+				keys = append(keys, k)
+				if got, ok := tree.Get([]byte(k)); !ok || got != v {
+					sub.Errorf("expected Get(%q) to return %d, got %d", k, v, got)
+				}
+			}
+			sort.Strings(keys)
+
+			visited := []string{}
+			tree.Ascend(nil, func(key []byte, value int) bool {
+				visited = append(visited, string(key))
+				return true
+			})
+			if fmt.Sprint(visited) != fmt.Sprint(keys) {
+				sub.Fatalf("expected keys to be visited in order")
+			}
+
+			// Ascend from a key that may not be in the tree
+			from := []byte("key0500")
+			visited = visited[:0]
+			tree.Ascend(from, func(key []byte, value int) bool {
+				visited = append(visited, string(key))
+				return len(visited) < 10
+			})
+			start := sort.SearchStrings(keys, string(from))
+			if fmt.Sprint(visited) != fmt.Sprint(keys[start:start+10]) {
+				sub.Fatalf("expected %v, got %v", keys[start:start+10], visited)
+			}
+
+			// Descend visits the keys in reverse, and stops when asked to
+			visited = visited[:0]
+			tree.Descend(func(key []byte, value int) bool {
+				visited = append(visited, string(key))
+				return true
+			})
+			for i, k := range visited {
+				if k != keys[len(keys)-1-i] {
+					sub.Fatalf("expected keys to be visited in reverse order")
+				}
+			}
+			visited = visited[:0]
+			tree.Descend(func(key []byte, value int) bool {
+				visited = append(visited, string(key))
+				return len(visited) < 10
+			})
+			if len(visited) != 10 || visited[9] != keys[len(keys)-10] {
+				sub.Fatalf("expected to stop after 10 keys, got %v", visited)
+			}
+
+			for _, k := range keys {
+				tree.Delete([]byte(k))
+			}
+			if tree.Len() != 0 {
+				sub.Fatalf("expected an empty tree, got %d keys", tree.Len())
+			}
+			tree.Ascend(nil, func(key []byte, value int) bool {
+				sub.Fatalf("unexpected key %q", key)
+				return false
+			})
+		})
+	}
+}
+
+func TestBTreeGetMissing(t *testing.T) {
+	tree := NewBTree[int](2)
+	if _, ok := tree.Get([]byte("a")); ok {
+		t.Fatalf("expected key to be missing")
+	}
+
+	tree.Set([]byte("b"), 1)
+	tree.Set([]byte("b"), 2)
+	if v, _ := tree.Get([]byte("b")); v != 2 || tree.Len() != 1 {
+		t.Fatalf("expected the value to be replaced")
+	}
+}
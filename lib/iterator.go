@@ -19,8 +19,14 @@ func (i *Iterator[T]) Next() (v T) {
 }
 
 func (i *Iterator[T]) Peek() (v T) {
-	if i.cursor < len(i.list)-1 {
-		v = i.list[i.cursor+1]
+	return i.PeekAt(1)
+}
+
+// PeekAt returns the item offset places after the current one, without
+// moving to it.
+func (i *Iterator[T]) PeekAt(offset int) (v T) {
+	if i.cursor+offset < len(i.list) {
+		v = i.list[i.cursor+offset]
 	}
 
 	return
@@ -12,3 +12,4 @@ func expectedTokenError(expected token.TokenType) error {
 
 var ErrEmptyColumnsList = errors.New("must specify a column name")
 var ErrEmptyColumnDefinitions = errors.New("must specify column definitions")
+var ErrCompoundColumns = errors.New("each UNION, INTERSECT or EXCEPT query must have the same number of columns")
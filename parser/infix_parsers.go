@@ -1,6 +1,11 @@
 package parser
 
-import "jnafolayan/sql-db/ast"
+import (
+	"errors"
+	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/token"
+	"strings"
+)
 
 type infixParseFn func(*Parser, ast.Expression) (ast.Expression, error)
 
@@ -11,9 +16,10 @@ func parseInfixExpression(p *Parser, left ast.Expression) (ast.Expression, error
 		Operator: p.curToken.Literal,
 	}
 
+	// Operators of the same precedence are grouped from the left
 	op := p.getCurTokenPrecedence()
 	p.nextToken()
-	right, err := p.parseExpression(op - 1)
+	right, err := p.parseExpression(op)
 	if err != nil {
 		return nil, err
 	}
@@ -22,3 +28,191 @@ func parseInfixExpression(p *Parser, left ast.Expression) (ast.Expression, error
 
 	return infixExpr, nil
 }
+
+func parseInExpression(p *Parser, left ast.Expression) (ast.Expression, error) {
+	inExpr := &ast.InExpression{
+		Token: p.curToken,
+		Left:  left,
+	}
+
+	if !p.expectPeekToken(token.LPAREN) {
+		return nil, expectedTokenError(token.LPAREN)
+	}
+
+	if p.checkPeekQuery() {
+		sub, err := p.parseSubquery()
+		if err != nil {
+			return nil, err
+		}
+		inExpr.Subquery = sub
+		return inExpr, nil
+	}
+
+	for {
+		p.nextToken()
+		if p.checkCurToken(token.EOF) {
+			return nil, expectedTokenError(token.RPAREN)
+		}
+
+		value, err := p.parseExpression(LOWEST)
+		if err != nil {
+			return nil, err
+		}
+		inExpr.Values = append(inExpr.Values, value)
+
+		if !p.expectPeekToken(token.COMMA) {
+			break
+		}
+	}
+
+	if !p.expectPeekToken(token.RPAREN) {
+		return nil, expectedTokenError(token.RPAREN)
+	}
+
+	return inExpr, nil
+}
+
+func parseIsExpression(p *Parser, left ast.Expression) (ast.Expression, error) {
+	isExpr := &ast.IsExpression{
+		Token: p.curToken,
+		Left:  left,
+	}
+
+	if p.expectPeekToken(token.NOT) {
+		isExpr.Not = true
+	}
+
+	if !p.expectPeekToken(token.NULL) {
+		return nil, expectedTokenError(token.NULL)
+	}
+
+	return isExpr, nil
+}
+
+func parsePatternExpression(p *Parser, left ast.Expression) (ast.Expression, error) {
+	patternExpr := &ast.PatternExpression{
+		Token:    p.curToken,
+		Left:     left,
+		Operator: strings.ToUpper(p.curToken.Literal),
+	}
+
+	p.nextToken()
+	pattern, err := p.parseExpression(EQUALS)
+	if err != nil {
+		return nil, err
+	}
+	patternExpr.Pattern = pattern
+
+	if patternExpr.Operator == "LIKE" && p.expectPeekToken(token.ESCAPE) {
+		p.nextToken()
+		escape, err := p.parseExpression(EQUALS)
+		if err != nil {
+			return nil, err
+		}
+		patternExpr.Escape = escape
+	}
+
+	return patternExpr, nil
+}
+
+// parseBetweenExpression parses the bounds of BETWEEN. Each bound binds
+// tighter than AND, so the AND between them is never taken for the logical
+// operator.
+func parseBetweenExpression(p *Parser, left ast.Expression) (ast.Expression, error) {
+	betweenExpr := &ast.BetweenExpression{
+		Token: p.curToken,
+		Left:  left,
+	}
+
+	p.nextToken()
+	low, err := p.parseExpression(EQUALS)
+	if err != nil {
+		return nil, err
+	}
+	betweenExpr.Low = low
+
+	if !p.expectPeekToken(token.AND) {
+		return nil, expectedTokenError(token.AND)
+	}
+
+	p.nextToken()
+	high, err := p.parseExpression(EQUALS)
+	if err != nil {
+		return nil, err
+	}
+	betweenExpr.High = high
+
+	return betweenExpr, nil
+}
+
+// parseNotExpression parses the negated forms of operators, such as
+// NOT LIKE.
+func parseNotExpression(p *Parser, left ast.Expression) (ast.Expression, error) {
+	switch {
+	case p.expectPeekToken(token.IN):
+		expr, err := parseInExpression(p, left)
+		if err != nil {
+			return nil, err
+		}
+		expr.(*ast.InExpression).Not = true
+		return expr, nil
+	case p.expectPeekToken(token.BETWEEN):
+		expr, err := parseBetweenExpression(p, left)
+		if err != nil {
+			return nil, err
+		}
+		expr.(*ast.BetweenExpression).Not = true
+		return expr, nil
+	case p.expectPeekToken(token.LIKE), p.expectPeekToken(token.GLOB), p.expectPeekToken(token.REGEXP):
+		expr, err := parsePatternExpression(p, left)
+		if err != nil {
+			return nil, err
+		}
+		expr.(*ast.PatternExpression).Not = true
+		return expr, nil
+	}
+
+	return nil, errors.New("expected IN, BETWEEN, LIKE, GLOB or REGEXP")
+}
+
+func parseCallExpression(p *Parser, left ast.Expression) (ast.Expression, error) {
+	fn, ok := left.(*ast.Identifier)
+	if !ok || fn.Table != nil {
+		return nil, errors.New("expected function name")
+	}
+
+	call := &ast.CallExpression{Token: fn.Token, Name: fn.Value}
+	if p.expectPeekToken(token.ASTERISK) {
+		// COUNT(*) counts rows rather than values
+		call.Star = true
+	} else if !p.checkPeekToken(token.RPAREN) {
+		call.Distinct = p.expectPeekToken(token.DISTINCT)
+
+		for {
+			p.nextToken()
+			arg, err := p.parseExpression(LOWEST)
+			if err != nil {
+				return nil, err
+			}
+			call.Arguments = append(call.Arguments, arg)
+
+			if !p.expectPeekToken(token.COMMA) {
+				break
+			}
+		}
+	}
+
+	if !p.expectPeekToken(token.RPAREN) {
+		return nil, expectedTokenError(token.RPAREN)
+	}
+
+	if p.expectPeekToken(token.OVER) {
+		over, err := p.parseWindowDefinition()
+		if err != nil {
+			return nil, err
+		}
+		call.Over = over
+	}
+
+	return call, nil
+}
@@ -4,10 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"jnafolayan/sql-db/ast"
-	"jnafolayan/sql-db/evaluator"
 	"jnafolayan/sql-db/lexer"
 	"jnafolayan/sql-db/lib"
 	"jnafolayan/sql-db/token"
+	"strings"
 )
 
 type OperatorPrecedence int
@@ -15,8 +15,9 @@ type OperatorPrecedence int
 const (
 	LOWEST OperatorPrecedence = iota
 	ASSIGN
-	AND
 	OR
+	AND
+	NOT
 	EQUALS
 	LT_GT
 	SUM
@@ -35,6 +36,21 @@ var precedences = map[token.TokenType]OperatorPrecedence{
 	token.MINUS: SUM,
 	token.AND:   AND,
 	token.OR:    OR,
+	token.IN:    EQUALS,
+	token.IS:    EQUALS,
+
+	token.ASTERISK: PRODUCT,
+	token.SLASH:    PRODUCT,
+	token.PERCENT:  PRODUCT,
+
+	token.LIKE:   EQUALS,
+	token.GLOB:   EQUALS,
+	token.REGEXP: EQUALS,
+	token.NOT:    EQUALS,
+
+	token.BETWEEN: EQUALS,
+
+	token.LPAREN: CALL,
 }
 
 func getTokenPrecedence(tokenType token.TokenType) OperatorPrecedence {
@@ -69,15 +85,32 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefixFn(token.FLOAT, parseFloatLiteral)
 	p.registerPrefixFn(token.STRING, parseStringLiteral)
 	p.registerPrefixFn(token.IDENTIFIER, parseIdentifier)
+	p.registerPrefixFn(token.NULL, parseNullLiteral)
+	p.registerPrefixFn(token.MINUS, parsePrefixExpression)
+	p.registerPrefixFn(token.LPAREN, parseGroupedExpression)
+	p.registerPrefixFn(token.CASE, parseCaseExpression)
+	p.registerPrefixFn(token.EXISTS, parseExistsExpression)
+	p.registerPrefixFn(token.NOT, parseNotPrefixExpression)
 
 	p.registerInfixFn(token.PLUS, parseInfixExpression)
 	p.registerInfixFn(token.MINUS, parseInfixExpression)
+	p.registerInfixFn(token.ASTERISK, parseInfixExpression)
+	p.registerInfixFn(token.SLASH, parseInfixExpression)
+	p.registerInfixFn(token.PERCENT, parseInfixExpression)
 	p.registerInfixFn(token.EQ, parseInfixExpression)
 	p.registerInfixFn(token.N_EQ, parseInfixExpression)
 	p.registerInfixFn(token.LT, parseInfixExpression)
 	p.registerInfixFn(token.GT, parseInfixExpression)
 	p.registerInfixFn(token.AND, parseInfixExpression)
 	p.registerInfixFn(token.OR, parseInfixExpression)
+	p.registerInfixFn(token.IN, parseInExpression)
+	p.registerInfixFn(token.IS, parseIsExpression)
+	p.registerInfixFn(token.LIKE, parsePatternExpression)
+	p.registerInfixFn(token.GLOB, parsePatternExpression)
+	p.registerInfixFn(token.REGEXP, parsePatternExpression)
+	p.registerInfixFn(token.NOT, parseNotExpression)
+	p.registerInfixFn(token.BETWEEN, parseBetweenExpression)
+	p.registerInfixFn(token.LPAREN, parseCallExpression)
 
 	return p
 }
@@ -170,32 +203,171 @@ func (p *Parser) Parse() (*ast.Program, error) {
 
 func (p *Parser) parseStatement() (ast.Statement, error) {
 	switch p.curToken.Type {
-	case token.SELECT:
-		return p.parseSelectStatement()
+	case token.SELECT, token.WITH:
+		return p.parseQuery()
 	case token.CREATE:
+		if p.checkPeekToken(token.INDEX) || p.checkPeekToken(token.UNIQUE) {
+			return p.parseCreateIndexStatement()
+		}
 		return p.parseCreateTableStatement()
+	case token.DROP:
+		return p.parseDropIndexStatement()
 	case token.INSERT:
 		return p.parseInsertStatement()
 	case token.DELETE:
 		return p.parseDeleteStatement()
 	case token.UPDATE:
 		return p.parseUpdateStatement()
+	case token.BEGIN, token.COMMIT, token.ROLLBACK:
+		return p.parseTransactionStatement()
 	default:
 		return nil, fmt.Errorf("invalid keyword %q", p.curToken.Literal)
 	}
 }
 
 func (p *Parser) parseSelectStatement() (ast.Statement, error) {
+	stmt, err := p.parseSelectCore()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		term := &ast.CompoundSelect{}
+		switch {
+		case p.expectPeekToken(token.UNION):
+			term.Operator = ast.UNION
+		case p.expectPeekToken(token.INTERSECT):
+			term.Operator = ast.INTERSECT
+		case p.expectPeekToken(token.EXCEPT):
+			term.Operator = ast.EXCEPT
+		}
+		if term.Operator == "" {
+			break
+		}
+
+		term.All = p.expectPeekToken(token.ALL)
+		if !p.expectPeekToken(token.SELECT) {
+			return nil, expectedTokenError(token.SELECT)
+		}
+
+		core, err := p.parseSelectCore()
+		if err != nil {
+			return nil, err
+		}
+		term.Select = core
+
+		// The columns of a star are only known to the engine
+		count, known := selectColumnCount(stmt)
+		termCount, termKnown := selectColumnCount(core)
+		if known && termKnown && count != termCount {
+			return nil, ErrCompoundColumns
+		}
+
+		stmt.Compound = append(stmt.Compound, term)
+	}
+
+	if p.expectPeekToken(token.ORDER) {
+		if !p.expectPeekToken(token.BY) {
+			return nil, expectedTokenError(token.BY)
+		}
+
+		for {
+			p.nextToken()
+			term, err := p.parseOrderingTerm()
+			if err != nil {
+				return nil, err
+			}
+			stmt.OrderBy = append(stmt.OrderBy, term)
+
+			if !p.expectPeekToken(token.COMMA) {
+				break
+			}
+		}
+	}
+
+	if p.expectPeekToken(token.LIMIT) {
+		p.nextToken()
+		expr, err := p.parseExpression(LOWEST)
+		if err != nil {
+			return nil, err
+		}
+		stmt.Limit = expr
+
+		if p.expectPeekToken(token.OFFSET) {
+			p.nextToken()
+			expr, err := p.parseExpression(LOWEST)
+			if err != nil {
+				return nil, err
+			}
+			stmt.Offset = expr
+		}
+	}
+
+	if p.checkPeekToken(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt, nil
+}
+
+// selectColumnCount returns the number of columns stmt selects, unless it
+// selects a star.
+func selectColumnCount(stmt *ast.SelectStatement) (int, bool) {
+	for _, col := range stmt.Columns {
+		if col.Star {
+			return 0, false
+		}
+	}
+	return len(stmt.Columns), true
+}
+
+// parseSelectCore parses a SELECT up to its HAVING clause, which is what
+// the queries of a compound SELECT are made of, leaving curToken on its
+// last token.
+func (p *Parser) parseSelectCore() (*ast.SelectStatement, error) {
 	stmt := &ast.SelectStatement{}
-	stmt.Columns = []*token.Token{}
+	stmt.Columns = []*ast.SelectColumn{}
+
+	if p.expectPeekToken(token.DISTINCT) {
+		stmt.Distinct = true
+
+		if p.expectPeekToken(token.ON) {
+			if !p.expectPeekToken(token.LPAREN) {
+				return nil, expectedTokenError(token.LPAREN)
+			}
+
+			for {
+				p.nextToken()
+				expr, err := p.parseExpression(LOWEST)
+				if err != nil {
+					return nil, err
+				}
+				stmt.DistinctOn = append(stmt.DistinctOn, expr)
+
+				if !p.expectPeekToken(token.COMMA) {
+					break
+				}
+			}
+
+			if !p.expectPeekToken(token.RPAREN) {
+				return nil, expectedTokenError(token.RPAREN)
+			}
+		}
+	}
 
 	p.nextToken()
-	for p.checkCurToken(token.IDENTIFIER) || p.checkCurToken(token.ASTERISK) {
-		stmt.Columns = append(stmt.Columns, p.curToken)
+	for p.curToken != nil && !p.checkCurToken(token.FROM) && !p.checkCurToken(token.COMMA) {
+		col, err := p.parseSelectColumn()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Columns = append(stmt.Columns, col)
+
 		p.nextToken()
-		if p.checkCurToken(token.COMMA) {
-			p.nextToken()
+		if !p.checkCurToken(token.COMMA) {
+			break
 		}
+		p.nextToken()
 	}
 
 	if len(stmt.Columns) == 0 {
@@ -207,11 +379,12 @@ func (p *Parser) parseSelectStatement() (ast.Statement, error) {
 	}
 
 	p.nextToken()
-	if !p.checkCurToken(token.IDENTIFIER) {
-		return nil, errors.New("expected table name")
+	from, err := p.parseTableExpression()
+	if err != nil {
+		return nil, err
 	}
 
-	stmt.Table = p.curToken
+	stmt.From = from
 
 	if p.checkPeekToken(token.WHERE) {
 		// move to where
@@ -230,13 +403,482 @@ func (p *Parser) parseSelectStatement() (ast.Statement, error) {
 		stmt.Predicate = expr
 	}
 
-	if p.checkPeekToken(token.SEMICOLON) {
+	if p.expectPeekToken(token.GROUP) {
+		if !p.expectPeekToken(token.BY) {
+			return nil, expectedTokenError(token.BY)
+		}
+
+		for {
+			p.nextToken()
+			expr, err := p.parseExpression(LOWEST)
+			if err != nil {
+				return nil, err
+			}
+			stmt.GroupBy = append(stmt.GroupBy, expr)
+
+			if !p.expectPeekToken(token.COMMA) {
+				break
+			}
+		}
+	}
+
+	if p.expectPeekToken(token.HAVING) {
 		p.nextToken()
+		expr, err := p.parseExpression(LOWEST)
+		if err != nil {
+			return nil, err
+		}
+
+		stmt.Having = expr
 	}
 
 	return stmt, nil
 }
 
+// parseSelectColumn parses an entry of a SELECT list, leaving curToken on
+// its last token.
+func (p *Parser) parseSelectColumn() (*ast.SelectColumn, error) {
+	if p.checkCurToken(token.ASTERISK) {
+		return &ast.SelectColumn{Star: true}, nil
+	}
+
+	// table.* selects every column of the table. Other qualified names
+	// are parsed as expressions.
+	if p.checkCurToken(token.IDENTIFIER) && p.checkPeekToken(token.DOT) && p.checkTokenIs(p.it.PeekAt(2), token.ASTERISK) {
+		table := p.curToken
+		p.nextToken()
+		p.nextToken()
+		return &ast.SelectColumn{Star: true, Table: table}, nil
+	}
+
+	expr, err := p.parseExpression(LOWEST)
+	if err != nil {
+		return nil, err
+	}
+
+	col := &ast.SelectColumn{Expression: expr}
+
+	// The AS keyword is optional
+	if p.expectPeekToken(token.AS) {
+		if !p.expectPeekToken(token.IDENTIFIER) {
+			return nil, errors.New("expected column alias")
+		}
+		col.Alias = p.curToken
+	} else if p.expectPeekToken(token.IDENTIFIER) {
+		col.Alias = p.curToken
+	}
+
+	return col, nil
+}
+
+// checkPeekQuery reports whether peekToken starts a query.
+func (p *Parser) checkPeekQuery() bool {
+	return p.checkPeekToken(token.SELECT) || p.checkPeekToken(token.WITH)
+}
+
+// parseQuery parses a SELECT, which can start with a WITH clause.
+func (p *Parser) parseQuery() (*ast.SelectStatement, error) {
+	var with *ast.WithClause
+	if p.checkCurToken(token.WITH) {
+		clause, err := p.parseWithClause()
+		if err != nil {
+			return nil, err
+		}
+		with = clause
+
+		if !p.expectPeekToken(token.SELECT) {
+			return nil, expectedTokenError(token.SELECT)
+		}
+	}
+
+	stmt, err := p.parseSelectStatement()
+	if err != nil {
+		return nil, err
+	}
+
+	query := stmt.(*ast.SelectStatement)
+	query.With = with
+	return query, nil
+}
+
+// parseWithClause parses a WITH clause, leaving curToken on the closing
+// parenthesis of its last table.
+func (p *Parser) parseWithClause() (*ast.WithClause, error) {
+	with := &ast.WithClause{}
+	with.Recursive = p.expectPeekToken(token.RECURSIVE)
+
+	for {
+		if !p.expectPeekToken(token.IDENTIFIER) {
+			return nil, errors.New("expected table name")
+		}
+		cte := &ast.CommonTableExpression{Name: p.curToken}
+
+		if p.expectPeekToken(token.LPAREN) {
+			columns, err := p.parseColumnList()
+			if err != nil {
+				return nil, err
+			}
+			cte.Columns = columns
+		}
+
+		if !p.expectPeekToken(token.AS) {
+			return nil, expectedTokenError(token.AS)
+		}
+		if !p.expectPeekToken(token.LPAREN) {
+			return nil, expectedTokenError(token.LPAREN)
+		}
+		if !p.checkPeekQuery() {
+			return nil, expectedTokenError(token.SELECT)
+		}
+
+		p.nextToken()
+		query, err := p.parseQuery()
+		if err != nil {
+			return nil, err
+		}
+		cte.Select = query
+
+		if !p.expectPeekToken(token.RPAREN) {
+			return nil, expectedTokenError(token.RPAREN)
+		}
+		with.Tables = append(with.Tables, cte)
+
+		if !p.expectPeekToken(token.COMMA) {
+			break
+		}
+	}
+
+	return with, nil
+}
+
+// parseSubquery parses a query in parentheses, from curToken on the
+// opening one to the closing one.
+func (p *Parser) parseSubquery() (*ast.SubqueryExpression, error) {
+	sub := &ast.SubqueryExpression{Token: p.curToken}
+
+	p.nextToken()
+	query, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	sub.Select = query
+
+	if !p.expectPeekToken(token.RPAREN) {
+		return nil, expectedTokenError(token.RPAREN)
+	}
+
+	return sub, nil
+}
+
+// parseOrderingTerm parses a term of ORDER BY, leaving curToken on its
+// last token.
+func (p *Parser) parseOrderingTerm() (*ast.OrderingTerm, error) {
+	expr, err := p.parseExpression(LOWEST)
+	if err != nil {
+		return nil, err
+	}
+
+	term := &ast.OrderingTerm{Expression: expr}
+	if p.expectPeekToken(token.DESC) {
+		term.Descending = true
+	} else {
+		p.expectPeekToken(token.ASC)
+	}
+
+	if p.expectPeekToken(token.NULLS) {
+		// FIRST and LAST aren't keywords, so they can still name columns
+		if !p.expectPeekToken(token.IDENTIFIER) {
+			return nil, errors.New("expected FIRST or LAST")
+		}
+
+		switch nulls := ast.NullsOrder(strings.ToUpper(p.curToken.Literal)); nulls {
+		case ast.NULLS_FIRST, ast.NULLS_LAST:
+			term.Nulls = nulls
+		default:
+			return nil, errors.New("expected FIRST or LAST")
+		}
+	}
+
+	return term, nil
+}
+
+// checkPeekWord reports whether peekToken is the identifier word, in any
+// case. The words of window definitions aren't keywords, so they can
+// still name columns.
+func (p *Parser) checkPeekWord(word string) bool {
+	return p.checkPeekToken(token.IDENTIFIER) && strings.ToUpper(p.peekToken.Literal) == word
+}
+
+func (p *Parser) expectPeekWord(word string) bool {
+	if p.checkPeekWord(word) {
+		p.nextToken()
+		return true
+	}
+	return false
+}
+
+// parseWindowDefinition parses the definition after OVER, leaving curToken
+// on its closing parenthesis.
+func (p *Parser) parseWindowDefinition() (*ast.WindowDefinition, error) {
+	if !p.expectPeekToken(token.LPAREN) {
+		return nil, expectedTokenError(token.LPAREN)
+	}
+
+	window := &ast.WindowDefinition{}
+	if p.expectPeekWord("PARTITION") {
+		if !p.expectPeekToken(token.BY) {
+			return nil, expectedTokenError(token.BY)
+		}
+
+		for {
+			p.nextToken()
+			expr, err := p.parseExpression(LOWEST)
+			if err != nil {
+				return nil, err
+			}
+			window.PartitionBy = append(window.PartitionBy, expr)
+
+			if !p.expectPeekToken(token.COMMA) {
+				break
+			}
+		}
+	}
+
+	if p.expectPeekToken(token.ORDER) {
+		if !p.expectPeekToken(token.BY) {
+			return nil, expectedTokenError(token.BY)
+		}
+
+		for {
+			p.nextToken()
+			term, err := p.parseOrderingTerm()
+			if err != nil {
+				return nil, err
+			}
+			window.OrderBy = append(window.OrderBy, term)
+
+			if !p.expectPeekToken(token.COMMA) {
+				break
+			}
+		}
+	}
+
+	if p.checkPeekWord("ROWS") || p.checkPeekWord("RANGE") {
+		p.nextToken()
+		frame, err := p.parseWindowFrame()
+		if err != nil {
+			return nil, err
+		}
+		window.Frame = frame
+	}
+
+	if !p.expectPeekToken(token.RPAREN) {
+		return nil, expectedTokenError(token.RPAREN)
+	}
+
+	return window, nil
+}
+
+// parseWindowFrame parses a frame clause, from curToken on ROWS or RANGE
+// to its last token. A frame given by its start alone ends at the current
+// row.
+func (p *Parser) parseWindowFrame() (*ast.WindowFrame, error) {
+	frame := &ast.WindowFrame{Unit: ast.FrameUnit(strings.ToUpper(p.curToken.Literal))}
+
+	between := p.expectPeekToken(token.BETWEEN)
+	p.nextToken()
+	start, err := p.parseFrameBound()
+	if err != nil {
+		return nil, err
+	}
+	frame.Start = start
+	frame.End = &ast.FrameBound{Kind: ast.CURRENT_ROW}
+
+	if between {
+		if !p.expectPeekToken(token.AND) {
+			return nil, expectedTokenError(token.AND)
+		}
+
+		p.nextToken()
+		end, err := p.parseFrameBound()
+		if err != nil {
+			return nil, err
+		}
+		frame.End = end
+	}
+
+	// Bounds are listed in the order they can be in
+	order := map[ast.FrameBoundKind]int{
+		ast.UNBOUNDED_PRECEDING: 0,
+		ast.OFFSET_PRECEDING:    1,
+		ast.CURRENT_ROW:         2,
+		ast.OFFSET_FOLLOWING:    3,
+		ast.UNBOUNDED_FOLLOWING: 4,
+	}
+	switch {
+	case frame.Start.Kind == ast.UNBOUNDED_FOLLOWING:
+		return nil, errors.New("frame can't start at UNBOUNDED FOLLOWING")
+	case frame.End.Kind == ast.UNBOUNDED_PRECEDING:
+		return nil, errors.New("frame can't end at UNBOUNDED PRECEDING")
+	case order[frame.Start.Kind] > order[frame.End.Kind]:
+		return nil, errors.New("frame can't start after it ends")
+	}
+
+	if frame.Unit == ast.RANGE_FRAME && (frame.Start.Offset != nil || frame.End.Offset != nil) {
+		return nil, errors.New("RANGE frames can only be bound by UNBOUNDED or CURRENT ROW")
+	}
+
+	return frame, nil
+}
+
+// parseFrameBound parses a frame bound, from curToken on its first token
+// to its last.
+func (p *Parser) parseFrameBound() (*ast.FrameBound, error) {
+	if p.checkCurToken(token.IDENTIFIER) {
+		switch strings.ToUpper(p.curToken.Literal) {
+		case "UNBOUNDED":
+			if p.expectPeekWord("PRECEDING") {
+				return &ast.FrameBound{Kind: ast.UNBOUNDED_PRECEDING}, nil
+			}
+			if p.expectPeekWord("FOLLOWING") {
+				return &ast.FrameBound{Kind: ast.UNBOUNDED_FOLLOWING}, nil
+			}
+			return nil, errors.New("expected PRECEDING or FOLLOWING")
+		case "CURRENT":
+			if !p.expectPeekWord("ROW") {
+				return nil, errors.New("expected ROW")
+			}
+			return &ast.FrameBound{Kind: ast.CURRENT_ROW}, nil
+		}
+	}
+
+	offset, err := p.parseExpression(LOWEST)
+	if err != nil {
+		return nil, err
+	}
+
+	bound := &ast.FrameBound{Offset: offset}
+	switch {
+	case p.expectPeekWord("PRECEDING"):
+		bound.Kind = ast.OFFSET_PRECEDING
+	case p.expectPeekWord("FOLLOWING"):
+		bound.Kind = ast.OFFSET_FOLLOWING
+	default:
+		return nil, errors.New("expected PRECEDING or FOLLOWING")
+	}
+	return bound, nil
+}
+
+// parseTableExpression parses the tables of a FROM clause and the joins
+// between them, leaving curToken on the last token. Joins are grouped from
+// the left.
+func (p *Parser) parseTableExpression() (ast.TableExpression, error) {
+	table, err := p.parseTableName()
+	if err != nil {
+		return nil, err
+	}
+
+	var left ast.TableExpression = table
+
+	for {
+		tok := p.peekToken
+		kind, ok, err := p.parseJoinKind()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return left, nil
+		}
+
+		p.nextToken()
+		right, err := p.parseTableName()
+		if err != nil {
+			return nil, err
+		}
+
+		join := &ast.JoinExpression{Token: tok, Kind: kind, Left: left, Right: right}
+		if kind != ast.CROSS_JOIN {
+			switch {
+			case p.expectPeekToken(token.ON):
+				p.nextToken()
+				on, err := p.parseExpression(LOWEST)
+				if err != nil {
+					return nil, err
+				}
+				join.On = on
+			case p.expectPeekToken(token.USING):
+				if !p.expectPeekToken(token.LPAREN) {
+					return nil, expectedTokenError(token.LPAREN)
+				}
+				using, err := p.parseColumnList()
+				if err != nil {
+					return nil, err
+				}
+				join.Using = using
+			default:
+				return nil, errors.New("expected ON or USING")
+			}
+		}
+
+		left = join
+	}
+}
+
+// parseJoinKind moves past the keywords that start a join, if the peek
+// token starts one. A comma between tables is a cross join.
+func (p *Parser) parseJoinKind() (ast.JoinKind, bool, error) {
+	var kind ast.JoinKind
+	switch {
+	case p.expectPeekToken(token.COMMA):
+		return ast.CROSS_JOIN, true, nil
+	case p.checkPeekToken(token.JOIN):
+		kind = ast.INNER_JOIN
+	case p.expectPeekToken(token.INNER):
+		kind = ast.INNER_JOIN
+	case p.expectPeekToken(token.CROSS):
+		kind = ast.CROSS_JOIN
+	case p.expectPeekToken(token.LEFT):
+		kind = ast.LEFT_JOIN
+	case p.expectPeekToken(token.RIGHT):
+		kind = ast.RIGHT_JOIN
+	case p.expectPeekToken(token.FULL):
+		kind = ast.FULL_JOIN
+	default:
+		return "", false, nil
+	}
+
+	if kind == ast.LEFT_JOIN || kind == ast.RIGHT_JOIN || kind == ast.FULL_JOIN {
+		p.expectPeekToken(token.OUTER)
+	}
+
+	if !p.expectPeekToken(token.JOIN) {
+		return "", false, expectedTokenError(token.JOIN)
+	}
+	return kind, true, nil
+}
+
+// parseTableName parses a table name and its alias, leaving curToken on
+// the last of them.
+func (p *Parser) parseTableName() (*ast.TableName, error) {
+	if !p.checkCurToken(token.IDENTIFIER) {
+		return nil, errors.New("expected table name")
+	}
+
+	table := &ast.TableName{Name: p.curToken}
+
+	// The AS keyword is optional
+	if p.expectPeekToken(token.AS) {
+		if !p.expectPeekToken(token.IDENTIFIER) {
+			return nil, errors.New("expected table alias")
+		}
+		table.Alias = p.curToken
+	} else if p.expectPeekToken(token.IDENTIFIER) {
+		table.Alias = p.curToken
+	}
+
+	return table, nil
+}
+
 func (p *Parser) parseCreateTableStatement() (ast.Statement, error) {
 	stmt := &ast.CreateTableStatement{}
 
@@ -257,24 +899,21 @@ func (p *Parser) parseCreateTableStatement() (ast.Statement, error) {
 	if p.checkCurToken(token.LPAREN) {
 		p.nextToken()
 		for p.curToken != nil && !p.checkCurToken(token.RPAREN) {
-			if !p.checkCurToken(token.IDENTIFIER) {
-				return nil, errors.New("expected column name")
-			}
-
-			colName := p.curToken
-			p.nextToken()
-			if !token.IsKeyword(p.curToken) {
-				return nil, errors.New("expected column type")
-			}
-
-			colType := p.curToken
-			columnDef := &ast.ColumnDefinition{
-				Name:     colName,
-				DataType: colType,
+			if p.checkCurToken(token.PRIMARY) || p.checkCurToken(token.UNIQUE) || p.checkCurToken(token.FOREIGN) ||
+				p.checkCurToken(token.CHECK) || p.checkCurToken(token.CONSTRAINT) {
+				constraint, err := p.parseTableConstraint()
+				if err != nil {
+					return nil, err
+				}
+				stmt.Constraints = append(stmt.Constraints, constraint)
+			} else {
+				columnDef, err := p.parseColumnDefinition()
+				if err != nil {
+					return nil, err
+				}
+				stmt.Columns = append(stmt.Columns, columnDef)
 			}
-			stmt.Columns = append(stmt.Columns, columnDef)
 
-			p.nextToken()
 			if p.checkCurToken(token.COMMA) {
 				p.nextToken()
 			}
@@ -296,6 +935,304 @@ func (p *Parser) parseCreateTableStatement() (ast.Statement, error) {
 	return stmt, nil
 }
 
+// parseColumnDefinition parses a column name, its type and its
+// constraints, and leaves the parser on the token that follows them.
+func (p *Parser) parseColumnDefinition() (*ast.ColumnDefinition, error) {
+	if !p.checkCurToken(token.IDENTIFIER) {
+		return nil, errors.New("expected column name")
+	}
+
+	colName := p.curToken
+	p.nextToken()
+	if p.curToken == nil || !token.IsKeyword(p.curToken) {
+		return nil, errors.New("expected column type")
+	}
+
+	columnDef := &ast.ColumnDefinition{
+		Name:     colName,
+		DataType: p.curToken,
+	}
+
+	p.nextToken()
+	for p.curToken != nil {
+		switch p.curToken.Type {
+		case token.PRIMARY:
+			if !p.expectPeekToken(token.KEY) {
+				return nil, expectedTokenError(token.KEY)
+			}
+			columnDef.PrimaryKey = true
+		case token.UNIQUE:
+			columnDef.Unique = true
+		case token.NOT:
+			if !p.expectPeekToken(token.NULL) {
+				return nil, expectedTokenError(token.NULL)
+			}
+			columnDef.NotNull = true
+		case token.NULL:
+			// Columns are nullable by default
+		case token.REFERENCES:
+			ref, err := p.parseReferences()
+			if err != nil {
+				return nil, err
+			}
+			columnDef.References = ref
+		case token.CHECK, token.CONSTRAINT:
+			check, err := p.parseCheck()
+			if err != nil {
+				return nil, err
+			}
+			columnDef.Checks = append(columnDef.Checks, check)
+		default:
+			return columnDef, nil
+		}
+		p.nextToken()
+	}
+
+	return columnDef, nil
+}
+
+// parseTableConstraint parses PRIMARY KEY (...), UNIQUE (...),
+// FOREIGN KEY (...) REFERENCES ... or CHECK (...) and leaves the parser on
+// the token that follows it.
+func (p *Parser) parseTableConstraint() (*ast.TableConstraint, error) {
+	if p.checkCurToken(token.CHECK) || p.checkCurToken(token.CONSTRAINT) {
+		check, err := p.parseCheck()
+		if err != nil {
+			return nil, err
+		}
+
+		p.nextToken()
+		return &ast.TableConstraint{Kind: token.CHECK, Check: check}, nil
+	}
+
+	constraint := &ast.TableConstraint{Kind: p.curToken.Type}
+	if constraint.Kind != token.UNIQUE && !p.expectPeekToken(token.KEY) {
+		return nil, expectedTokenError(token.KEY)
+	}
+
+	if !p.expectPeekToken(token.LPAREN) {
+		return nil, expectedTokenError(token.LPAREN)
+	}
+
+	columns, err := p.parseColumnList()
+	if err != nil {
+		return nil, err
+	}
+	constraint.Columns = columns
+
+	if constraint.Kind == token.FOREIGN {
+		if !p.expectPeekToken(token.REFERENCES) {
+			return nil, expectedTokenError(token.REFERENCES)
+		}
+
+		ref, err := p.parseReferences()
+		if err != nil {
+			return nil, err
+		}
+		constraint.References = ref
+	}
+
+	p.nextToken()
+	return constraint, nil
+}
+
+// parseCheck parses an optionally named CHECK (...) constraint and leaves
+// the parser on its closing parenthesis.
+func (p *Parser) parseCheck() (*ast.CheckConstraint, error) {
+	check := &ast.CheckConstraint{}
+
+	if p.checkCurToken(token.CONSTRAINT) {
+		if !p.expectPeekToken(token.IDENTIFIER) {
+			return nil, errors.New("expected constraint name")
+		}
+		check.Name = p.curToken
+
+		if !p.expectPeekToken(token.CHECK) {
+			return nil, expectedTokenError(token.CHECK)
+		}
+	}
+
+	if !p.expectPeekToken(token.LPAREN) {
+		return nil, expectedTokenError(token.LPAREN)
+	}
+	lparen := p.curToken
+
+	if p.nextToken() == nil {
+		return nil, errors.New("expected expression")
+	}
+
+	expr, err := p.parseExpression(LOWEST)
+	if err != nil {
+		return nil, err
+	}
+	check.Expression = expr
+
+	if !p.expectPeekToken(token.RPAREN) {
+		return nil, expectedTokenError(token.RPAREN)
+	}
+
+	// The text is kept so the constraint can be stored and parsed again
+	source := p.lexer.Source()[lparen.Position+1 : p.curToken.Position]
+	check.Source = strings.TrimSpace(source)
+
+	return check, nil
+}
+
+// parseColumnList parses a parenthesized list of column names, starting
+// on the opening parenthesis and leaving the parser on the closing one.
+func (p *Parser) parseColumnList() ([]*token.Token, error) {
+	columns := []*token.Token{}
+
+	p.nextToken()
+	for p.checkCurToken(token.IDENTIFIER) {
+		columns = append(columns, p.curToken)
+		p.nextToken()
+
+		if p.checkCurToken(token.COMMA) {
+			p.nextToken()
+		}
+	}
+
+	if !p.checkCurToken(token.RPAREN) {
+		return nil, expectedTokenError(token.RPAREN)
+	}
+
+	if len(columns) == 0 {
+		return nil, ErrEmptyColumnsList
+	}
+
+	return columns, nil
+}
+
+// parseReferences parses a REFERENCES clause and leaves the parser on its
+// last token.
+func (p *Parser) parseReferences() (*ast.ForeignKey, error) {
+	ref := &ast.ForeignKey{}
+
+	if !p.expectPeekToken(token.IDENTIFIER) {
+		return nil, errors.New("expected table name")
+	}
+	ref.Table = p.curToken
+
+	if p.expectPeekToken(token.LPAREN) {
+		columns, err := p.parseColumnList()
+		if err != nil {
+			return nil, err
+		}
+		ref.Columns = columns
+	}
+
+	if p.expectPeekToken(token.ON) {
+		if !p.expectPeekToken(token.DELETE) {
+			return nil, expectedTokenError(token.DELETE)
+		}
+
+		switch {
+		case p.expectPeekToken(token.CASCADE):
+			ref.OnDelete = "CASCADE"
+		case p.expectPeekToken(token.RESTRICT):
+			ref.OnDelete = "RESTRICT"
+		case p.expectPeekToken(token.SET):
+			if !p.expectPeekToken(token.NULL) {
+				return nil, expectedTokenError(token.NULL)
+			}
+			ref.OnDelete = "SET NULL"
+		default:
+			return nil, errors.New("expected CASCADE, SET NULL or RESTRICT")
+		}
+	}
+
+	return ref, nil
+}
+
+func (p *Parser) parseCreateIndexStatement() (ast.Statement, error) {
+	stmt := &ast.CreateIndexStatement{}
+
+	if p.expectPeekToken(token.UNIQUE) {
+		stmt.Unique = true
+	}
+
+	if !p.expectPeekToken(token.INDEX) {
+		p.nextToken()
+		return nil, expectedTokenError(token.INDEX)
+	}
+
+	if !p.expectPeekToken(token.IDENTIFIER) {
+		p.nextToken()
+		return nil, errors.New("expected index name")
+	}
+	stmt.Name = p.curToken
+
+	if !p.expectPeekToken(token.ON) {
+		p.nextToken()
+		return nil, expectedTokenError(token.ON)
+	}
+
+	if !p.expectPeekToken(token.IDENTIFIER) {
+		p.nextToken()
+		return nil, errors.New("expected table name")
+	}
+	stmt.Table = p.curToken
+
+	if p.expectPeekToken(token.USING) {
+		if !p.expectPeekToken(token.IDENTIFIER) {
+			p.nextToken()
+			return nil, errors.New("expected index method")
+		}
+		stmt.Method = p.curToken
+	}
+
+	if !p.expectPeekToken(token.LPAREN) {
+		p.nextToken()
+		return nil, expectedTokenError(token.LPAREN)
+	}
+
+	p.nextToken()
+	for p.checkCurToken(token.IDENTIFIER) {
+		stmt.Columns = append(stmt.Columns, p.curToken)
+		p.nextToken()
+
+		if p.checkCurToken(token.COMMA) {
+			p.nextToken()
+		}
+	}
+
+	if !p.checkCurToken(token.RPAREN) {
+		return nil, expectedTokenError(token.RPAREN)
+	}
+
+	if len(stmt.Columns) == 0 {
+		return nil, ErrEmptyColumnsList
+	}
+
+	if p.checkPeekToken(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt, nil
+}
+
+func (p *Parser) parseDropIndexStatement() (ast.Statement, error) {
+	stmt := &ast.DropIndexStatement{}
+
+	if !p.expectPeekToken(token.INDEX) {
+		p.nextToken()
+		return nil, expectedTokenError(token.INDEX)
+	}
+
+	if !p.expectPeekToken(token.IDENTIFIER) {
+		p.nextToken()
+		return nil, errors.New("expected index name")
+	}
+	stmt.Name = p.curToken
+
+	if p.checkPeekToken(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt, nil
+}
+
 func (p *Parser) parseInsertStatement() (ast.Statement, error) {
 	stmt := &ast.InsertStatement{}
 
@@ -349,12 +1286,7 @@ func (p *Parser) parseInsertStatement() (ast.Statement, error) {
 			return nil, err
 		}
 
-		evaluatedExpr, err := evaluator.EvalExpression(expr, nil)
-		if err != nil {
-			return nil, err
-		}
-
-		stmt.Values = append(stmt.Values, evaluatedExpr)
+		stmt.Values = append(stmt.Values, expr)
 		p.nextToken()
 
 		if p.checkCurToken(token.COMMA) {
@@ -429,10 +1361,13 @@ func (p *Parser) parseUpdateStatement() (ast.Statement, error) {
 		}
 		p.nextToken()
 
-		value := p.curToken
+		value, err := p.parseExpression(LOWEST)
+		if err != nil {
+			return nil, err
+		}
 		p.nextToken()
 
-		stmt.Update = append(stmt.Update, []*token.Token{colName, value})
+		stmt.Update = append(stmt.Update, &ast.SetClause{Column: colName, Value: value})
 
 		if p.checkCurToken(token.COMMA) {
 			p.nextToken()
@@ -461,8 +1396,56 @@ func (p *Parser) parseUpdateStatement() (ast.Statement, error) {
 	return stmt, nil
 }
 
+func (p *Parser) parseTransactionStatement() (ast.Statement, error) {
+	var stmt ast.Statement
+	switch p.curToken.Type {
+	case token.BEGIN:
+		stmt = &ast.BeginStatement{Token: p.curToken}
+	case token.COMMIT:
+		stmt = &ast.CommitStatement{Token: p.curToken}
+	case token.ROLLBACK:
+		stmt = &ast.RollbackStatement{Token: p.curToken}
+	}
+
+	// TRANSACTION is optional
+	if p.checkPeekToken(token.TRANSACTION) {
+		p.nextToken()
+	}
+
+	if p.checkPeekToken(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt, nil
+}
+
+// ParseExpression parses source as a single expression.
+func ParseExpression(source string) (ast.Expression, error) {
+	p := New(lexer.New(source))
+	p.it = lib.NewIterator(p.lexer.Tokenize())
+
+	if p.nextToken() == nil {
+		return nil, errors.New("expected expression")
+	}
+
+	expr, err := p.parseExpression(LOWEST)
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.checkPeekToken(token.EOF) {
+		return nil, fmt.Errorf("unexpected %q", p.peekToken.Literal)
+	}
+
+	return expr, nil
+}
+
 func (p *Parser) parseExpression(precedence OperatorPrecedence) (ast.Expression, error) {
 	tok := p.curToken
+	if tok == nil {
+		return nil, errors.New("expected expression")
+	}
+
 	prefixFn, ok := p.prefixParseFns[tok.Type]
 	if !ok {
 		return nil, fmt.Errorf("no prefix parse function for %s", tok.Type)
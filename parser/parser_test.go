@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"jnafolayan/sql-db/ast"
+	"jnafolayan/sql-db/evaluator"
 	"jnafolayan/sql-db/lexer"
 	"testing"
 )
@@ -17,6 +18,26 @@ func TestParseSelectStatement(t *testing.T) {
 	}{
 		{"SELECT * FROM people", nil, "people", []string{"*"}},
 		{"SELECT name, age FROM people", nil, "people", []string{"name", "age"}},
+		{"SELECT name, age + 1 AS next_age, -age old FROM people", nil, "people", []string{"name", "age+1 AS next_age", "-age AS old"}},
+		{"SELECT people.*, CASE WHEN age > 30 THEN 'old' END AS label FROM people", nil, "people", []string{"people.*", "CASE WHEN age>30 THEN old END AS label"}},
+		{"SELECT name AS FROM people", errors.New("expected column alias"), "people", nil},
+		// Qualified names are parsed the same way everywhere
+		{"SELECT people. FROM people", errors.New("expected identifier"), "people", nil},
+		{"SELECT p.*, p.id * 2 FROM people p", nil, "people AS p", []string{"p.*", "p.id*2"}},
+		{"SELECT p.name, p.age + 1 AS next FROM people p", nil, "people AS p", []string{"p.name", "p.age+1 AS next"}},
+		{"SELECT * FROM people AS p JOIN pets ON p.id = pets.owner", nil, "people AS p INNER JOIN pets ON p.id=pets.owner", []string{"*"}},
+		{"SELECT * FROM a, b CROSS JOIN c", nil, "a CROSS JOIN b CROSS JOIN c", []string{"*"}},
+		{
+			"SELECT a.x FROM a LEFT OUTER JOIN b USING (x, y) right join c on c.x = a.x FULL JOIN d ON 1 = 1 INNER JOIN e ON e.x IN (a.x)",
+			nil,
+			"a LEFT JOIN b USING (x, y) RIGHT JOIN c ON c.x=a.x FULL JOIN d ON 1=1 INNER JOIN e ON e.x IN (a.x)",
+			[]string{"a.x"},
+		},
+		{"SELECT * FROM a JOIN b", errors.New("expected ON or USING"), "", nil},
+		{"SELECT * FROM a LEFT b", errors.New("expected JOIN"), "", nil},
+		{"SELECT * FROM a JOIN", errors.New("expected table name"), "", nil},
+		{"SELECT * FROM a AS", errors.New("expected table alias"), "", nil},
+		{"SELECT * FROM a WHERE a. = 1", errors.New("expected identifier"), "", nil},
 		{
 			"SELECT , FROM people",
 			ErrEmptyColumnsList,
@@ -37,7 +58,7 @@ func TestParseSelectStatement(t *testing.T) {
 				if tt.expectedError == nil {
 					sub.Fatalf("expected no error, got %q", err)
 				}
-				if !errors.Is(err, tt.expectedError) {
+				if !errors.Is(err, tt.expectedError) && err.Error() != tt.expectedError.Error() {
 					sub.Fatalf("expected %q error, got %q", tt.expectedError, err)
 				}
 				return
@@ -55,13 +76,17 @@ func TestParseSelectStatement(t *testing.T) {
 			}
 
 			selectStmt := stmt.(*ast.SelectStatement)
-			if selectStmt.Table.Literal != tt.expectedTable {
-				t.Fatalf("expected table %q, got %q", tt.expectedTable, selectStmt.Table.Literal)
+			if selectStmt.From.String() != tt.expectedTable {
+				t.Fatalf("expected table %q, got %q", tt.expectedTable, selectStmt.From.String())
+			}
+
+			if len(selectStmt.Columns) != len(tt.expectedCols) {
+				t.Fatalf("expected %d columns, got %d", len(tt.expectedCols), len(selectStmt.Columns))
 			}
 
 			for i, col := range selectStmt.Columns {
-				if col.Literal != tt.expectedCols[i] {
-					t.Errorf("expected %q column, got %q", tt.expectedCols[i], col.Literal)
+				if col.String() != tt.expectedCols[i] {
+					t.Errorf("expected %q column, got %q", tt.expectedCols[i], col.String())
 				}
 			}
 		})
@@ -252,6 +277,7 @@ func TestParseUpdateStatement(t *testing.T) {
 		expectedString string
 	}{
 		{"UPDATE people SET name='Jaden' WHERE age=40", nil, "UPDATE people SET name=Jaden WHERE age=40"},
+		{"UPDATE people SET age = age + 1, name = CASE WHEN age > 30 THEN 'old' END", nil, "UPDATE people SET age=age+1, name=CASE WHEN age>30 THEN old END"},
 	}
 
 	for i, tt := range tests {
@@ -290,3 +316,849 @@ func TestParseUpdateStatement(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTransactionStatements(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedTypes []ast.NodeType
+	}{
+		{"BEGIN", []ast.NodeType{ast.BEGIN}},
+		{"BEGIN TRANSACTION; COMMIT", []ast.NodeType{ast.BEGIN, ast.COMMIT}},
+		{"begin; DELETE FROM people; rollback;", []ast.NodeType{ast.BEGIN, ast.DELETE, ast.ROLLBACK}},
+	}
+
+	for i, tt := range tests {
+		testName := fmt.Sprintf("TRANSACTION_%d", i)
+		t.Run(testName, func(sub *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			p.OmitErrorLocation = true
+			program, err := p.Parse()
+			if err != nil {
+				sub.Fatalf("expected no error, got %q", err)
+			}
+
+			if len(program.Statements) != len(tt.expectedTypes) {
+				sub.Fatalf("expected %d statements, got %d", len(tt.expectedTypes), len(program.Statements))
+			}
+
+			for i, stmt := range program.Statements {
+				if stmt.Type() != tt.expectedTypes[i] {
+					sub.Errorf("expected %s statement, got %s", tt.expectedTypes[i], stmt.Type())
+				}
+			}
+
+			if !program.HasTransactionStatements() {
+				sub.Errorf("expected the program to have transaction statements")
+			}
+		})
+	}
+}
+
+func TestParseIndexStatements(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedError  string
+		expectedString string
+	}{
+		{"CREATE INDEX people_age ON people (age)", "", "CREATE INDEX people_age ON people (age)"},
+		{"create unique index people_name on people (last, first);", "", "CREATE UNIQUE INDEX people_name ON people (last, first)"},
+		{"CREATE UNIQUE INDEX people_email ON people USING hash (email)", "", "CREATE UNIQUE INDEX people_email ON people USING HASH (email)"},
+		{"DROP INDEX people_age", "", "DROP INDEX people_age"},
+		{"CREATE INDEX ON people (age)", "expected index name", ""},
+		{"CREATE INDEX people_age ON people", "expected (", ""},
+		{"CREATE INDEX people_age ON people USING (age)", "expected index method", ""},
+	}
+
+	for i, tt := range tests {
+		testName := fmt.Sprintf("INDEX_%d", i)
+		t.Run(testName, func(sub *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			p.OmitErrorLocation = true
+			program, err := p.Parse()
+
+			if err != nil {
+				if tt.expectedError == "" {
+					sub.Fatalf("expected no error, got %q", err)
+				}
+				if err.Error() != tt.expectedError {
+					sub.Fatalf("expected %q error, got %q", tt.expectedError, err)
+				}
+				return
+			} else if tt.expectedError != "" {
+				sub.Fatalf("expected %q error, got no error", tt.expectedError)
+			}
+
+			if len(program.Statements) != 1 {
+				sub.Fatalf("expected 1 statement, got %d", len(program.Statements))
+			}
+
+			stmt := program.Statements[0]
+			if stmt.String() != tt.expectedString {
+				sub.Fatalf("expected %q, got %q", tt.expectedString, stmt.String())
+			}
+		})
+	}
+}
+
+func TestParseInExpression(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedError  string
+		expectedValues int
+	}{
+		{"SELECT name FROM people WHERE age IN (40)", "", 1},
+		{"SELECT name FROM people WHERE name in ('John', 'Julia', 'Jake')", "", 3},
+		{"SELECT name FROM people WHERE age IN 40", "expected (", 0},
+		{"SELECT name FROM people WHERE age IN (40, 41", "expected )", 0},
+		{"SELECT name FROM people WHERE age NOT IN (40, 41 + 1)", "", 2},
+	}
+
+	for i, tt := range tests {
+		testName := fmt.Sprintf("IN_%d", i)
+		t.Run(testName, func(sub *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			p.OmitErrorLocation = true
+			program, err := p.Parse()
+
+			if err != nil {
+				if tt.expectedError == "" {
+					sub.Fatalf("expected no error, got %q", err)
+				}
+				if err.Error() != tt.expectedError {
+					sub.Fatalf("expected %q error, got %q", tt.expectedError, err)
+				}
+				return
+			} else if tt.expectedError != "" {
+				sub.Fatalf("expected %q error, got no error", tt.expectedError)
+			}
+
+			selectStmt := program.Statements[0].(*ast.SelectStatement)
+			in, ok := selectStmt.Predicate.(*ast.InExpression)
+			if !ok {
+				sub.Fatalf("expected an IN expression, got %T", selectStmt.Predicate)
+			}
+
+			if len(in.Values) != tt.expectedValues {
+				sub.Errorf("expected %d values, got %d", tt.expectedValues, len(in.Values))
+			}
+		})
+	}
+}
+
+func TestParseNullExpressions(t *testing.T) {
+	tests := []struct {
+		input             string
+		expectedError     string
+		expectedPredicate string
+	}{
+		{"SELECT name FROM people WHERE age IS NULL", "", "age IS NULL"},
+		{"SELECT name FROM people WHERE age is not null", "", "age IS NOT NULL"},
+		{"SELECT name FROM people WHERE age = NULL", "", "age=NULL"},
+		{"SELECT name FROM people WHERE age IS 40", "expected NULL", ""},
+		{"SELECT name FROM people WHERE age IS NOT", "expected NULL", ""},
+	}
+
+	for i, tt := range tests {
+		testName := fmt.Sprintf("NULL_%d", i)
+		t.Run(testName, func(sub *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			p.OmitErrorLocation = true
+			program, err := p.Parse()
+
+			if err != nil {
+				if tt.expectedError == "" {
+					sub.Fatalf("expected no error, got %q", err)
+				}
+				if err.Error() != tt.expectedError {
+					sub.Fatalf("expected %q error, got %q", tt.expectedError, err)
+				}
+				return
+			} else if tt.expectedError != "" {
+				sub.Fatalf("expected %q error, got no error", tt.expectedError)
+			}
+
+			selectStmt := program.Statements[0].(*ast.SelectStatement)
+			if selectStmt.Predicate.String() != tt.expectedPredicate {
+				sub.Errorf("expected %q, got %q", tt.expectedPredicate, selectStmt.Predicate.String())
+			}
+		})
+	}
+}
+
+func TestParseBetweenExpression(t *testing.T) {
+	tests := []struct {
+		input             string
+		expectedError     string
+		expectedPredicate string
+	}{
+		{"SELECT name FROM people WHERE age BETWEEN 20 AND 30", "", "age BETWEEN 20 AND 30"},
+		{"SELECT name FROM people WHERE age not between 20 and 30", "", "age NOT BETWEEN 20 AND 30"},
+		{"SELECT name FROM people WHERE age BETWEEN 1 + 1 AND 30 * 2", "", "age BETWEEN 1+1 AND 30*2"},
+		{"SELECT name FROM people WHERE name = 'John' AND age BETWEEN 20 AND 30 AND age IN (25)", "", "((name=John)AND(age BETWEEN 20 AND 30))AND(age IN (25))"},
+		{"SELECT name FROM people WHERE age BETWEEN 20", "expected AND", ""},
+		{"SELECT name FROM people WHERE age BETWEEN 20 AND", "expected expression", ""},
+	}
+
+	for i, tt := range tests {
+		testName := fmt.Sprintf("BETWEEN_%d", i)
+		t.Run(testName, func(sub *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			p.OmitErrorLocation = true
+			program, err := p.Parse()
+
+			if err != nil {
+				if tt.expectedError == "" {
+					sub.Fatalf("expected no error, got %q", err)
+				}
+				if err.Error() != tt.expectedError {
+					sub.Fatalf("expected %q error, got %q", tt.expectedError, err)
+				}
+				return
+			} else if tt.expectedError != "" {
+				sub.Fatalf("expected %q error, got no error", tt.expectedError)
+			}
+
+			selectStmt := program.Statements[0].(*ast.SelectStatement)
+			if selectStmt.Predicate.String() != tt.expectedPredicate {
+				sub.Errorf("expected %q, got %q", tt.expectedPredicate, selectStmt.Predicate.String())
+			}
+		})
+	}
+}
+
+func TestParseCaseExpression(t *testing.T) {
+	tests := []struct {
+		input             string
+		expectedError     string
+		expectedPredicate string
+	}{
+		{"SELECT name FROM people WHERE CASE WHEN age > 30 THEN 1 ELSE 0 END = 1", "", "CASE WHEN age>30 THEN 1 ELSE 0 END=1"},
+		{"SELECT name FROM people WHERE case status when 'a' then 1 when 'b' then 2 end = 2", "", "CASE status WHEN a THEN 1 WHEN b THEN 2 END=2"},
+		{"SELECT name FROM people WHERE CASE age + 1 WHEN 2 * 2 THEN age IS NULL END", "", "CASE age+1 WHEN 2*2 THEN age IS NULL END"},
+		{"SELECT name FROM people WHERE CASE WHEN age IN (1, 2) THEN CASE WHEN name = 'John' THEN 1 END END = 1", "", "CASE WHEN age IN (1, 2) THEN CASE WHEN name=John THEN 1 END END=1"},
+		{"SELECT name FROM people WHERE CASE END", "expected WHEN", ""},
+		{"SELECT name FROM people WHERE CASE WHEN age > 30 1 END", "expected THEN", ""},
+		{"SELECT name FROM people WHERE CASE WHEN age > 30 THEN 1 ELSE 0", "expected END", ""},
+	}
+
+	for i, tt := range tests {
+		testName := fmt.Sprintf("CASE_%d", i)
+		t.Run(testName, func(sub *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			p.OmitErrorLocation = true
+			program, err := p.Parse()
+
+			if err != nil {
+				if tt.expectedError == "" {
+					sub.Fatalf("expected no error, got %q", err)
+				}
+				if err.Error() != tt.expectedError {
+					sub.Fatalf("expected %q error, got %q", tt.expectedError, err)
+				}
+				return
+			} else if tt.expectedError != "" {
+				sub.Fatalf("expected %q error, got no error", tt.expectedError)
+			}
+
+			selectStmt := program.Statements[0].(*ast.SelectStatement)
+			if selectStmt.Predicate.String() != tt.expectedPredicate {
+				sub.Errorf("expected %q, got %q", tt.expectedPredicate, selectStmt.Predicate.String())
+			}
+		})
+	}
+}
+
+func TestParseGroupBy(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedError  string
+		expectedString string
+	}{
+		{"SELECT COUNT(*) FROM people", "", "SELECT COUNT(*) FROM people"},
+		{"SELECT count(DISTINCT age), max(age) - min(age) AS spread FROM people", "", "SELECT count(DISTINCT age), max(age)-min(age) AS spread FROM people"},
+		{
+			"SELECT status, SUM(balance * 2) FROM people WHERE age > 1 GROUP BY status, age / 10 HAVING COUNT(*) > 1 AND AVG(age) < 40",
+			"",
+			"SELECT status, SUM(balance*2) FROM people WHERE age>1 GROUP BY status, age/10 HAVING (COUNT(*)>1)AND(AVG(age)<40)",
+		},
+		{"SELECT name FROM people HAVING 1 = 1", "", "SELECT name FROM people HAVING 1=1"},
+		{"SELECT f() FROM people", "", "SELECT f() FROM people"},
+		{"SELECT COUNT(* FROM people", "expected )", ""},
+		{"SELECT COUNT(age FROM people", "expected )", ""},
+		{"SELECT people.count(age) FROM people", "expected function name", ""},
+		{"SELECT status FROM people GROUP status", "expected BY", ""},
+		{"SELECT status FROM people GROUP BY", "expected expression", ""},
+	}
+
+	for i, tt := range tests {
+		testName := fmt.Sprintf("GROUP_%d", i)
+		t.Run(testName, func(sub *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			p.OmitErrorLocation = true
+			program, err := p.Parse()
+
+			if err != nil {
+				if tt.expectedError == "" {
+					sub.Fatalf("expected no error, got %q", err)
+				}
+				if err.Error() != tt.expectedError {
+					sub.Fatalf("expected %q error, got %q", tt.expectedError, err)
+				}
+				return
+			} else if tt.expectedError != "" {
+				sub.Fatalf("expected %q error, got no error", tt.expectedError)
+			}
+
+			if program.Statements[0].String() != tt.expectedString {
+				sub.Errorf("expected %q, got %q", tt.expectedString, program.Statements[0].String())
+			}
+		})
+	}
+}
+
+func TestParseOrderBy(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedError  string
+		expectedString string
+	}{
+		{"SELECT name FROM people ORDER BY name", "", "SELECT name FROM people ORDER BY name"},
+		{"SELECT name FROM people ORDER BY name ASC", "", "SELECT name FROM people ORDER BY name"},
+		{
+			"SELECT name FROM people WHERE age > 1 ORDER BY age DESC NULLS LAST, people.name nulls first LIMIT 10 OFFSET 5;",
+			"",
+			"SELECT name FROM people WHERE age>1 ORDER BY age DESC NULLS LAST, people.name NULLS FIRST LIMIT 10 OFFSET 5",
+		},
+		{"SELECT status, COUNT(*) FROM people GROUP BY status ORDER BY COUNT(*) DESC, 1", "", "SELECT status, COUNT(*) FROM people GROUP BY status ORDER BY COUNT(*) DESC, 1"},
+		{"SELECT name FROM people LIMIT 2 + 1", "", "SELECT name FROM people LIMIT 2+1"},
+		// FIRST and LAST can still name columns
+		{"SELECT first FROM people ORDER BY last", "", "SELECT first FROM people ORDER BY last"},
+		{"SELECT name FROM people ORDER name", "expected BY", ""},
+		{"SELECT name FROM people ORDER BY", "expected expression", ""},
+		{"SELECT name FROM people ORDER BY name NULLS", "expected FIRST or LAST", ""},
+		{"SELECT name FROM people ORDER BY name NULLS age", "expected FIRST or LAST", ""},
+		{"SELECT name FROM people LIMIT", "expected expression", ""},
+		{"SELECT name FROM people LIMIT 1 OFFSET", "expected expression", ""},
+	}
+
+	for i, tt := range tests {
+		testName := fmt.Sprintf("ORDER_%d", i)
+		t.Run(testName, func(sub *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			p.OmitErrorLocation = true
+			program, err := p.Parse()
+
+			if err != nil {
+				if tt.expectedError == "" {
+					sub.Fatalf("expected no error, got %q", err)
+				}
+				if err.Error() != tt.expectedError {
+					sub.Fatalf("expected %q error, got %q", tt.expectedError, err)
+				}
+				return
+			} else if tt.expectedError != "" {
+				sub.Fatalf("expected %q error, got no error", tt.expectedError)
+			}
+
+			if program.Statements[0].String() != tt.expectedString {
+				sub.Errorf("expected %q, got %q", tt.expectedString, program.Statements[0].String())
+			}
+		})
+	}
+}
+
+func TestParseDistinct(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedError  string
+		expectedString string
+	}{
+		{"SELECT DISTINCT name FROM people", "", "SELECT DISTINCT name FROM people"},
+		{"select distinct name, age + 1 FROM people ORDER BY name", "", "SELECT DISTINCT name, age+1 FROM people ORDER BY name"},
+		{"SELECT DISTINCT ON (status) name FROM people", "", "SELECT DISTINCT ON (status) name FROM people"},
+		{
+			"SELECT DISTINCT ON (status, age / 10) * FROM people ORDER BY status, age / 10, name LIMIT 3",
+			"",
+			"SELECT DISTINCT ON (status, age/10) * FROM people ORDER BY status, age/10, name LIMIT 3",
+		},
+		{"SELECT DISTINCT FROM people", ErrEmptyColumnsList.Error(), ""},
+		{"SELECT DISTINCT ON status name FROM people", "expected (", ""},
+		{"SELECT DISTINCT ON (status name FROM people", "expected )", ""},
+		{"SELECT DISTINCT ON () name FROM people", "no prefix parse function for )", ""},
+	}
+
+	for i, tt := range tests {
+		testName := fmt.Sprintf("DISTINCT_%d", i)
+		t.Run(testName, func(sub *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			p.OmitErrorLocation = true
+			program, err := p.Parse()
+
+			if err != nil {
+				if tt.expectedError == "" {
+					sub.Fatalf("expected no error, got %q", err)
+				}
+				if err.Error() != tt.expectedError {
+					sub.Fatalf("expected %q error, got %q", tt.expectedError, err)
+				}
+				return
+			} else if tt.expectedError != "" {
+				sub.Fatalf("expected %q error, got no error", tt.expectedError)
+			}
+
+			if program.Statements[0].String() != tt.expectedString {
+				sub.Errorf("expected %q, got %q", tt.expectedString, program.Statements[0].String())
+			}
+		})
+	}
+}
+
+func TestParseSubqueries(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedError  string
+		expectedString string
+	}{
+		{"SELECT name FROM people WHERE age > (SELECT AVG(age) FROM people)", "", "SELECT name FROM people WHERE age>(SELECT AVG(age) FROM people)"},
+		{
+			"SELECT name, (SELECT COUNT(*) FROM pets WHERE pets.owner = p.id) FROM people AS p",
+			"",
+			"SELECT name, (SELECT COUNT(*) FROM pets WHERE pets.owner=p.id) FROM people AS p",
+		},
+		{"SELECT name FROM people WHERE id IN (SELECT owner FROM pets)", "", "SELECT name FROM people WHERE id IN (SELECT owner FROM pets)"},
+		{
+			"SELECT name FROM people WHERE id NOT IN (SELECT owner FROM pets WHERE kind = 'cat')",
+			"",
+			"SELECT name FROM people WHERE id NOT IN (SELECT owner FROM pets WHERE kind=cat)",
+		},
+		{"SELECT name FROM people WHERE EXISTS (SELECT * FROM pets WHERE owner = id)", "", "SELECT name FROM people WHERE EXISTS (SELECT * FROM pets WHERE owner=id)"},
+		{"SELECT name FROM people WHERE NOT EXISTS (SELECT * FROM pets) AND age > 1", "", "SELECT name FROM people WHERE NOT EXISTS (SELECT * FROM pets)AND(age>1)"},
+		{"SELECT name FROM people WHERE (age) > 1", "", "SELECT name FROM people WHERE age>1"},
+		// Other conditions can be negated too
+		{"SELECT name FROM people WHERE not age = 1 AND id > 2", "", "SELECT name FROM people WHERE (NOT (age=1))AND(id>2)"},
+		{"SELECT name FROM people WHERE NOT NOT EXISTS (SELECT * FROM pets)", "", "SELECT name FROM people WHERE NOT NOT EXISTS (SELECT * FROM pets)"},
+		{"SELECT name FROM people WHERE NOT", "expected expression", ""},
+		{"SELECT name FROM people WHERE age > (SELECT age FROM people", "expected )", ""},
+		{"SELECT name FROM people WHERE EXISTS SELECT * FROM pets", "expected (", ""},
+		{"SELECT name FROM people WHERE EXISTS (1)", "expected SELECT", ""},
+		{"SELECT name FROM people WHERE NOT (SELECT id FROM pets)", "", "SELECT name FROM people WHERE NOT (SELECT id FROM pets)"},
+	}
+
+	for i, tt := range tests {
+		testName := fmt.Sprintf("SUBQUERY_%d", i)
+		t.Run(testName, func(sub *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			p.OmitErrorLocation = true
+			program, err := p.Parse()
+
+			if err != nil {
+				if tt.expectedError == "" {
+					sub.Fatalf("expected no error, got %q", err)
+				}
+				if err.Error() != tt.expectedError {
+					sub.Fatalf("expected %q error, got %q", tt.expectedError, err)
+				}
+				return
+			} else if tt.expectedError != "" {
+				sub.Fatalf("expected %q error, got no error", tt.expectedError)
+			}
+
+			if program.Statements[0].String() != tt.expectedString {
+				sub.Errorf("expected %q, got %q", tt.expectedString, program.Statements[0].String())
+			}
+		})
+	}
+}
+
+func TestParseWith(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedError  string
+		expectedString string
+	}{
+		{
+			"WITH rich AS (SELECT name FROM people WHERE balance > 100) SELECT * FROM rich",
+			"",
+			"WITH rich AS (SELECT name FROM people WHERE balance>100) SELECT * FROM rich",
+		},
+		{
+			"with a (x, y) as (select id, age from people), b as (select x from a) select * from b",
+			"",
+			"WITH a (x, y) AS (SELECT id, age FROM people), b AS (SELECT x FROM a) SELECT * FROM b",
+		},
+		{
+			"WITH RECURSIVE chain (id) AS (SELECT id FROM people WHERE boss IS NULL UNION ALL SELECT p.id FROM people AS p JOIN chain AS c ON p.boss = c.id) SELECT * FROM chain",
+			"",
+			"WITH RECURSIVE chain (id) AS (SELECT id FROM people WHERE boss IS NULL UNION ALL SELECT p.id FROM people AS p INNER JOIN chain AS c ON p.boss=c.id) SELECT * FROM chain",
+		},
+		{
+			"WITH RECURSIVE a AS (SELECT id FROM people UNION SELECT id FROM a) SELECT * FROM a",
+			"",
+			"WITH RECURSIVE a AS (SELECT id FROM people UNION SELECT id FROM a) SELECT * FROM a",
+		},
+		{
+			"SELECT * FROM people WHERE id IN (WITH a AS (SELECT id FROM people) SELECT id FROM a)",
+			"",
+			"SELECT * FROM people WHERE id IN (WITH a AS (SELECT id FROM people) SELECT id FROM a)",
+		},
+		{"WITH AS (SELECT id FROM people) SELECT * FROM a", "expected table name", ""},
+		{"WITH a (SELECT id FROM people) SELECT * FROM a", "expected )", ""},
+		{"WITH a () AS (SELECT id FROM people) SELECT * FROM a", ErrEmptyColumnsList.Error(), ""},
+		{"WITH a SELECT id FROM people", "expected AS", ""},
+		{"WITH a AS SELECT id FROM people", "expected (", ""},
+		{"WITH a AS (people) SELECT * FROM a", "expected SELECT", ""},
+		{"WITH a AS (SELECT id FROM people) DELETE FROM a", "expected SELECT", ""},
+		{
+			"WITH a AS (SELECT id FROM people UNION SELECT id FROM a) SELECT * FROM a",
+			"",
+			"WITH a AS (SELECT id FROM people UNION SELECT id FROM a) SELECT * FROM a",
+		},
+		{"WITH RECURSIVE a AS (SELECT id FROM people UNION ALL a) SELECT * FROM a", "expected SELECT", ""},
+	}
+
+	for i, tt := range tests {
+		testName := fmt.Sprintf("WITH_%d", i)
+		t.Run(testName, func(sub *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			p.OmitErrorLocation = true
+			program, err := p.Parse()
+
+			if err != nil {
+				if tt.expectedError == "" {
+					sub.Fatalf("expected no error, got %q", err)
+				}
+				if err.Error() != tt.expectedError {
+					sub.Fatalf("expected %q error, got %q", tt.expectedError, err)
+				}
+				return
+			} else if tt.expectedError != "" {
+				sub.Fatalf("expected %q error, got no error", tt.expectedError)
+			}
+
+			if program.Statements[0].String() != tt.expectedString {
+				sub.Errorf("expected %q, got %q", tt.expectedString, program.Statements[0].String())
+			}
+		})
+	}
+}
+
+func TestParseCompoundSelects(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedError  string
+		expectedString string
+	}{
+		{"SELECT id FROM people UNION SELECT owner FROM pets", "", "SELECT id FROM people UNION SELECT owner FROM pets"},
+		{
+			"select id from people union all select owner from pets where owner > 1 except select id from banned",
+			"",
+			"SELECT id FROM people UNION ALL SELECT owner FROM pets WHERE owner>1 EXCEPT SELECT id FROM banned",
+		},
+		{
+			"SELECT name, age FROM people INTERSECT ALL SELECT DISTINCT name, age FROM staff GROUP BY name, age ORDER BY 2 DESC, name LIMIT 3 OFFSET 1",
+			"",
+			"SELECT name, age FROM people INTERSECT ALL SELECT DISTINCT name, age FROM staff GROUP BY name, age ORDER BY 2 DESC, name LIMIT 3 OFFSET 1",
+		},
+		// Stars are counted by the engine
+		{"SELECT * FROM people UNION SELECT id, name FROM staff", "", "SELECT * FROM people UNION SELECT id, name FROM staff"},
+		{
+			"SELECT id FROM people WHERE id IN (SELECT owner FROM pets EXCEPT SELECT id FROM banned)",
+			"",
+			"SELECT id FROM people WHERE id IN (SELECT owner FROM pets EXCEPT SELECT id FROM banned)",
+		},
+		{"SELECT id, name FROM people UNION SELECT id FROM staff", ErrCompoundColumns.Error(), ""},
+		{"SELECT id FROM people UNION ALL SELECT id FROM staff INTERSECT SELECT id, name FROM pets", ErrCompoundColumns.Error(), ""},
+		{"SELECT id FROM people UNION", "expected SELECT", ""},
+		{"SELECT id FROM people INTERSECT ALL people", "expected SELECT", ""},
+		// ORDER BY and LIMIT only come after the last query
+		{"SELECT id FROM people ORDER BY id UNION SELECT id FROM staff", "expected ;", ""},
+	}
+
+	for i, tt := range tests {
+		testName := fmt.Sprintf("COMPOUND_%d", i)
+		t.Run(testName, func(sub *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			p.OmitErrorLocation = true
+			program, err := p.Parse()
+
+			if err != nil {
+				if tt.expectedError == "" {
+					sub.Fatalf("expected no error, got %q", err)
+				}
+				if err.Error() != tt.expectedError {
+					sub.Fatalf("expected %q error, got %q", tt.expectedError, err)
+				}
+				return
+			} else if tt.expectedError != "" {
+				sub.Fatalf("expected %q error, got no error", tt.expectedError)
+			}
+
+			if program.Statements[0].String() != tt.expectedString {
+				sub.Errorf("expected %q, got %q", tt.expectedString, program.Statements[0].String())
+			}
+		})
+	}
+}
+
+func TestParseWindows(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedError  string
+		expectedString string
+	}{
+		{"SELECT ROW_NUMBER() OVER () FROM people", "", "SELECT ROW_NUMBER() OVER () FROM people"},
+		{
+			"select name, rank() over (partition by team, city order by score desc nulls last) from people",
+			"",
+			"SELECT name, rank() OVER (PARTITION BY team, city ORDER BY score DESC NULLS LAST) FROM people",
+		},
+		{"SELECT LAG(score, 2, 0) OVER (ORDER BY id) FROM people", "", "SELECT LAG(score, 2, 0) OVER (ORDER BY id) FROM people"},
+		{
+			"SELECT SUM(score) OVER (ORDER BY id ROWS BETWEEN 2 PRECEDING AND CURRENT ROW) FROM people",
+			"",
+			"SELECT SUM(score) OVER (ORDER BY id ROWS BETWEEN 2 PRECEDING AND CURRENT ROW) FROM people",
+		},
+		// A frame with only a start ends at the current row
+		{
+			"SELECT COUNT(*) OVER (ROWS UNBOUNDED PRECEDING) FROM people",
+			"",
+			"SELECT COUNT(*) OVER (ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) FROM people",
+		},
+		{
+			"SELECT AVG(score) OVER (PARTITION BY team RANGE BETWEEN CURRENT ROW AND UNBOUNDED FOLLOWING) FROM people",
+			"",
+			"SELECT AVG(score) OVER (PARTITION BY team RANGE BETWEEN CURRENT ROW AND UNBOUNDED FOLLOWING) FROM people",
+		},
+		{
+			"SELECT id FROM people ORDER BY DENSE_RANK() OVER (ORDER BY score) + 1",
+			"",
+			"SELECT id FROM people ORDER BY DENSE_RANK() OVER (ORDER BY score)+1",
+		},
+		{"SELECT ROW_NUMBER() OVER FROM people", "expected (", ""},
+		{"SELECT ROW_NUMBER() OVER (PARTITION id) FROM people", "expected BY", ""},
+		{"SELECT SUM(id) OVER (ROWS BETWEEN 1 PRECEDING) FROM people", "expected AND", ""},
+		{"SELECT SUM(id) OVER (ROWS 1) FROM people", "expected PRECEDING or FOLLOWING", ""},
+		{"SELECT SUM(id) OVER (ROWS CURRENT) FROM people", "expected ROW", ""},
+		{"SELECT SUM(id) OVER (ROWS UNBOUNDED FOLLOWING) FROM people", "frame can't start at UNBOUNDED FOLLOWING", ""},
+		{"SELECT SUM(id) OVER (ROWS BETWEEN CURRENT ROW AND UNBOUNDED PRECEDING) FROM people", "frame can't end at UNBOUNDED PRECEDING", ""},
+		{"SELECT SUM(id) OVER (ROWS BETWEEN 1 FOLLOWING AND CURRENT ROW) FROM people", "frame can't start after it ends", ""},
+		{"SELECT SUM(id) OVER (ORDER BY id RANGE 1 PRECEDING) FROM people", "RANGE frames can only be bound by UNBOUNDED or CURRENT ROW", ""},
+	}
+
+	for i, tt := range tests {
+		testName := fmt.Sprintf("WINDOW_%d", i)
+		t.Run(testName, func(sub *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			p.OmitErrorLocation = true
+			program, err := p.Parse()
+
+			if err != nil {
+				if tt.expectedError == "" {
+					sub.Fatalf("expected no error, got %q", err)
+				}
+				if err.Error() != tt.expectedError {
+					sub.Fatalf("expected %q error, got %q", tt.expectedError, err)
+				}
+				return
+			} else if tt.expectedError != "" {
+				sub.Fatalf("expected %q error, got no error", tt.expectedError)
+			}
+
+			if program.Statements[0].String() != tt.expectedString {
+				sub.Errorf("expected %q, got %q", tt.expectedString, program.Statements[0].String())
+			}
+		})
+	}
+}
+
+func TestParsePatternExpressions(t *testing.T) {
+	tests := []struct {
+		input             string
+		expectedError     string
+		expectedPredicate string
+	}{
+		{"SELECT name FROM people WHERE name LIKE 'Jo%'", "", "name LIKE Jo%"},
+		{"SELECT name FROM people WHERE name not like 'Jo%' AND age = 1", "", "(name NOT LIKE Jo%)AND(age=1)"},
+		{"SELECT name FROM people WHERE name LIKE '50!%' ESCAPE '!'", "", "name LIKE 50!% ESCAPE !"},
+		{"SELECT name FROM people WHERE name GLOB 'J*'", "", "name GLOB J*"},
+		{"SELECT name FROM people WHERE name NOT REGEXP '^J'", "", "name NOT REGEXP ^J"},
+		{"SELECT name FROM people WHERE name LIKE", "expected expression", ""},
+		{"SELECT name FROM people WHERE name NOT 'Jo%'", "expected IN, BETWEEN, LIKE, GLOB or REGEXP", ""},
+	}
+
+	for i, tt := range tests {
+		testName := fmt.Sprintf("PATTERN_%d", i)
+		t.Run(testName, func(sub *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			p.OmitErrorLocation = true
+			program, err := p.Parse()
+
+			if err != nil {
+				if tt.expectedError == "" {
+					sub.Fatalf("expected no error, got %q", err)
+				}
+				if err.Error() != tt.expectedError {
+					sub.Fatalf("expected %q error, got %q", tt.expectedError, err)
+				}
+				return
+			} else if tt.expectedError != "" {
+				sub.Fatalf("expected %q error, got no error", tt.expectedError)
+			}
+
+			selectStmt := program.Statements[0].(*ast.SelectStatement)
+			if selectStmt.Predicate.String() != tt.expectedPredicate {
+				sub.Errorf("expected %q, got %q", tt.expectedPredicate, selectStmt.Predicate.String())
+			}
+		})
+	}
+}
+
+func TestParseArithmeticExpressions(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+		expectedValue string
+	}{
+		{"1 + 2 * 3", "", "7"},
+		{"(1 + 2) * 3", "", "9"},
+		{"7 / 2 - 1", "", "2"},
+		{"7 % 4 * 2", "", "6"},
+		{"7.5 / 2.5", "", "3.000000"},
+		{"-2 * -3", "", "6"},
+		{"-(1 + 2) * 2", "", "-6"},
+		{"2 - -1", "", "3"},
+		{"10 - 2 - 3", "", "5"},
+		{"(1 + 2", "expected )", ""},
+		{"2 *", "expected expression", ""},
+		{"-", "expected expression", ""},
+	}
+
+	for i, tt := range tests {
+		testName := fmt.Sprintf("ARITHMETIC_%d", i)
+		t.Run(testName, func(sub *testing.T) {
+			expr, err := ParseExpression(tt.input)
+			if err != nil {
+				if tt.expectedError == "" {
+					sub.Fatalf("expected no error, got %q", err)
+				}
+				if err.Error() != tt.expectedError {
+					sub.Fatalf("expected %q error, got %q", tt.expectedError, err)
+				}
+				return
+			} else if tt.expectedError != "" {
+				sub.Fatalf("expected %q error, got no error", tt.expectedError)
+			}
+
+			value, err := evaluator.EvalExpression(expr, nil)
+			if err != nil {
+				sub.Fatalf("error evaluating %s: %s", tt.input, err)
+			}
+			if value.String() != tt.expectedValue {
+				sub.Errorf("expected %s, got %s", tt.expectedValue, value.String())
+			}
+		})
+	}
+
+	// The asterisk only multiplies in expressions
+	program, err := New(lexer.New("SELECT * FROM people WHERE price * qty > 100")).Parse()
+	if err != nil {
+		t.Fatalf("expected no error, got %q", err)
+	}
+	stmt := program.Statements[0].(*ast.SelectStatement)
+	if len(stmt.Columns) != 1 || !stmt.Columns[0].Star {
+		t.Errorf("expected to select all columns")
+	}
+	if stmt.Predicate.String() != "(price*qty)>100" {
+		t.Errorf("expected %q, got %q", "(price*qty)>100", stmt.Predicate.String())
+	}
+}
+
+func TestParseTableConstraints(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedError  string
+		expectedString string
+	}{
+		{
+			"CREATE TABLE people (id INT PRIMARY KEY, email TEXT UNIQUE NOT NULL, name TEXT NULL)",
+			"",
+			"CREATE TABLE people (id INT PRIMARY KEY, email TEXT UNIQUE NOT NULL, name TEXT)",
+		},
+		{
+			"create table visits (person INT, day INT, note TEXT, primary key (person, day), unique (note))",
+			"",
+			"CREATE TABLE visits (person INT, day INT, note TEXT, PRIMARY KEY (person, day), UNIQUE (note))",
+		},
+		{
+			"CREATE TABLE addresses (id INT PRIMARY KEY, person INT NOT NULL REFERENCES people ON DELETE CASCADE)",
+			"",
+			"CREATE TABLE addresses (id INT PRIMARY KEY, person INT NOT NULL REFERENCES people ON DELETE CASCADE)",
+		},
+		{
+			"CREATE TABLE orders (person INT, day INT, FOREIGN KEY (person, day) REFERENCES visits (person, day) on delete set null)",
+			"",
+			"CREATE TABLE orders (person INT, day INT, FOREIGN KEY (person, day) REFERENCES visits (person, day) ON DELETE SET NULL)",
+		},
+		{"CREATE TABLE orders (person INT REFERENCES people (id) ON DELETE RESTRICT)", "", "CREATE TABLE orders (person INT REFERENCES people (id) ON DELETE RESTRICT)"},
+		{"CREATE TABLE orders (person INT REFERENCES people (id) ON DELETE)", "expected CASCADE, SET NULL or RESTRICT", ""},
+		{"CREATE TABLE orders (person INT, FOREIGN KEY (person) people (id))", "expected REFERENCES", ""},
+		{"CREATE TABLE people (id INT PRIMARY)", "expected KEY", ""},
+		{"CREATE TABLE people (id INT NOT)", "expected NULL", ""},
+		{"CREATE TABLE people (id INT, PRIMARY KEY ())", ErrEmptyColumnsList.Error(), ""},
+		{
+			"CREATE TABLE people (age INT CHECK ( age > 0 AND age < 150 ), CHECK (age IN (1, 2)))",
+			"",
+			"CREATE TABLE people (age INT CHECK (age > 0 AND age < 150), CHECK (age IN (1, 2)))",
+		},
+		{
+			"CREATE TABLE people (age INT NOT NULL CONSTRAINT adult CHECK (age > 17), CONSTRAINT young check (age < 30))",
+			"",
+			"CREATE TABLE people (age INT NOT NULL CONSTRAINT adult CHECK (age > 17), CONSTRAINT young CHECK (age < 30))",
+		},
+		{"CREATE TABLE people (age INT CHECK age > 0)", "expected (", ""},
+		{"CREATE TABLE people (age INT CHECK (age > 0)", "expected )", ""},
+		{"CREATE TABLE people (age INT CONSTRAINT CHECK (age > 0))", "expected constraint name", ""},
+		{"CREATE TABLE people (age INT, CONSTRAINT adult UNIQUE (age))", "expected CHECK", ""},
+	}
+
+	for i, tt := range tests {
+		testName := fmt.Sprintf("CONSTRAINT_%d", i)
+		t.Run(testName, func(sub *testing.T) {
+			l := lexer.New(tt.input)
+			p := New(l)
+			p.OmitErrorLocation = true
+			program, err := p.Parse()
+
+			if err != nil {
+				if tt.expectedError == "" {
+					sub.Fatalf("expected no error, got %q", err)
+				}
+				if err.Error() != tt.expectedError {
+					sub.Fatalf("expected %q error, got %q", tt.expectedError, err)
+				}
+				return
+			} else if tt.expectedError != "" {
+				sub.Fatalf("expected %q error, got no error", tt.expectedError)
+			}
+
+			stmt := program.Statements[0]
+			if stmt.String() != tt.expectedString {
+				sub.Fatalf("expected %q, got %q", tt.expectedString, stmt.String())
+			}
+		})
+	}
+}
@@ -9,7 +9,18 @@ import (
 type prefixParseFn func(*Parser) (ast.Expression, error)
 
 func parseIdentifier(p *Parser) (ast.Expression, error) {
-	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}, nil
+	if !p.checkPeekToken(token.DOT) {
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}, nil
+	}
+
+	// A name qualified by the table it belongs to
+	table := p.curToken
+	p.nextToken()
+	if !p.expectPeekToken(token.IDENTIFIER) {
+		return nil, expectedTokenError(token.IDENTIFIER)
+	}
+
+	return &ast.Identifier{Token: p.curToken, Table: table, Value: p.curToken.Literal}, nil
 }
 
 func parseIntegerLiteral(p *Parser) (ast.Expression, error) {
@@ -31,3 +42,142 @@ func parseFloatLiteral(p *Parser) (ast.Expression, error) {
 func parseStringLiteral(p *Parser) (ast.Expression, error) {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}, nil
 }
+
+func parseNullLiteral(p *Parser) (ast.Expression, error) {
+	return &ast.Null{Token: p.curToken}, nil
+}
+
+func parsePrefixExpression(p *Parser) (ast.Expression, error) {
+	prefixExpr := &ast.PrefixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+	}
+
+	p.nextToken()
+	right, err := p.parseExpression(PREFIX)
+	if err != nil {
+		return nil, err
+	}
+
+	prefixExpr.Right = right
+
+	return prefixExpr, nil
+}
+
+func parseCaseExpression(p *Parser) (ast.Expression, error) {
+	caseExpr := &ast.CaseExpression{Token: p.curToken}
+
+	// Simple CASE expressions compare an operand with each WHEN value
+	if !p.checkPeekToken(token.WHEN) && !p.checkPeekToken(token.END) {
+		p.nextToken()
+		operand, err := p.parseExpression(LOWEST)
+		if err != nil {
+			return nil, err
+		}
+		caseExpr.Operand = operand
+	}
+
+	for p.expectPeekToken(token.WHEN) {
+		p.nextToken()
+		condition, err := p.parseExpression(LOWEST)
+		if err != nil {
+			return nil, err
+		}
+
+		if !p.expectPeekToken(token.THEN) {
+			return nil, expectedTokenError(token.THEN)
+		}
+
+		p.nextToken()
+		result, err := p.parseExpression(LOWEST)
+		if err != nil {
+			return nil, err
+		}
+
+		caseExpr.Whens = append(caseExpr.Whens, &ast.WhenClause{Condition: condition, Result: result})
+	}
+
+	if len(caseExpr.Whens) == 0 {
+		return nil, expectedTokenError(token.WHEN)
+	}
+
+	if p.expectPeekToken(token.ELSE) {
+		p.nextToken()
+		elseExpr, err := p.parseExpression(LOWEST)
+		if err != nil {
+			return nil, err
+		}
+		caseExpr.Else = elseExpr
+	}
+
+	if !p.expectPeekToken(token.END) {
+		return nil, expectedTokenError(token.END)
+	}
+
+	return caseExpr, nil
+}
+
+func parseGroupedExpression(p *Parser) (ast.Expression, error) {
+	if p.checkPeekQuery() {
+		return p.parseSubquery()
+	}
+
+	p.nextToken()
+	expr, err := p.parseExpression(LOWEST)
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.expectPeekToken(token.RPAREN) {
+		return nil, expectedTokenError(token.RPAREN)
+	}
+
+	return expr, nil
+}
+
+func parseExistsExpression(p *Parser) (ast.Expression, error) {
+	expr := &ast.ExistsExpression{Token: p.curToken}
+
+	if !p.expectPeekToken(token.LPAREN) {
+		return nil, expectedTokenError(token.LPAREN)
+	}
+	if !p.checkPeekQuery() {
+		return nil, expectedTokenError(token.SELECT)
+	}
+
+	sub, err := p.parseSubquery()
+	if err != nil {
+		return nil, err
+	}
+	expr.Subquery = sub
+
+	return expr, nil
+}
+
+// parseNotPrefixExpression parses NOT EXISTS, or NOT before any other
+// condition. NOT binds looser than comparisons, so NOT a = 1 negates a = 1.
+func parseNotPrefixExpression(p *Parser) (ast.Expression, error) {
+	if p.expectPeekToken(token.EXISTS) {
+		expr, err := parseExistsExpression(p)
+		if err != nil {
+			return nil, err
+		}
+		expr.(*ast.ExistsExpression).Not = true
+
+		return expr, nil
+	}
+
+	notExpr := &ast.PrefixExpression{
+		Token:    p.curToken,
+		Operator: "NOT",
+	}
+
+	p.nextToken()
+	right, err := p.parseExpression(NOT)
+	if err != nil {
+		return nil, err
+	}
+	notExpr.Right = right
+
+	return notExpr, nil
+}
@@ -16,10 +16,14 @@ import (
 const PROMPT = "sqlit> "
 
 func Start(input io.Reader, output io.Writer) {
+	Run(input, output, engine.NewMemoryBackend(nil))
+}
+
+// Run starts the REPL against an existing backend.
+func Run(input io.Reader, output io.Writer, backend engine.Engine) {
 	scanner := bufio.NewScanner(input)
 	scanner.Split(bufio.ScanLines)
 
-	backend := engine.NewMemoryBackend(nil)
 	fmt.Println("SQLit version 1.0")
 
 	for {
@@ -39,52 +43,70 @@ func Start(input io.Reader, output io.Writer) {
 
 		end := len(program.Statements) - 1
 
+		// Run the whole line atomically unless it manages transactions itself
+		implicit := end >= 0 && !backend.InTransaction() && !program.HasTransactionStatements()
+		if implicit {
+			if err := backend.Begin(); err != nil {
+				fmt.Fprintf(os.Stderr, "program error: %s\n", err)
+				continue
+			}
+		}
+
 		startTime := time.Now()
 	loop:
 		for i, stmt := range program.Statements {
 			switch st := stmt.(type) {
 			case *ast.CreateTableStatement:
-				err := backend.CreateTable(st)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "program error: %s\n", err)
-					break loop
-				}
+				err = backend.CreateTable(st)
 			case *ast.InsertStatement:
-				err := backend.Insert(st)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "program error: %s\n", err)
-					break loop
-				}
+				err = backend.Insert(st)
+			case *ast.CreateIndexStatement:
+				err = backend.CreateIndex(st)
+			case *ast.DropIndexStatement:
+				err = backend.DropIndex(st)
 			case *ast.SelectStatement:
-				res, err := backend.Select(st)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "program error: %s\n", err)
-					break loop
-				} else if i == end {
+				var res *engine.FetchResult
+				res, err = backend.Select(st)
+				if err == nil && i == end {
 					// Print only if result is not empty
 					if len(res.Rows) != 0 {
 						fmt.Fprintln(output, utils.FormatSelectResult(res))
 					}
 				}
 			case *ast.DeleteStatement:
-				res, err := backend.Delete(st)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "program error: %s\n", err)
-					break loop
-				} else if i == end {
-					// Print only if result is not empty
+				var res *engine.UpdateResult
+				res, err = backend.Delete(st)
+				if err == nil && i == end {
 					fmt.Fprintf(output, "affected rows: %d\n", res.AffectedRows)
 				}
 			case *ast.UpdateStatement:
-				res, err := backend.Update(st)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "program error: %s\n", err)
-					break loop
-				} else if i == end {
-					// Print only if result is not empty
+				var res *engine.UpdateResult
+				res, err = backend.Update(st)
+				if err == nil && i == end {
 					fmt.Fprintf(output, "affected rows: %d\n", res.AffectedRows)
 				}
+			case *ast.BeginStatement:
+				err = backend.Begin()
+			case *ast.CommitStatement:
+				err = backend.Commit()
+			case *ast.RollbackStatement:
+				err = backend.Rollback()
+			}
+
+			if err == nil && i == end && implicit {
+				err = backend.Commit()
 			}
+
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "program error: %s\n", err)
+				// A failed statement aborts the transaction it ran in
+				if backend.InTransaction() {
+					backend.Rollback()
+					fmt.Fprintln(os.Stderr, "transaction rolled back")
+				}
+				break loop
+			}
+
 			if i == end {
 				duration := time.Now().Sub(startTime).Seconds()
 				fmt.Fprintf(output, "ok (took %.2fs)\n", duration)
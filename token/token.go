@@ -15,6 +15,8 @@ type Token struct {
 	Type     TokenType
 	Literal  string
 	Location *TokenLocation
+	// Position is the offset of the token's first byte in the source
+	Position int
 }
 
 const (
@@ -40,12 +42,99 @@ const (
 	FLOAT      TokenType = "FLOAT"
 	TEXT       TokenType = "TEXT"
 
+	// Transactions
+	BEGIN       TokenType = "BEGIN"
+	COMMIT      TokenType = "COMMIT"
+	ROLLBACK    TokenType = "ROLLBACK"
+	TRANSACTION TokenType = "TRANSACTION"
+
+	// Indexes
+	INDEX  TokenType = "INDEX"
+	UNIQUE TokenType = "UNIQUE"
+	ON     TokenType = "ON"
+	DROP   TokenType = "DROP"
+	USING  TokenType = "USING"
+
+	// Predicates
+	IN      TokenType = "IN"
+	BETWEEN TokenType = "BETWEEN"
+	EXISTS  TokenType = "EXISTS"
+
+	// Constraints
+	PRIMARY TokenType = "PRIMARY"
+	KEY     TokenType = "KEY"
+	NOT     TokenType = "NOT"
+	NULL    TokenType = "NULL"
+
+	// Foreign keys
+	FOREIGN    TokenType = "FOREIGN"
+	REFERENCES TokenType = "REFERENCES"
+	CASCADE    TokenType = "CASCADE"
+	RESTRICT   TokenType = "RESTRICT"
+
+	// Check constraints
+	CHECK      TokenType = "CHECK"
+	CONSTRAINT TokenType = "CONSTRAINT"
+
+	// Null tests
+	IS TokenType = "IS"
+
+	// Pattern matching
+	LIKE   TokenType = "LIKE"
+	GLOB   TokenType = "GLOB"
+	REGEXP TokenType = "REGEXP"
+	ESCAPE TokenType = "ESCAPE"
+
+	// Joins
+	JOIN  TokenType = "JOIN"
+	INNER TokenType = "INNER"
+	LEFT  TokenType = "LEFT"
+	RIGHT TokenType = "RIGHT"
+	FULL  TokenType = "FULL"
+	OUTER TokenType = "OUTER"
+	CROSS TokenType = "CROSS"
+
+	// Grouping
+	GROUP    TokenType = "GROUP"
+	BY       TokenType = "BY"
+	HAVING   TokenType = "HAVING"
+	DISTINCT TokenType = "DISTINCT"
+
+	// Ordering
+	ORDER  TokenType = "ORDER"
+	ASC    TokenType = "ASC"
+	DESC   TokenType = "DESC"
+	NULLS  TokenType = "NULLS"
+	LIMIT  TokenType = "LIMIT"
+	OFFSET TokenType = "OFFSET"
+
+	// Common table expressions
+	WITH      TokenType = "WITH"
+	RECURSIVE TokenType = "RECURSIVE"
+
+	// Compound selects
+	UNION     TokenType = "UNION"
+	INTERSECT TokenType = "INTERSECT"
+	EXCEPT    TokenType = "EXCEPT"
+	ALL       TokenType = "ALL"
+
+	// Window functions
+	OVER TokenType = "OVER"
+
+	// Conditional expressions
+	CASE TokenType = "CASE"
+	WHEN TokenType = "WHEN"
+	THEN TokenType = "THEN"
+	ELSE TokenType = "ELSE"
+	END  TokenType = "END"
+
 	STRING TokenType = "STRING"
 
 	// Symbols
 	SEMICOLON TokenType = ";"
 	ASTERISK  TokenType = "*"
 	COMMA     TokenType = ","
+	DOT       TokenType = "."
 	LPAREN    TokenType = "("
 	RPAREN    TokenType = ")"
 
@@ -55,6 +144,10 @@ const (
 	N_EQ  TokenType = "!="
 	GT    TokenType = ">"
 	LT    TokenType = "<"
+
+	// Arithmetic
+	SLASH   TokenType = "/"
+	PERCENT TokenType = "%"
 )
 
 var keywords = map[string]TokenType{
@@ -75,6 +168,77 @@ var keywords = map[string]TokenType{
 	"INT":    INT,
 	"FLOAT":  FLOAT,
 	"TEXT":   TEXT,
+
+	"BEGIN":       BEGIN,
+	"COMMIT":      COMMIT,
+	"ROLLBACK":    ROLLBACK,
+	"TRANSACTION": TRANSACTION,
+
+	"INDEX":  INDEX,
+	"UNIQUE": UNIQUE,
+	"ON":     ON,
+	"DROP":   DROP,
+	"USING":  USING,
+
+	"IN":      IN,
+	"BETWEEN": BETWEEN,
+	"EXISTS":  EXISTS,
+
+	"PRIMARY": PRIMARY,
+	"KEY":     KEY,
+	"NOT":     NOT,
+	"NULL":    NULL,
+
+	"FOREIGN":    FOREIGN,
+	"REFERENCES": REFERENCES,
+	"CASCADE":    CASCADE,
+	"RESTRICT":   RESTRICT,
+
+	"CHECK":      CHECK,
+	"CONSTRAINT": CONSTRAINT,
+
+	"IS": IS,
+
+	"LIKE":   LIKE,
+	"GLOB":   GLOB,
+	"REGEXP": REGEXP,
+	"ESCAPE": ESCAPE,
+
+	"JOIN":  JOIN,
+	"INNER": INNER,
+	"LEFT":  LEFT,
+	"RIGHT": RIGHT,
+	"FULL":  FULL,
+	"OUTER": OUTER,
+	"CROSS": CROSS,
+
+	"GROUP":    GROUP,
+	"BY":       BY,
+	"HAVING":   HAVING,
+	"DISTINCT": DISTINCT,
+
+	"ORDER":  ORDER,
+	"ASC":    ASC,
+	"DESC":   DESC,
+	"NULLS":  NULLS,
+	"LIMIT":  LIMIT,
+	"OFFSET": OFFSET,
+
+	"WITH":      WITH,
+	"RECURSIVE": RECURSIVE,
+
+	"UNION":     UNION,
+	"INTERSECT": INTERSECT,
+	"EXCEPT":    EXCEPT,
+	"ALL":       ALL,
+
+	"OVER": OVER,
+
+	"CASE": CASE,
+	"WHEN": WHEN,
+	"THEN": THEN,
+	"ELSE": ELSE,
+	"END":  END,
 }
 
 func init() {
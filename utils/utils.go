@@ -31,15 +31,7 @@ func FormatSelectResult(result *engine.FetchResult) string {
 	for _, row := range result.Rows {
 		var rowBuilder strings.Builder
 		for i, cell := range row {
-			resCol := result.Columns[i]
-			content := ""
-			if resCol.Type == engine.INT_COLUMN {
-				content = fmt.Sprintf("%d", cell.AsInt())
-			} else if resCol.Type == engine.FLOAT_COLUMN {
-				content = fmt.Sprintf("%f", cell.AsFloat())
-			} else {
-				content = cell.AsText()
-			}
+			content := formatCell(cell, result.Columns[i])
 
 			if i == 0 {
 				rowBuilder.WriteString("|")
@@ -54,6 +46,10 @@ func FormatSelectResult(result *engine.FetchResult) string {
 	return fmt.Sprintf("%s\n%s", header.String(), rowsBuilder.String())
 }
 
+func formatCell(cell engine.Cell, resCol *engine.ResultColumn) string {
+	return cell.Value(resCol.Type).String()
+}
+
 func alignText(str string, length int, prefix string) string {
 	res := str
 	if len(res) < length {
@@ -65,15 +61,7 @@ func alignText(str string, length int, prefix string) string {
 func getLargestCellSize(column int, result *engine.FetchResult) int {
 	largest := 0.
 	for _, row := range result.Rows {
-		content := ""
-		resCol := result.Columns[column]
-		if resCol.Type == engine.INT_COLUMN {
-			content = fmt.Sprintf("%d", row[column].AsInt())
-		} else if resCol.Type == engine.FLOAT_COLUMN {
-			content = fmt.Sprintf("%f", row[column].AsFloat())
-		} else {
-			content = row[column].AsText()
-		}
+		content := formatCell(row[column], result.Columns[column])
 		largest = math.Max(largest, float64(len(content)))
 	}
 	return int(largest)